@@ -1,94 +1,376 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 
+	"book-service/internal/config"
 	"book-service/internal/handler"
 	"book-service/internal/repository"
 	"book-service/internal/service"
 	"book-service/pkg/database"
+	"book-service/pkg/health"
+	"book-service/pkg/logging"
 	"book-service/pkg/middlewares"
+	"book-service/pkg/tracing"
 )
 
 func main() {
-	// Database configuration
-	dbUser := os.Getenv("DB_USER")
-	if dbUser == "" {
-		dbUser = "postgres"
+	cfg, opts, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
 	}
-	dbPassword := os.Getenv("DB_PASSWORD")
-	if dbPassword == "" {
-		dbPassword = "postgres"
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("Failed to print configuration", err)
+		}
+		return
 	}
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
+
+	// JSON logs on stdout
+	logger := logging.New(os.Stdout, cfg.LogLevel())
+	slog.SetDefault(logger)
+	if opts.File != "" {
+		slog.Info("Loaded configuration", "file", opts.File)
 	}
-	dbPort := os.Getenv("DB_PORT")
-	if dbPort == "" {
-		dbPort = "5432"
+
+	// Schema migrations
+	if len(opts.Args) > 0 {
+		if opts.Args[0] != "migrate" {
+			fatal("Unknown command", fmt.Errorf("%q, expected migrate", opts.Args[0]))
+		}
+		db, err := connectDatabase(cfg.Database)
+		if err != nil {
+			fatal("Failed to connect to database", err)
+		}
+		err = runMigrate(db, opts.Args[1:])
+		db.Close()
+		if err != nil {
+			fatal("Migration failed", err)
+		}
+		return
 	}
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		dbName = "bookdb"
+
+	if err := run(cfg, logger); err != nil {
+		fatal("Server failed", err)
 	}
+}
 
-	// Connect to database
-	log.Println("Connecting to database...")
-	db, err := database.NewConnection(dbUser, dbPassword, dbHost, dbPort, dbName)
+// run serves until SIGINT or SIGTERM, then drains in-flight requests and
+// releases every resource it opened before returning.
+func run(cfg *config.Config, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Tracing, exported over OTLP or to stdout
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName: cfg.Tracing.ServiceName,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: &cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	// Readiness reflects the dependencies the storage backend needs
+	checker := health.NewChecker()
+
+	// Storage backend
+	var (
+		store   repository.BookStore
+		lending repository.FineStore
+	)
+	switch cfg.Storage {
+	case "memory":
+		slog.Warn("Using in-memory storage, data will not survive a restart")
+		memory := repository.NewMemoryBookStore()
+		store, lending = memory, memory
+	case "postgres":
+		db, err := connectDatabase(cfg.Database)
+		if err != nil {
+			return fmt.Errorf("connect to database: %w", err)
+		}
+		defer db.Close()
+
+		slog.Info("Applying migrations")
+		migrator, err := database.NewMigrator(db)
+		if err != nil {
+			return fmt.Errorf("load migrations: %w", err)
+		}
+		if err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("apply migrations: %w", err)
+		}
+		checker.AddReadinessCheck("database", db.PingContext)
+		checker.AddReadinessCheck("migrations", migrator.CheckCurrent)
+
+		// Connection pool and per-operation query metrics
+		if err := database.RegisterPoolMetrics(nil, db, "books"); err != nil {
+			return fmt.Errorf("register pool metrics: %w", err)
+		}
+		queryMetrics, err := repository.NewQueryMetrics(nil)
+		if err != nil {
+			return fmt.Errorf("register query metrics: %w", err)
+		}
+
+		repo := repository.NewBookRepository(db, repository.BookRepositoryConfig{
+			Metrics:  queryMetrics,
+			Timeouts: cfg.Database.Timeouts(),
+		})
+		store, lending = repo, repo
+	}
+
+	// Shared state between replicas: rate limit budgets and cached books
+	var redisClient *redis.Client
+	if cfg.Redis.Addr != "" {
+		redisClient = redis.NewClient(&redis.Options{Addr: cfg.Redis.Addr, Password: cfg.Redis.Password.Value()})
+		defer redisClient.Close()
 	}
-	defer db.Close()
 
-	// Initialize schema
-	log.Println("Initializing schema...")
-	if err := database.InitializeSchema(db); err != nil {
-		log.Fatalf("Failed to initialize schema: %v", err)
+	// Read-through cache for book lookups, kept coherent across replicas
+	// through Redis when it is configured
+	if cfg.Cache.Enabled {
+		cacheCfg := repository.BookCacheConfig{Size: cfg.Cache.Size, LocalTTL: cfg.Cache.LocalTTL.Std()}
+		if redisClient != nil {
+			redisCache := repository.NewRedisBookCache(redisClient)
+			redisCache.TTL = cfg.Cache.RedisTTL.Std()
+			cacheCfg.Redis = redisCache
+		}
+		cached, err := repository.NewCachedBookStore(store, cacheCfg)
+		if err != nil {
+			return fmt.Errorf("register cache metrics: %w", err)
+		}
+		go cached.Listen(ctx)
+		store = cached
 	}
 
 	// Initialize repository, service, and handler
-	repo := repository.NewBookRepository(db)
-	svc := service.NewBookService(repo)
+	svc := service.NewBookService(store)
 	bookHandler := handler.NewBookHandler(svc)
+	authorHandler := handler.NewAuthorHandler(service.NewAuthorService(store))
+	fineSvc := service.NewFineService(lending, cfg.Fines.Policy())
+	fineHandler := handler.NewFineHandler(fineSvc)
+	lendingSvc := service.NewLendingService(lending, service.LendingConfig{
+		LoanPeriod:   cfg.Lending.LoanPeriod.Std(),
+		PickupWindow: cfg.Lending.PickupWindow.Std(),
+		Fines:        fineSvc,
+	})
+	lendingHandler := handler.NewLendingHandler(lendingSvc)
+
+	// Hard-delete books that have been in the trash longer than the
+	// retention period
+	if cfg.Trash.Retention > 0 {
+		go svc.RunPurge(ctx, cfg.Trash.PurgeInterval.Std(), cfg.Trash.Retention.Std())
+	}
+
+	// Pass copies that holders did not collect in time down the queue
+	go lendingSvc.RunHoldSweep(ctx, cfg.Lending.HoldSweepInterval.Std())
+
+	// Bring the fines on overdue loans up to date
+	go fineSvc.RunAccrual(ctx, cfg.Fines.AccrualInterval.Std())
 
 	// Setup routes
 	r := mux.NewRouter()
 
-	// Rate limiting middleware
-	rateLimitMiddleware := middlewares.NewRateLimitMiddleware(50)
+	// Request IDs, server spans continuing the caller's trace from
+	// traceparent, and an access log line per request tagged with both
+	r.Use(middlewares.RequestID)
+	r.Use(middlewares.NewTracing(middlewares.TracingConfig{}).Middleware)
+	r.Use(middlewares.NewAccessLog(logger))
+
+	// RED metrics per route template, method and status code
+	metrics, err := middlewares.NewMetrics(middlewares.MetricsConfig{Prefix: cfg.Metrics.Prefix})
+	if err != nil {
+		return fmt.Errorf("register metrics: %w", err)
+	}
+	r.Use(metrics.Middleware)
+
+	// Admin requests, which may see and restore deleted books, read and
+	// revert book history, manage members and copies, see hold queues, and
+	// record fine payments and closure days
+	r.Use(middlewares.NewAdminAuth(cfg.Admin.Actors()))
+
+	// Bearer tokens signed with the configured secret, which identify
+	// members acting for themselves
+	r.Use(middlewares.NewJWTAuth([]byte(cfg.Auth.JWTSecret.Value())))
+
+	// Who each request acts for, recorded in the history of the books it
+	// changes: the admin, the subject of a verified bearer token, or the
+	// client IP. What an unverified token claims is never recorded.
+	r.Use(middlewares.NewActor(middlewares.FirstKey(
+		middlewares.KeyByAdmin,
+		middlewares.KeyByVerifiedSubject,
+		middlewares.KeyByIP,
+	)))
+
+	// Rate limiting, keyed by the subject of a verified bearer token and by
+	// IP otherwise, with separate budgets for reads, writes and bulk jobs.
+	// Unverified credentials never pick the bucket, or a client could take
+	// a fresh one for every request. Budgets are shared between replicas
+	// through Redis when an address is configured.
+	clientKey := middlewares.FirstKey(middlewares.KeyByVerifiedSubject, middlewares.KeyByIP)
+	var limitStore middlewares.RateLimitStore = middlewares.NewMemoryRateLimitStore()
+	if redisClient != nil {
+		limitStore = middlewares.NewRedisRateLimitStore(redisClient)
+	}
+	newLimit := func(route string, limit config.Limit) func(http.Handler) http.Handler {
+		return middlewares.NewRateLimitMiddleware(middlewares.RateLimitConfig{
+			Route:    route,
+			Rate:     limit.Rate,
+			Burst:    limit.Burst,
+			Key:      clientKey,
+			Store:    limitStore,
+			FailOpen: cfg.RateLimit.FailOpen,
+		})
+	}
+	readLimit := newLimit("read", cfg.RateLimit.Read)
+	writeLimit := newLimit("write", cfg.RateLimit.Write)
+	bulkLimit := newLimit("bulk", cfg.RateLimit.Bulk)
+
+	// HTTP caching: Cache-Control per route, 304 for conditional GETs, and
+	// Vary on the export, whose format follows Accept
+	newCache := func(cacheControl string, vary ...string) func(http.Handler) http.Handler {
+		return middlewares.NewHTTPCache(middlewares.HTTPCacheConfig{CacheControl: cacheControl, Vary: vary})
+	}
+	bookCache := newCache(cfg.HTTPCache.Book)
+	listCache := newCache(cfg.HTTPCache.List)
+	searchCache := newCache(cfg.HTTPCache.Search)
+	exportCache := newCache("no-store", "Accept")
 
 	// Book routes
-	r.HandleFunc("/api/books", bookHandler.CreateBook).Methods("POST")
-	r.HandleFunc("/api/books", bookHandler.GetAllBooks).Methods("GET")
-	r.Handle("/api/books/{id}", rateLimitMiddleware(http.HandlerFunc(bookHandler.GetBook))).Methods("GET")
-	r.HandleFunc("/api/books/{id}", bookHandler.UpdateBook).Methods("PUT")
-	r.HandleFunc("/api/books/{id}", bookHandler.DeleteBook).Methods("DELETE")
-
-	// Health check
-	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, `{"status":"ok"}`)
-	}).Methods("GET")
+	r.Handle("/api/books", writeLimit(http.HandlerFunc(bookHandler.CreateBook))).Methods("POST")
+	r.Handle("/api/books", readLimit(listCache(http.HandlerFunc(bookHandler.ListBooks)))).Methods("GET")
+	r.Handle("/api/books:import", bulkLimit(http.HandlerFunc(bookHandler.ImportBooks))).Methods("POST")
+	r.Handle("/api/books:export", bulkLimit(exportCache(http.HandlerFunc(bookHandler.ExportBooks)))).Methods("GET")
+	r.Handle("/api/books/search", readLimit(searchCache(http.HandlerFunc(bookHandler.SearchBooks)))).Methods("GET")
+	r.Handle("/api/books/{id}:checkout", writeLimit(http.HandlerFunc(lendingHandler.Checkout))).Methods("POST")
+	r.Handle("/api/books/{id}:reserve", writeLimit(http.HandlerFunc(lendingHandler.Reserve))).Methods("POST")
+	r.Handle("/api/books/{id}/holds", readLimit(http.HandlerFunc(lendingHandler.BookHolds))).Methods("GET")
+	r.Handle("/api/books/{id}/copies", readLimit(http.HandlerFunc(lendingHandler.ListCopies))).Methods("GET")
+	r.Handle("/api/books/{id}/copies", writeLimit(http.HandlerFunc(lendingHandler.AddCopy))).Methods("POST")
+	r.Handle("/api/books/{id}:restore", writeLimit(http.HandlerFunc(bookHandler.RestoreBook))).Methods("POST")
+	r.Handle("/api/books/{id}/history", readLimit(http.HandlerFunc(bookHandler.BookHistory))).Methods("GET")
+	r.Handle("/api/books/{id}/history/{rev}:revert", writeLimit(http.HandlerFunc(bookHandler.RevertBook))).Methods("POST")
+	r.Handle("/api/books/{id}", readLimit(bookCache(http.HandlerFunc(bookHandler.GetBook)))).Methods("GET")
+	r.Handle("/api/books/{id}", writeLimit(http.HandlerFunc(bookHandler.UpdateBook))).Methods("PUT")
+	r.Handle("/api/books/{id}", writeLimit(http.HandlerFunc(bookHandler.DeleteBook))).Methods("DELETE")
+
+	// Author routes
+	r.Handle("/api/authors", writeLimit(http.HandlerFunc(authorHandler.CreateAuthor))).Methods("POST")
+	r.Handle("/api/authors", readLimit(http.HandlerFunc(authorHandler.ListAuthors))).Methods("GET")
+	r.Handle("/api/authors/{id}/books", readLimit(http.HandlerFunc(authorHandler.AuthorBooks))).Methods("GET")
+	r.Handle("/api/authors/{id}", readLimit(http.HandlerFunc(authorHandler.GetAuthor))).Methods("GET")
+	r.Handle("/api/authors/{id}", writeLimit(http.HandlerFunc(authorHandler.UpdateAuthor))).Methods("PUT")
+	r.Handle("/api/authors/{id}", writeLimit(http.HandlerFunc(authorHandler.DeleteAuthor))).Methods("DELETE")
+
+	// Lending routes
+	r.Handle("/api/members", writeLimit(http.HandlerFunc(lendingHandler.CreateMember))).Methods("POST")
+	r.Handle("/api/members/{id}/loans", readLimit(http.HandlerFunc(lendingHandler.MemberLoans))).Methods("GET")
+	r.Handle("/api/members/{id}", readLimit(http.HandlerFunc(lendingHandler.GetMember))).Methods("GET")
+	r.Handle("/api/loans/{id}:return", writeLimit(http.HandlerFunc(lendingHandler.ReturnLoan))).Methods("POST")
+	r.Handle("/api/loans/{id}", readLimit(http.HandlerFunc(lendingHandler.GetLoan))).Methods("GET")
+	r.Handle("/api/holds/{id}:cancel", writeLimit(http.HandlerFunc(lendingHandler.CancelHold))).Methods("POST")
+	r.Handle("/api/holds/{id}", readLimit(http.HandlerFunc(lendingHandler.GetHold))).Methods("GET")
+
+	// Fine routes
+	r.Handle("/api/members/{id}/fines", readLimit(http.HandlerFunc(fineHandler.MemberFines))).Methods("GET")
+	r.Handle("/api/fines/{id}:pay", writeLimit(http.HandlerFunc(fineHandler.PayFine))).Methods("POST")
+	r.Handle("/api/fines/{id}", readLimit(http.HandlerFunc(fineHandler.GetFine))).Methods("GET")
+	r.Handle("/api/closures", readLimit(http.HandlerFunc(fineHandler.ListClosures))).Methods("GET")
+	r.Handle("/api/closures", writeLimit(http.HandlerFunc(fineHandler.AddClosure))).Methods("POST")
+	r.Handle("/api/closures/{day}", writeLimit(http.HandlerFunc(fineHandler.DeleteClosure))).Methods("DELETE")
+
+	// Probes. /health is kept for existing checks and means ready.
+	r.HandleFunc("/livez", checker.Livez).Methods("GET")
+	r.HandleFunc("/readyz", checker.Readyz).Methods("GET")
+	r.HandleFunc("/health", checker.Readyz).Methods("GET")
 
 	// Prometheus metrics
-	r.Handle("/metrics", promhttp.Handler())
+	r.Handle(cfg.Metrics.Path, promhttp.Handler())
 
 	// Start server
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	port := strconv.Itoa(cfg.Server.Port)
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Std(),
+		ReadTimeout:       cfg.Server.ReadTimeout.Std(),
+		WriteTimeout:      cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:       cfg.Server.IdleTimeout.Std(),
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal kills the process instead of waiting for the drain.
+	stop()
+
+	// Fail readiness first and keep serving for the drain period, so load
+	// balancers stop routing here before the listener closes.
+	slog.Info("Shutting down", "drain", cfg.Server.ShutdownDrain.String(), "timeout", cfg.Server.ShutdownTimeout.String())
+	checker.SetShuttingDown()
+	time.Sleep(cfg.Server.ShutdownDrain.Std())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Std())
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("drain in-flight requests: %w", err)
 	}
+	slog.Info("Server stopped")
+	return nil
+}
 
-	log.Printf("Starting server on port %s...\n", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("Server failed: %v", err)
+func connectDatabase(cfg config.DatabaseConfig) (*sql.DB, error) {
+	if cfg.DSN != "" {
+		slog.Info("Connecting to database", "dsn", cfg.DSN)
+	} else {
+		slog.Info("Connecting to database", "host", cfg.Host, "port", cfg.Port, "database", cfg.Name)
 	}
+	return database.NewConnection(cfg.ConnString(), database.PoolConfig{
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime.Std(),
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime.Std(),
+	})
+}
+
+// fatal logs err and exits. Deferred calls do not run.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,96 @@
+// Package bulk reads and writes book catalog dumps as CSV or newline
+// delimited JSON, one record at a time so dumps never have to fit in memory.
+package bulk
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	"book-service/internal/models"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported format, expected text/csv or application/x-ndjson")
+	ErrMalformed         = errors.New("malformed input")
+)
+
+// Record is one decoded input row. Err is set when the row itself could not
+// be decoded; the reader can still continue with the next row.
+type Record struct {
+	Line int
+	Book models.CreateBookRequest
+	Err  error
+}
+
+// Reader yields records until it returns io.EOF. Any other error means the
+// input is malformed beyond the current row and reading must stop.
+type Reader interface {
+	Read() (*Record, error)
+}
+
+type Writer interface {
+	Write(book *models.Book) error
+	Flush() error
+}
+
+// FormatFromMediaType maps a Content-Type or Accept value to a Format.
+func FormatFromMediaType(value string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return "", ErrUnsupportedFormat
+	}
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV, nil
+	case "application/x-ndjson", "application/jsonl", "application/json-seq":
+		return FormatNDJSON, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// ParseFormat accepts the short names used in query strings.
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+func NewReader(format Format, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVReader(r)
+	case FormatNDJSON:
+		return NewNDJSONReader(r), nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w)
+	case FormatNDJSON:
+		return NewNDJSONWriter(w), nil
+	}
+	return nil, ErrUnsupportedFormat
+}
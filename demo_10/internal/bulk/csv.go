@@ -0,0 +1,124 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"book-service/internal/models"
+)
+
+var csvImportColumns = []string{"title", "author", "isbn", "pages", "published"}
+
+var csvExportColumns = []string{"id", "title", "author", "isbn", "pages", "published", "created_at", "updated_at", "version"}
+
+type CSVReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// NewCSVReader reads the header row and checks every import column is
+// present. Columns may appear in any order; unknown columns are ignored.
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: csv input is empty, expected a header row", ErrMalformed)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range csvImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: csv header is missing column %q", ErrMalformed, name)
+		}
+	}
+
+	return &CSVReader{r: cr, columns: columns}, nil
+}
+
+func (c *CSVReader) Read() (*Record, error) {
+	row, err := c.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	line, _ := c.r.FieldPos(0)
+	rec := &Record{Line: line}
+	field := func(name string) string { return strings.TrimSpace(row[c.columns[name]]) }
+
+	rec.Book = models.CreateBookRequest{
+		Title:  field("title"),
+		Author: field("author"),
+		ISBN:   field("isbn"),
+	}
+	if raw := field("pages"); raw != "" {
+		if rec.Book.Pages, err = strconv.Atoi(raw); err != nil {
+			rec.Err = fmt.Errorf("pages: %q is not an integer", raw)
+			return rec, nil
+		}
+	}
+	if raw := field("published"); raw != "" {
+		if rec.Book.Published, err = parseDate(raw); err != nil {
+			rec.Err = fmt.Errorf("published: %q is not a date", raw)
+			return rec, nil
+		}
+	}
+
+	return rec, nil
+}
+
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+func NewCSVWriter(w io.Writer) (*CSVWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvExportColumns); err != nil {
+		return nil, err
+	}
+	return &CSVWriter{w: cw}, nil
+}
+
+func (c *CSVWriter) Write(book *models.Book) error {
+	return c.w.Write([]string{
+		strconv.Itoa(book.ID),
+		book.Title,
+		book.Author,
+		book.ISBN,
+		strconv.Itoa(book.Pages),
+		book.Published.Format(time.RFC3339),
+		book.CreatedAt.Format(time.RFC3339Nano),
+		book.UpdatedAt.Format(time.RFC3339Nano),
+		strconv.Itoa(book.Version),
+	})
+}
+
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func parseDate(raw string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", raw)
+}
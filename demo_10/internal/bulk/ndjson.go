@@ -0,0 +1,64 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"book-service/internal/models"
+)
+
+// maxNDJSONLine bounds a single record so one runaway line cannot exhaust
+// memory.
+const maxNDJSONLine = 1 << 20
+
+type NDJSONReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxNDJSONLine)
+	return &NDJSONReader{s: s}
+}
+
+func (n *NDJSONReader) Read() (*Record, error) {
+	for n.s.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		rec := &Record{Line: n.line}
+		if err := json.Unmarshal(data, &rec.Book); err != nil {
+			rec.Err = fmt.Errorf("invalid JSON: %v", err)
+		}
+		return rec, nil
+	}
+	if err := n.s.Err(); err != nil {
+		return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, n.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+type NDJSONWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	bw := bufio.NewWriter(w)
+	return &NDJSONWriter{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (n *NDJSONWriter) Write(book *models.Book) error {
+	return n.enc.Encode(book)
+}
+
+func (n *NDJSONWriter) Flush() error {
+	return n.w.Flush()
+}
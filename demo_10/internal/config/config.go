@@ -0,0 +1,426 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML file, environment variables and command-line flags, in that order of
+// precedence from lowest to highest.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+	"book-service/internal/service"
+	"book-service/pkg/logging"
+	"book-service/pkg/tracing"
+)
+
+type Config struct {
+	// Storage is "postgres" or "memory".
+	Storage   string          `yaml:"storage"`
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Redis     RedisConfig     `yaml:"redis"`
+	Cache     CacheConfig     `yaml:"cache"`
+	HTTPCache HTTPCacheConfig `yaml:"http_cache"`
+	Admin     AdminConfig     `yaml:"admin"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Trash     TrashConfig     `yaml:"trash"`
+	Lending   LendingConfig   `yaml:"lending"`
+	Fines     FinesConfig     `yaml:"fines"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+}
+
+type ServerConfig struct {
+	Port              int      `yaml:"port"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout"`
+	WriteTimeout      Duration `yaml:"write_timeout"`
+	IdleTimeout       Duration `yaml:"idle_timeout"`
+	// ShutdownDrain is how long readiness fails before the listener closes.
+	ShutdownDrain Duration `yaml:"shutdown_drain"`
+	// ShutdownTimeout bounds the wait for in-flight requests.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	// DSN, when set, is used as is and the connection fields below are
+	// ignored.
+	DSN      Secret `yaml:"dsn"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+
+	MaxOpenConns    int      `yaml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time"`
+
+	// QueryTimeout bounds every store operation without its own entry in
+	// QueryTimeouts. Zero means no timeout.
+	QueryTimeout  Duration            `yaml:"query_timeout"`
+	QueryTimeouts map[string]Duration `yaml:"query_timeouts"`
+}
+
+type RedisConfig struct {
+	// Addr enables rate limits and a book cache shared between replicas.
+	Addr     string `yaml:"addr"`
+	Password Secret `yaml:"password"`
+}
+
+type CacheConfig struct {
+	// Enabled puts a read-through cache in front of book lookups. It is
+	// shared between replicas when redis.addr is set.
+	Enabled bool `yaml:"enabled"`
+	// Size is the most books each replica keeps in memory.
+	Size int `yaml:"size"`
+	// LocalTTL bounds how long a replica serves a book from memory if it
+	// misses an invalidation from another replica.
+	LocalTTL Duration `yaml:"local_ttl"`
+	RedisTTL Duration `yaml:"redis_ttl"`
+}
+
+// HTTPCacheConfig holds the Cache-Control sent with successful responses on
+// each read route. An empty value sends none.
+type HTTPCacheConfig struct {
+	Book   string `yaml:"book"`
+	List   string `yaml:"list"`
+	Search string `yaml:"search"`
+}
+
+type AdminConfig struct {
+	// Token, sent in X-Admin-Token, unlocks the trash. Changes made with it
+	// are recorded as "admin". Empty, with no Tokens either, disables admin
+	// access.
+	Token Secret `yaml:"token"`
+	// Tokens gives each admin a token of their own, by name, so that their
+	// changes are recorded as "admin:<name>".
+	Tokens map[string]Secret `yaml:"tokens"`
+}
+
+type AuthConfig struct {
+	// JWTSecret verifies HS256 bearer tokens, whose subject then identifies
+	// who a request acts for. Empty verifies none.
+	JWTSecret Secret `yaml:"jwt_secret"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted books can be restored before the purge
+	// job removes them for good. Zero keeps them forever.
+	Retention     Duration `yaml:"retention"`
+	PurgeInterval Duration `yaml:"purge_interval"`
+}
+
+type LendingConfig struct {
+	// LoanPeriod is how long a member may keep a copy before the loan is
+	// overdue.
+	LoanPeriod Duration `yaml:"loan_period"`
+	// PickupWindow is how long a holder has to collect a copy set aside for
+	// them before it passes to the next in the queue, which the hold sweep
+	// does every HoldSweepInterval.
+	PickupWindow      Duration `yaml:"pickup_window"`
+	HoldSweepInterval Duration `yaml:"hold_sweep_interval"`
+}
+
+// FinesConfig prices overdue loans. Amounts are in cents.
+type FinesConfig struct {
+	// DailyRate is charged for each overdue day the library is open, once
+	// the first GraceDays of them have passed.
+	DailyRate int `yaml:"daily_rate"`
+	GraceDays int `yaml:"grace_days"`
+	// Caps limits the fine on a single loan by member type. Types left out
+	// are not capped.
+	Caps map[string]int `yaml:"caps"`
+	// TimeZone is the library's, in which overdue days and closure days are
+	// counted.
+	TimeZone        string   `yaml:"time_zone"`
+	AccrualInterval Duration `yaml:"accrual_interval"`
+}
+
+type RateLimitConfig struct {
+	// FailOpen lets requests through when the limit store is unreachable.
+	FailOpen bool  `yaml:"fail_open"`
+	Read     Limit `yaml:"read"`
+	Write    Limit `yaml:"write"`
+	Bulk     Limit `yaml:"bulk"`
+}
+
+// Limit is a token bucket: Rate tokens per second up to Burst.
+type Limit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type MetricsConfig struct {
+	Path   string `yaml:"path"`
+	Prefix string `yaml:"prefix"`
+}
+
+type LoggingConfig struct {
+	Level string `yaml:"level"`
+}
+
+type TracingConfig struct {
+	ServiceName string  `yaml:"service_name"`
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	queryTimeouts := make(map[string]Duration, len(repository.DefaultQueryTimeouts.Operations))
+	for op, d := range repository.DefaultQueryTimeouts.Operations {
+		queryTimeouts[op] = Duration(d)
+	}
+
+	return Config{
+		Storage: "postgres",
+		Server: ServerConfig{
+			Port:              8080,
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(60 * time.Second),
+			IdleTimeout:       Duration(120 * time.Second),
+			ShutdownDrain:     Duration(5 * time.Second),
+			ShutdownTimeout:   Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			Password:        "postgres",
+			Name:            "bookdb",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+			QueryTimeout:    Duration(repository.DefaultQueryTimeouts.Default),
+			QueryTimeouts:   queryTimeouts,
+		},
+		Cache: CacheConfig{
+			Enabled:  true,
+			Size:     repository.DefaultBookCacheSize,
+			LocalTTL: Duration(repository.DefaultBookCacheLocalTTL),
+			RedisTTL: Duration(repository.DefaultRedisBookCacheTTL),
+		},
+		HTTPCache: HTTPCacheConfig{
+			Book:   "public, max-age=30",
+			List:   "public, max-age=5",
+			Search: "public, max-age=5",
+		},
+		Trash: TrashConfig{
+			Retention:     Duration(30 * 24 * time.Hour),
+			PurgeInterval: Duration(time.Hour),
+		},
+		Lending: LendingConfig{
+			LoanPeriod:        Duration(21 * 24 * time.Hour),
+			PickupWindow:      Duration(3 * 24 * time.Hour),
+			HoldSweepInterval: Duration(5 * time.Minute),
+		},
+		Fines: FinesConfig{
+			DailyRate:       25,
+			GraceDays:       1,
+			Caps:            map[string]int{"standard": 1000, "student": 500, "senior": 500},
+			TimeZone:        "UTC",
+			AccrualInterval: Duration(time.Hour),
+		},
+		RateLimit: RateLimitConfig{
+			FailOpen: true,
+			Read:     Limit{Rate: 50, Burst: 100},
+			Write:    Limit{Rate: 10, Burst: 20},
+			Bulk:     Limit{Rate: 0.2, Burst: 2},
+		},
+		Metrics: MetricsConfig{Path: "/metrics", Prefix: "book"},
+		Logging: LoggingConfig{Level: "info"},
+		Tracing: TracingConfig{ServiceName: "book-service", Exporter: tracing.ExporterNone, SampleRatio: 1},
+	}
+}
+
+// ConnString returns DSN when set and otherwise builds a postgres:// URL
+// from the connection fields.
+func (c DatabaseConfig) ConnString() string {
+	if c.DSN != "" {
+		return c.DSN.Value()
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password.Value()),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     "/" + c.Name,
+		RawQuery: url.Values{"sslmode": {c.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// Policy is the fine policy the settings describe. The time zone must have
+// passed Validate.
+func (c FinesConfig) Policy() service.FinePolicy {
+	caps := make(map[models.MemberType]int, len(c.Caps))
+	for t, limit := range c.Caps {
+		caps[models.MemberType(t)] = limit
+	}
+	loc, _ := time.LoadLocation(c.TimeZone)
+	return service.FinePolicy{DailyRate: c.DailyRate, GraceDays: c.GraceDays, Caps: caps, Location: loc}
+}
+
+// Actors maps the name each admin's changes are recorded under to their
+// token.
+func (c AdminConfig) Actors() map[string]string {
+	actors := make(map[string]string, len(c.Tokens)+1)
+	if c.Token != "" {
+		actors["admin"] = c.Token.Value()
+	}
+	for name, token := range c.Tokens {
+		actors["admin:"+name] = token.Value()
+	}
+	return actors
+}
+
+// Timeouts converts the query timeouts for the repository.
+func (c DatabaseConfig) Timeouts() repository.QueryTimeouts {
+	ops := make(map[string]time.Duration, len(c.QueryTimeouts))
+	for op, d := range c.QueryTimeouts {
+		ops[op] = d.Std()
+	}
+	return repository.QueryTimeouts{Default: c.QueryTimeout.Std(), Operations: ops}
+}
+
+var (
+	storages        = []string{"postgres", "memory"}
+	sslModes        = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	traceExporters  = []string{"", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout}
+	adminName       = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	storeOperations = []string{"CreateBook", "GetBookByID", "ListBooks", "SearchBooks", "UpdateBook", "DeleteBook", "UpsertBooks", "ExportBooks", "LastModified", "RestoreBook", "PurgeDeletedBooks", "BookHistory", "RevertBook", "CreateAuthor", "GetAuthor", "ListAuthors", "UpdateAuthor", "DeleteAuthor", "CreateMember", "GetMember", "AddCopy", "ListCopies", "Checkout", "ReturnLoan", "GetLoan", "ListLoans", "Reserve", "GetHold", "CancelHold", "ListHolds", "ExpireHolds", "OverdueLoans", "AssessFine", "GetFine", "ListFines", "PayFine", "AddClosure", "ListClosures", "DeleteClosure"}
+)
+
+// Validate reports every problem at once rather than stopping at the first.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains(storages, c.Storage), "storage: %q is not one of %v", c.Storage, storages)
+
+	s := c.Server
+	check(s.Port > 0 && s.Port <= 65535, "server.port: %d is out of range", s.Port)
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"read_header_timeout", s.ReadHeaderTimeout}, {"read_timeout", s.ReadTimeout}, {"write_timeout", s.WriteTimeout},
+		{"idle_timeout", s.IdleTimeout}, {"shutdown_drain", s.ShutdownDrain}, {"shutdown_timeout", s.ShutdownTimeout},
+	} {
+		check(d.value >= 0, "server.%s: must not be negative", d.name)
+	}
+
+	seen := map[Secret]bool{c.Admin.Token: c.Admin.Token != ""}
+	for _, name := range slices.Sorted(maps.Keys(c.Admin.Tokens)) {
+		token := c.Admin.Tokens[name]
+		check(adminName.MatchString(name), "admin.tokens: %q is not a name of letters, digits, '.', '_' and '-'", name)
+		check(token != "", "admin.tokens.%s: must not be empty", name)
+		check(!seen[token], "admin.tokens.%s: is the same as another admin token", name)
+		seen[token] = token != ""
+	}
+
+	if c.Storage == "postgres" {
+		db := c.Database
+		if db.DSN == "" {
+			check(db.Host != "", "database.host: is required")
+			check(db.Port > 0 && db.Port <= 65535, "database.port: %d is out of range", db.Port)
+			check(db.User != "", "database.user: is required")
+			check(db.Name != "", "database.name: is required")
+			check(slices.Contains(sslModes, db.SSLMode), "database.sslmode: %q is not one of %v", db.SSLMode, sslModes)
+		}
+		check(db.MaxOpenConns >= 0, "database.max_open_conns: must not be negative")
+		check(db.MaxIdleConns >= 0, "database.max_idle_conns: must not be negative")
+		check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns,
+			"database.max_idle_conns: %d exceeds max_open_conns %d", db.MaxIdleConns, db.MaxOpenConns)
+		check(db.ConnMaxLifetime >= 0, "database.conn_max_lifetime: must not be negative")
+		check(db.ConnMaxIdleTime >= 0, "database.conn_max_idle_time: must not be negative")
+		check(db.QueryTimeout >= 0, "database.query_timeout: must not be negative")
+		for _, op := range slices.Sorted(maps.Keys(db.QueryTimeouts)) {
+			check(slices.Contains(storeOperations, op), "database.query_timeouts: unknown operation %q", op)
+			check(db.QueryTimeouts[op] >= 0, "database.query_timeouts.%s: must not be negative", op)
+		}
+	}
+
+	if c.Cache.Enabled {
+		check(c.Cache.Size >= 1, "cache.size: must be at least 1")
+		check(c.Cache.LocalTTL > 0, "cache.local_ttl: must be positive")
+		check(c.Cache.RedisTTL > 0, "cache.redis_ttl: must be positive")
+	}
+
+	check(c.Trash.Retention >= 0, "trash.retention: must not be negative")
+	check(c.Trash.Retention == 0 || c.Trash.PurgeInterval > 0, "trash.purge_interval: must be positive")
+
+	check(c.Lending.LoanPeriod > 0, "lending.loan_period: must be positive")
+	check(c.Lending.PickupWindow > 0, "lending.pickup_window: must be positive")
+	check(c.Lending.HoldSweepInterval > 0, "lending.hold_sweep_interval: must be positive")
+
+	f := c.Fines
+	check(f.DailyRate >= 0, "fines.daily_rate: must not be negative")
+	check(f.GraceDays >= 0, "fines.grace_days: must not be negative")
+	for _, t := range slices.Sorted(maps.Keys(f.Caps)) {
+		check(slices.Contains(models.MemberTypes, models.MemberType(t)), "fines.caps: %q is not one of %v", t, models.MemberTypes)
+		check(f.Caps[t] >= 0, "fines.caps.%s: must not be negative", t)
+	}
+	_, tzErr := time.LoadLocation(f.TimeZone)
+	check(tzErr == nil, "fines.time_zone: %v", tzErr)
+	check(f.AccrualInterval > 0, "fines.accrual_interval: must be positive")
+
+	for _, l := range []struct {
+		name  string
+		limit Limit
+	}{{"read", c.RateLimit.Read}, {"write", c.RateLimit.Write}, {"bulk", c.RateLimit.Bulk}} {
+		check(l.limit.Rate > 0, "rate_limit.%s.rate: must be positive", l.name)
+		check(l.limit.Burst >= 1, "rate_limit.%s.burst: must be at least 1", l.name)
+	}
+
+	check(len(c.Metrics.Path) > 1 && c.Metrics.Path[0] == '/', "metrics.path: %q must start with / and not be the root", c.Metrics.Path)
+
+	_, err := logging.ParseLevel(c.Logging.Level)
+	check(err == nil, "logging.level: %q is not one of debug, info, warn, error", c.Logging.Level)
+
+	check(slices.Contains(traceExporters, c.Tracing.Exporter), "tracing.exporter: %q is not one of otlp, stdout, none", c.Tracing.Exporter)
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio: %v is not between 0 and 1", c.Tracing.SampleRatio)
+
+	return errors.Join(errs...)
+}
+
+// LogLevel returns the parsed logging level. Validate has already rejected
+// unknown levels.
+func (c *Config) LogLevel() slog.Level {
+	level, _ := logging.ParseLevel(c.Logging.Level)
+	return level
+}
+
+// Print writes the effective configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func envFunc(env map[string]string) func(string) string {
+	return func(name string) string { return env[name] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+  write_timeout: 90s
+database:
+  host: db.internal
+  max_open_conns: 50
+  query_timeouts:
+    SearchBooks: 2s
+rate_limit:
+  read: {rate: 5, burst: 10}
+`)
+
+	cfg, opts, err := Load(
+		[]string{"--config", file, "--port", "9100", "migrate", "up"},
+		envFunc(map[string]string{
+			"SERVER_PORT":       "9050",
+			"DB_HOST":           "db.env",
+			"DB_QUERY_TIMEOUTS": "ListBooks=1s",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Server.Port != 9100 {
+		t.Errorf("port = %d, want the flag's 9100", cfg.Server.Port)
+	}
+	if cfg.Database.Host != "db.env" {
+		t.Errorf("db host = %q, want the environment's db.env", cfg.Database.Host)
+	}
+	if cfg.Database.MaxOpenConns != 50 || cfg.Server.WriteTimeout.Std() != 90*time.Second {
+		t.Errorf("file values not applied: max_open_conns = %d, write_timeout = %v", cfg.Database.MaxOpenConns, cfg.Server.WriteTimeout)
+	}
+	if cfg.RateLimit.Read != (Limit{Rate: 5, Burst: 10}) || cfg.RateLimit.Write != Default().RateLimit.Write {
+		t.Errorf("rate limits = %+v, want read from file and write defaulted", cfg.RateLimit)
+	}
+	if cfg.Database.User != "postgres" {
+		t.Errorf("db user = %q, want the default", cfg.Database.User)
+	}
+
+	timeouts := cfg.Database.Timeouts()
+	for op, want := range map[string]time.Duration{"SearchBooks": 2 * time.Second, "ListBooks": time.Second, "UpsertBooks": 30 * time.Second, "GetBookByID": 5 * time.Second} {
+		if got := timeouts.For(op); got != want {
+			t.Errorf("timeout for %s = %v, want %v", op, got, want)
+		}
+	}
+
+	if strings.Join(opts.Args, " ") != "migrate up" {
+		t.Errorf("args = %v, want [migrate up]", opts.Args)
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	password := writeFile(t, "db_password", "s3cret\n")
+
+	cfg, _, err := Load(nil, envFunc(map[string]string{"DB_PASSWORD_FILE": password}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Database.Password.Value(); got != "s3cret" {
+		t.Errorf("password = %q, want s3cret without the trailing newline", got)
+	}
+	if !strings.Contains(cfg.Database.ConnString(), "postgres:s3cret@localhost:5432/bookdb") {
+		t.Errorf("ConnString() = %q does not use the password", cfg.Database.ConnString())
+	}
+
+	_, _, err = Load(nil, envFunc(map[string]string{"DB_PASSWORD_FILE": password, "DB_PASSWORD": "other"}))
+	if err == nil || !strings.Contains(err.Error(), "both set") {
+		t.Errorf("Load() with DB_PASSWORD and DB_PASSWORD_FILE error = %v, want both set", err)
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		wantErr []string
+	}{
+		{
+			name:    "every problem reported",
+			args:    []string{"--port", "70000", "--db-sslmode", "sometimes"},
+			env:     map[string]string{"RATE_LIMIT_WRITE_BURST": "0"},
+			wantErr: []string{"server.port", "database.sslmode", "rate_limit.write.burst"},
+		},
+		{
+			name:    "cache bounds",
+			args:    []string{"--cache-size", "0", "--cache-local-ttl", "0s"},
+			wantErr: []string{"cache.size", "cache.local_ttl"},
+		},
+		{
+			name: "disabled cache is not checked",
+			args: []string{"--cache-enabled=false", "--cache-size", "0"},
+		},
+		{
+			name:    "trash purge interval",
+			args:    []string{"--trash-retention", "-1h", "--trash-purge-interval", "0s"},
+			wantErr: []string{"trash.retention", "trash.purge_interval"},
+		},
+		{
+			name:    "lending periods",
+			env:     map[string]string{"LOAN_PERIOD": "0s", "PICKUP_WINDOW": "-1h"},
+			args:    []string{"--hold-sweep-interval", "0s"},
+			wantErr: []string{"lending.loan_period", "lending.pickup_window", "lending.hold_sweep_interval"},
+		},
+		{
+			name:    "fines",
+			env:     map[string]string{"FINE_DAILY_RATE": "-1", "FINE_CAPS": "staff=100,student=-5"},
+			args:    []string{"--fine-time-zone", "Mars/Olympus"},
+			wantErr: []string{"fines.daily_rate", `fines.caps: "staff"`, "fines.caps.student", "fines.time_zone"},
+		},
+		{
+			name:    "admin tokens",
+			env:     map[string]string{"ADMIN_TOKEN": "s3cret", "ADMIN_TOKENS": "alice=s3cret,bob=,c d=x"},
+			wantErr: []string{"admin.tokens.alice: is the same", "admin.tokens.bob: must not be empty", `admin.tokens: "c d"`},
+		},
+		{
+			name:    "malformed cap",
+			env:     map[string]string{"FINE_CAPS": "student"},
+			wantErr: []string{"FINE_CAPS"},
+		},
+		{
+			name:    "malformed value",
+			env:     map[string]string{"DB_MAX_OPEN_CONNS": "lots"},
+			wantErr: []string{"DB_MAX_OPEN_CONNS"},
+		},
+		{
+			name:    "unknown operation timeout",
+			args:    []string{"--db-query-timeouts", "FindBooks=1s"},
+			wantErr: []string{`unknown operation "FindBooks"`},
+		},
+		{
+			name:    "unknown file key",
+			file:    "server:\n  prot: 8080\n",
+			wantErr: []string{"field prot not found"},
+		},
+		{
+			name: "memory storage needs no database",
+			args: []string{"--storage", "memory", "--db-host", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"--config", writeFile(t, "config.yaml", tt.file)}, args...)
+			}
+			_, _, err := Load(args, envFunc(tt.env))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Load() error = nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, _, err := Load([]string{"--db-dsn", "postgres://u:hunter2@db/books"}, envFunc(map[string]string{"REDIS_PASSWORD": "hunter3", "ADMIN_TOKENS": "alice=hunter4", "JWT_SECRET": "hunter5"}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "hunter") {
+		t.Errorf("Print() leaked a secret:\n%s", out)
+	}
+	if !strings.Contains(out, "dsn: '[REDACTED]'") || !strings.Contains(out, "write_timeout: 1m0s") {
+		t.Errorf("Print() output missing redacted dsn or readable durations:\n%s", out)
+	}
+}
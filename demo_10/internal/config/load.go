@@ -0,0 +1,358 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Options are the flags that steer loading rather than configure the
+// service.
+type Options struct {
+	// File is the YAML file that was read, if any.
+	File string
+	// PrintConfig asks for the effective configuration to be printed
+	// instead of starting the service.
+	PrintConfig bool
+	// Args are the arguments left after the flags, such as a subcommand.
+	Args []string
+}
+
+// binding ties one setting to its environment variable and flag. Secrets
+// may also be read from the file named by the variable with a _FILE suffix,
+// as Docker and Kubernetes secrets are mounted.
+type binding struct {
+	env     string
+	flag    string
+	usage   string
+	secret  bool
+	boolean bool
+	set     func(string) error
+}
+
+func bindings(c *Config) []binding {
+	return []binding{
+		{env: "STORAGE", flag: "storage", usage: "storage backend: postgres or memory", set: setString(&c.Storage)},
+
+		{env: "SERVER_PORT", flag: "port", usage: "HTTP listen port", set: setInt(&c.Server.Port)},
+		{env: "SERVER_READ_HEADER_TIMEOUT", flag: "read-header-timeout", usage: "time allowed to read request headers", set: setDuration(&c.Server.ReadHeaderTimeout)},
+		{env: "SERVER_READ_TIMEOUT", flag: "read-timeout", usage: "time allowed to read a whole request", set: setDuration(&c.Server.ReadTimeout)},
+		{env: "SERVER_WRITE_TIMEOUT", flag: "write-timeout", usage: "time allowed to write a response", set: setDuration(&c.Server.WriteTimeout)},
+		{env: "SERVER_IDLE_TIMEOUT", flag: "idle-timeout", usage: "keep-alive idle timeout", set: setDuration(&c.Server.IdleTimeout)},
+		{env: "SHUTDOWN_DRAIN", flag: "shutdown-drain", usage: "time readiness fails before the listener closes", set: setDuration(&c.Server.ShutdownDrain)},
+		{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "time allowed for in-flight requests on shutdown", set: setDuration(&c.Server.ShutdownTimeout)},
+
+		{env: "DB_DSN", flag: "db-dsn", usage: "Postgres connection string, overrides the other db settings", secret: true, set: setSecret(&c.Database.DSN)},
+		{env: "DB_HOST", flag: "db-host", usage: "Postgres host", set: setString(&c.Database.Host)},
+		{env: "DB_PORT", flag: "db-port", usage: "Postgres port", set: setInt(&c.Database.Port)},
+		{env: "DB_USER", flag: "db-user", usage: "Postgres user", set: setString(&c.Database.User)},
+		{env: "DB_PASSWORD", flag: "db-password", usage: "Postgres password", secret: true, set: setSecret(&c.Database.Password)},
+		{env: "DB_NAME", flag: "db-name", usage: "Postgres database", set: setString(&c.Database.Name)},
+		{env: "DB_SSLMODE", flag: "db-sslmode", usage: "Postgres sslmode", set: setString(&c.Database.SSLMode)},
+		{env: "DB_MAX_OPEN_CONNS", flag: "db-max-open-conns", usage: "connection pool size, 0 for unlimited", set: setInt(&c.Database.MaxOpenConns)},
+		{env: "DB_MAX_IDLE_CONNS", flag: "db-max-idle-conns", usage: "idle connections kept in the pool", set: setInt(&c.Database.MaxIdleConns)},
+		{env: "DB_CONN_MAX_LIFETIME", flag: "db-conn-max-lifetime", usage: "maximum age of a pooled connection", set: setDuration(&c.Database.ConnMaxLifetime)},
+		{env: "DB_CONN_MAX_IDLE_TIME", flag: "db-conn-max-idle-time", usage: "maximum idle time of a pooled connection", set: setDuration(&c.Database.ConnMaxIdleTime)},
+		{env: "DB_QUERY_TIMEOUT", flag: "db-query-timeout", usage: "default timeout of a store operation", set: setDuration(&c.Database.QueryTimeout)},
+		{env: "DB_QUERY_TIMEOUTS", flag: "db-query-timeouts", usage: "per-operation timeouts, e.g. SearchBooks=2s,UpsertBooks=1m", set: setDurationMap(&c.Database.QueryTimeouts)},
+
+		{env: "REDIS_ADDR", flag: "redis-addr", usage: "Redis address for shared rate limits and book cache", set: setString(&c.Redis.Addr)},
+		{env: "REDIS_PASSWORD", flag: "redis-password", usage: "Redis password", secret: true, set: setSecret(&c.Redis.Password)},
+
+		{env: "CACHE_ENABLED", flag: "cache-enabled", usage: "cache book lookups", boolean: true, set: setBool(&c.Cache.Enabled)},
+		{env: "CACHE_SIZE", flag: "cache-size", usage: "books cached in memory per replica", set: setInt(&c.Cache.Size)},
+		{env: "CACHE_LOCAL_TTL", flag: "cache-local-ttl", usage: "lifetime of a book cached in memory", set: setDuration(&c.Cache.LocalTTL)},
+		{env: "CACHE_REDIS_TTL", flag: "cache-redis-ttl", usage: "lifetime of a book cached in Redis", set: setDuration(&c.Cache.RedisTTL)},
+
+		{env: "CACHE_CONTROL_BOOK", flag: "cache-control-book", usage: "Cache-Control for a single book", set: setString(&c.HTTPCache.Book)},
+		{env: "CACHE_CONTROL_LIST", flag: "cache-control-list", usage: "Cache-Control for book lists", set: setString(&c.HTTPCache.List)},
+		{env: "CACHE_CONTROL_SEARCH", flag: "cache-control-search", usage: "Cache-Control for search results", set: setString(&c.HTTPCache.Search)},
+
+		{env: "ADMIN_TOKEN", flag: "admin-token", usage: "token that unlocks the trash", secret: true, set: setSecret(&c.Admin.Token)},
+		{env: "ADMIN_TOKENS", flag: "admin-tokens", usage: "per-admin tokens by name, e.g. alice=token1,bob=token2", secret: true, set: setSecretMap(&c.Admin.Tokens)},
+		{env: "JWT_SECRET", flag: "jwt-secret", usage: "HS256 key that verifies bearer tokens", secret: true, set: setSecret(&c.Auth.JWTSecret)},
+		{env: "TRASH_RETENTION", flag: "trash-retention", usage: "how long deleted books can be restored, 0 to keep them", set: setDuration(&c.Trash.Retention)},
+		{env: "TRASH_PURGE_INTERVAL", flag: "trash-purge-interval", usage: "how often expired books are purged", set: setDuration(&c.Trash.PurgeInterval)},
+		{env: "LOAN_PERIOD", flag: "loan-period", usage: "how long a copy is lent before it is overdue", set: setDuration(&c.Lending.LoanPeriod)},
+		{env: "PICKUP_WINDOW", flag: "pickup-window", usage: "how long a copy is kept for the holder it is set aside for", set: setDuration(&c.Lending.PickupWindow)},
+		{env: "HOLD_SWEEP_INTERVAL", flag: "hold-sweep-interval", usage: "how often uncollected holds are expired", set: setDuration(&c.Lending.HoldSweepInterval)},
+		{env: "FINE_DAILY_RATE", flag: "fine-daily-rate", usage: "fine in cents per overdue day", set: setInt(&c.Fines.DailyRate)},
+		{env: "FINE_GRACE_DAYS", flag: "fine-grace-days", usage: "overdue days before fines start", set: setInt(&c.Fines.GraceDays)},
+		{env: "FINE_CAPS", flag: "fine-caps", usage: "per-member-type cap on a loan's fine in cents, e.g. standard=1000,student=500", set: setIntMap(&c.Fines.Caps)},
+		{env: "FINE_TIME_ZONE", flag: "fine-time-zone", usage: "library time zone in which overdue days are counted", set: setString(&c.Fines.TimeZone)},
+		{env: "FINE_ACCRUAL_INTERVAL", flag: "fine-accrual-interval", usage: "how often fines on open loans are brought up to date", set: setDuration(&c.Fines.AccrualInterval)},
+
+		{env: "RATE_LIMIT_FAIL_OPEN", flag: "rate-limit-fail-open", usage: "allow requests when the rate limit store is down", boolean: true, set: setBool(&c.RateLimit.FailOpen)},
+		{env: "RATE_LIMIT_READ_RATE", flag: "rate-limit-read-rate", usage: "read requests per second per client", set: setFloat(&c.RateLimit.Read.Rate)},
+		{env: "RATE_LIMIT_READ_BURST", flag: "rate-limit-read-burst", usage: "read burst per client", set: setInt(&c.RateLimit.Read.Burst)},
+		{env: "RATE_LIMIT_WRITE_RATE", flag: "rate-limit-write-rate", usage: "write requests per second per client", set: setFloat(&c.RateLimit.Write.Rate)},
+		{env: "RATE_LIMIT_WRITE_BURST", flag: "rate-limit-write-burst", usage: "write burst per client", set: setInt(&c.RateLimit.Write.Burst)},
+		{env: "RATE_LIMIT_BULK_RATE", flag: "rate-limit-bulk-rate", usage: "bulk jobs per second per client", set: setFloat(&c.RateLimit.Bulk.Rate)},
+		{env: "RATE_LIMIT_BULK_BURST", flag: "rate-limit-bulk-burst", usage: "bulk job burst per client", set: setInt(&c.RateLimit.Bulk.Burst)},
+
+		{env: "METRICS_PATH", flag: "metrics-path", usage: "path of the Prometheus endpoint", set: setString(&c.Metrics.Path)},
+		{env: "METRICS_PREFIX", flag: "metrics-prefix", usage: "prefix of the request metric names", set: setString(&c.Metrics.Prefix)},
+
+		{env: "LOG_LEVEL", flag: "log-level", usage: "debug, info, warn or error", set: setString(&c.Logging.Level)},
+
+		{env: "OTEL_SERVICE_NAME", flag: "otel-service-name", usage: "service name on exported spans", set: setString(&c.Tracing.ServiceName)},
+		{env: "OTEL_TRACES_EXPORTER", flag: "otel-traces-exporter", usage: "otlp, stdout or none", set: setString(&c.Tracing.Exporter)},
+		{env: "OTEL_EXPORTER_OTLP_ENDPOINT", flag: "otel-endpoint", usage: "OTLP/HTTP collector URL", set: setString(&c.Tracing.Endpoint)},
+		{env: "OTEL_TRACES_SAMPLER_ARG", flag: "otel-sample-ratio", usage: "fraction of new traces to record", set: setFloat(&c.Tracing.SampleRatio)},
+	}
+}
+
+// Load builds the configuration for a process started with args (without
+// the program name). The YAML file comes from --config or CONFIG_FILE.
+// Environment variables override the file and flags override both. Parsing
+// stops at the first non-flag argument; the rest are returned in
+// Options.Args.
+func Load(args []string, getenv func(string) string) (*Config, Options, error) {
+	cfg := Default()
+	binds := bindings(&cfg)
+
+	var opts Options
+	flagValues := make(map[string]string)
+	fs := flag.NewFlagSet("book-app", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.File, "config", getenv("CONFIG_FILE"), "YAML configuration file")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	for _, b := range binds {
+		name := b.flag
+		record := func(v string) error {
+			flagValues[name] = v
+			return nil
+		}
+		if b.boolean {
+			fs.BoolFunc(name, b.usage, record)
+		} else {
+			fs.Func(name, b.usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+	opts.Args = fs.Args()
+
+	if opts.File != "" {
+		if err := loadFile(&cfg, opts.File); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	for _, b := range binds {
+		value, ok, err := lookupEnv(b, getenv)
+		if err != nil {
+			return nil, opts, err
+		}
+		if !ok {
+			continue
+		}
+		if err := b.set(value); err != nil {
+			return nil, opts, fmt.Errorf("%s: %w", b.env, err)
+		}
+	}
+
+	for _, b := range binds {
+		value, ok := flagValues[b.flag]
+		if !ok {
+			continue
+		}
+		if err := b.set(value); err != nil {
+			return nil, opts, fmt.Errorf("--%s: %w", b.flag, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return &cfg, opts, nil
+}
+
+// Usage writes the flag and environment variable reference to w.
+func Usage(w io.Writer) {
+	cfg := Default()
+	fmt.Fprintln(w, "usage: book-app [flags]\n       book-app [flags] migrate up | down | status | to <version>\n\nflags:")
+	fmt.Fprintln(w, "  --config FILE          YAML configuration file (CONFIG_FILE)")
+	fmt.Fprintln(w, "  --print-config         print the effective configuration with secrets redacted and exit")
+	for _, b := range bindings(&cfg) {
+		env := b.env
+		if b.secret {
+			env += ", " + b.env + "_FILE"
+		}
+		fmt.Fprintf(w, "  --%-21s%s (%s)\n", b.flag, b.usage, env)
+	}
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// lookupEnv reads b's variable, or for secrets the file named by its _FILE
+// variant. Setting both is ambiguous and rejected.
+func lookupEnv(b binding, getenv func(string) string) (string, bool, error) {
+	value := getenv(b.env)
+	if !b.secret {
+		return value, value != "", nil
+	}
+
+	path := getenv(b.env + "_FILE")
+	if path == "" {
+		return value, value != "", nil
+	}
+	if value != "" {
+		return "", false, fmt.Errorf("%s and %s_FILE are both set", b.env, b.env)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", b.env, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+func setString(p *string) func(string) error {
+	return func(v string) error {
+		*p = v
+		return nil
+	}
+}
+
+func setSecret(p *Secret) func(string) error {
+	return func(v string) error {
+		*p = Secret(v)
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*p = n
+		return nil
+	}
+}
+
+func setFloat(p *float64) func(string) error {
+	return func(v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*p = f
+		return nil
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*p = b
+		return nil
+	}
+}
+
+func setDuration(p *Duration) func(string) error {
+	return func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = Duration(d)
+		return nil
+	}
+}
+
+// setSecretMap merges comma-separated name=secret pairs into *p. Secrets
+// are taken as they are, without trimming.
+func setSecretMap(p *map[string]Secret) func(string) error {
+	return func(v string) error {
+		if *p == nil {
+			*p = make(map[string]Secret)
+		}
+		for _, entry := range strings.Split(v, ",") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(entry, "=")
+			if !ok {
+				// Never echo the entry: it may be a secret missing its name.
+				return errors.New("an entry is not name=secret")
+			}
+			(*p)[strings.TrimSpace(name)] = Secret(value)
+		}
+		return nil
+	}
+}
+
+// setIntMap merges comma-separated name=integer pairs into *p, like
+// setDurationMap.
+func setIntMap(p *map[string]int) func(string) error {
+	return func(v string) error {
+		if *p == nil {
+			*p = make(map[string]int)
+		}
+		for _, entry := range strings.Split(v, ",") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("%q is not name=integer", entry)
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("%s: %q is not an integer", strings.TrimSpace(name), strings.TrimSpace(value))
+			}
+			(*p)[strings.TrimSpace(name)] = n
+		}
+		return nil
+	}
+}
+
+// setDurationMap merges comma-separated name=duration pairs into *p, so an
+// override for one operation keeps the defaults of the others.
+func setDurationMap(p *map[string]Duration) func(string) error {
+	return func(v string) error {
+		if *p == nil {
+			*p = make(map[string]Duration)
+		}
+		for _, entry := range strings.Split(v, ",") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			name, value, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("%q is not name=duration", entry)
+			}
+			d, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("%s: %w", strings.TrimSpace(name), err)
+			}
+			(*p)[strings.TrimSpace(name)] = Duration(d)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"book-service/internal/models"
+	"book-service/internal/service"
+)
+
+type AuthorHandler struct {
+	service *service.AuthorService
+}
+
+func NewAuthorHandler(service *service.AuthorService) *AuthorHandler {
+	return &AuthorHandler{service: service}
+}
+
+func (h *AuthorHandler) CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	var req models.AuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON author")
+		return
+	}
+
+	author, err := h.service.CreateAuthor(r.Context(), &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(author)
+}
+
+func (h *AuthorHandler) GetAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "author id must be an integer")
+		return
+	}
+
+	author, err := h.service.GetAuthor(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(author)
+}
+
+func (h *AuthorHandler) ListAuthors(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := models.ListAuthorsParams{
+		Name:   q.Get("name"),
+		Cursor: q.Get("cursor"),
+	}
+	var err error
+	if params.Limit, err = intParam(q, "limit"); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+		return
+	}
+
+	page, err := h.service.ListAuthors(r.Context(), params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *AuthorHandler) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "author id must be an integer")
+		return
+	}
+
+	var req models.AuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON author")
+		return
+	}
+
+	author, err := h.service.UpdateAuthor(r.Context(), id, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(author)
+}
+
+func (h *AuthorHandler) DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "author id must be an integer")
+		return
+	}
+
+	if err := h.service.DeleteAuthor(r.Context(), id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuthorBooks lists the books credited to an author. It takes the same
+// filters, sorts and cursors as the book list.
+func (h *AuthorHandler) AuthorBooks(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "author id must be an integer")
+		return
+	}
+
+	params, err := parseListBooksParams(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+		return
+	}
+	if !allowIncludeDeleted(w, r, params) {
+		return
+	}
+
+	page, err := h.service.ListAuthorBooks(r.Context(), id, params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *AuthorHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/api/authors", h.ListAuthors).Methods("GET")
+	router.HandleFunc("/api/authors", h.CreateAuthor).Methods("POST")
+	router.HandleFunc("/api/authors/{id}/books", h.AuthorBooks).Methods("GET")
+	router.HandleFunc("/api/authors/{id}", h.GetAuthor).Methods("GET")
+	router.HandleFunc("/api/authors/{id}", h.UpdateAuthor).Methods("PUT")
+	router.HandleFunc("/api/authors/{id}", h.DeleteAuthor).Methods("DELETE")
+}
@@ -8,152 +8,275 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus"
 
 	"book-service/internal/models"
 	"book-service/internal/service"
+	"book-service/pkg/middlewares"
 )
 
 type BookHandler struct {
 	service *service.BookService
-
-	// Prometheus metrics
-	requestsTotal    prometheus.Counter
-	requestsDuration prometheus.Histogram
 }
 
 func NewBookHandler(service *service.BookService) *BookHandler {
-	requestsTotal := prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "book_requests_total",
-		Help: "Total number of book requests",
-	})
-
-	requestsDuration := prometheus.NewHistogram(prometheus.HistogramOpts{
-		Name: "book_request_duration_seconds",
-		Help: "Duration of book requests in seconds",
-	})
-
-	prometheus.MustRegister(requestsTotal, requestsDuration)
-
-	return &BookHandler{
-		service:          service,
-		requestsTotal:    requestsTotal,
-		requestsDuration: requestsDuration,
-	}
+	return &BookHandler{service: service}
 }
 
 func (h *BookHandler) CreateBook(w http.ResponseWriter, r *http.Request) {
-	defer h.recordMetrics()()
-
 	var req models.CreateBookRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON book")
 		return
 	}
 
-	book, err := h.service.CreateBook(&req)
+	book, err := h.service.CreateBook(r.Context(), &req)
 	if err != nil {
-		http.Error(w, "Failed to create book", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
+	setBookETag(w, book)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(book)
 }
 
 func (h *BookHandler) GetBook(w http.ResponseWriter, r *http.Request) {
-	defer h.recordMetrics()()
-
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
 		return
 	}
 
-	book, err := h.service.GetBookByID(id)
+	book, err := h.service.GetBookByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		writeError(w, r, err)
 		return
 	}
 
+	setBookValidators(w, book)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(book)
 }
 
-func (h *BookHandler) GetAllBooks(w http.ResponseWriter, r *http.Request) {
-	defer h.recordMetrics()()
+func (h *BookHandler) ListBooks(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListBooksParams(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+		return
+	}
+	if !allowIncludeDeleted(w, r, params) {
+		return
+	}
+	if h.catalogNotModified(w, r) {
+		return
+	}
 
-	books, err := h.service.GetAllBooks()
+	page, err := h.service.ListBooks(r.Context(), params)
 	if err != nil {
-		http.Error(w, "Failed to retrieve books", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	randomWaitInterval := time.Duration(rand.Intn(2000)) * time.Millisecond
-	time.Sleep(randomWaitInterval)
+	wait := time.NewTimer(randomWaitInterval)
+	defer wait.Stop()
+	select {
+	case <-wait.C:
+	case <-r.Context().Done():
+		writeError(w, r, r.Context().Err())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	json.NewEncoder(w).Encode(page)
 }
 
-func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
-	defer h.recordMetrics()()
+// allowIncludeDeleted answers 403 when a non-admin asks for trashed books,
+// reporting whether the list may go ahead.
+func allowIncludeDeleted(w http.ResponseWriter, r *http.Request, params models.ListBooksParams) bool {
+	if !params.Filter.IncludeDeleted {
+		return true
+	}
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "include_deleted requires an admin token")
+		return false
+	}
+	// The trash is for admins only; keep it out of shared caches.
+	w.Header().Set("Cache-Control", "private, no-store")
+	return true
+}
+
+func (h *BookHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := models.SearchParams{
+		Query:  q.Get("q"),
+		Lang:   q.Get("lang"),
+		Cursor: q.Get("cursor"),
+	}
+	if params.Query == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, "q is required")
+		return
+	}
+	var err error
+	if params.Limit, err = intParam(q, "limit"); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+		return
+	}
+	if h.catalogNotModified(w, r) {
+		return
+	}
+
+	page, err := h.service.SearchBooks(r.Context(), params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
 
+func (h *BookHandler) UpdateBook(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
 		return
 	}
 
 	var req models.UpdateBookRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON book")
 		return
 	}
 
-	book, err := h.service.UpdateBook(id, &req)
+	ifMatch, conditional := parseIfMatch(r)
+	book, err := h.service.UpdateBook(r.Context(), id, &req, ifMatch)
 	if err != nil {
-		http.Error(w, "Failed to update book", http.StatusInternalServerError)
+		writeError(w, r, preconditionError(err, conditional))
 		return
 	}
 
+	setBookETag(w, book)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(book)
 }
 
 func (h *BookHandler) DeleteBook(w http.ResponseWriter, r *http.Request) {
-	defer h.recordMetrics()()
-
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
 		return
 	}
 
-	err = h.service.DeleteBook(id)
+	ifMatch, conditional := parseIfMatch(r)
+	err = h.service.DeleteBook(r.Context(), id, ifMatch)
 	if err != nil {
-		http.Error(w, "Failed to delete book", http.StatusInternalServerError)
+		writeError(w, r, preconditionError(err, conditional))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *BookHandler) recordMetrics() func() {
-	timer := prometheus.NewTimer(h.requestsDuration)
-	return func() {
-		h.requestsTotal.Inc()
-		timer.ObserveDuration()
+// RestoreBook brings a deleted book back from the trash. Admin only.
+func (h *BookHandler) RestoreBook(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "restoring a book requires an admin token")
+		return
 	}
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+
+	book, err := h.service.RestoreBook(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setBookETag(w, book)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
+}
+
+// BookHistory lists the revisions of a book, newest first. Admin only, since
+// it names who made each change.
+func (h *BookHandler) BookHistory(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "book history requires an admin token")
+		return
+	}
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+
+	q := r.URL.Query()
+	params := models.HistoryParams{Cursor: q.Get("cursor")}
+	if params.Limit, err = intParam(q, "limit"); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+		return
+	}
+
+	page, err := h.service.BookHistory(r.Context(), id, params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// RevertBook sets a book back to an earlier revision. Admin only.
+func (h *BookHandler) RevertBook(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "reverting a book requires an admin token")
+		return
+	}
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+	rev, err := strconv.Atoi(vars["rev"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "revision must be an integer")
+		return
+	}
+
+	ifMatch, conditional := parseIfMatch(r)
+	book, err := h.service.RevertBook(r.Context(), id, rev, ifMatch)
+	if err != nil {
+		writeError(w, r, preconditionError(err, conditional))
+		return
+	}
+
+	setBookETag(w, book)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(book)
 }
 
 func (h *BookHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/api/books", h.GetAllBooks).Methods("GET")
+	router.HandleFunc("/api/books", h.ListBooks).Methods("GET")
 	router.HandleFunc("/api/books", h.CreateBook).Methods("POST")
+	router.HandleFunc("/api/books:import", h.ImportBooks).Methods("POST")
+	router.HandleFunc("/api/books:export", h.ExportBooks).Methods("GET")
+	router.HandleFunc("/api/books/search", h.SearchBooks).Methods("GET")
+	router.HandleFunc("/api/books/{id}:restore", h.RestoreBook).Methods("POST")
+	router.HandleFunc("/api/books/{id}/history", h.BookHistory).Methods("GET")
+	router.HandleFunc("/api/books/{id}/history/{rev}:revert", h.RevertBook).Methods("POST")
 	router.HandleFunc("/api/books/{id}", h.GetBook).Methods("GET")
 	router.HandleFunc("/api/books/{id}", h.UpdateBook).Methods("PUT")
 	router.HandleFunc("/api/books/{id}", h.DeleteBook).Methods("DELETE")
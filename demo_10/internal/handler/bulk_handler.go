@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"book-service/internal/bulk"
+	"book-service/internal/models"
+	"book-service/internal/service"
+	"book-service/pkg/logging"
+)
+
+const (
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeMalformedInput   = "malformed_input"
+)
+
+// ImportBooks handles POST /api/books:import. The body is CSV (text/csv) or
+// NDJSON (application/x-ndjson) and is streamed into the store in batches.
+// ?dry_run=true validates and reports without writing anything.
+func (h *BookHandler) ImportBooks(w http.ResponseWriter, r *http.Request) {
+	format, err := bulk.FormatFromMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMedia, err.Error())
+		return
+	}
+
+	var opts service.ImportOptions
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		if opts.DryRun, err = strconv.ParseBool(raw); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, "dry_run must be true or false")
+			return
+		}
+	}
+
+	// Large files take longer than the server's read and write timeouts
+	// allow for ordinary requests.
+	clearDeadlines(w)
+
+	src, err := bulk.NewReader(format, r.Body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeMalformedInput, err.Error())
+		return
+	}
+
+	report, err := h.service.ImportBooks(r.Context(), src, opts)
+	if errors.Is(err, bulk.ErrMalformed) {
+		written := report.Created + report.Updated
+		if opts.DryRun {
+			written = 0
+		}
+		writeProblem(w, r, http.StatusBadRequest, CodeMalformedInput,
+			fmt.Sprintf("%v; %d books were written before the error", err, written))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ExportBooks handles GET /api/books:export, streaming the whole catalog as
+// CSV or NDJSON. The format comes from ?format= or the Accept header and
+// defaults to NDJSON.
+func (h *BookHandler) ExportBooks(w http.ResponseWriter, r *http.Request) {
+	format := bulk.FormatNDJSON
+	var err error
+	if name := r.URL.Query().Get("format"); name != "" {
+		format, err = bulk.ParseFormat(name)
+	} else if accept := r.Header.Get("Accept"); accept != "" && accept != "*/*" {
+		format, err = bulk.FormatFromMediaType(accept)
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusNotAcceptable, CodeUnsupportedMedia, err.Error())
+		return
+	}
+
+	clearDeadlines(w)
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="books.%s"`, format))
+
+	out, err := bulk.NewWriter(format, w)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Once the first row is written the status is committed, so a failure
+	// part way through can only be logged and the stream cut short.
+	started := false
+	err = h.service.ExportBooks(r.Context(), func(book *models.Book) error {
+		started = true
+		return out.Write(book)
+	})
+	if err != nil && !started {
+		w.Header().Del("Content-Disposition")
+		writeError(w, r, err)
+		return
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("export books failed after the response started", "error", err)
+	}
+}
+
+// clearDeadlines lifts the server's read and write timeouts for a streaming
+// request. Writers that cannot change deadlines, such as test recorders, are
+// left alone.
+func clearDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"book-service/internal/models"
+	"book-service/internal/service"
+	"book-service/pkg/middlewares"
+)
+
+// bookETag is a strong validator derived from the book's version, which
+// changes on every write.
+func bookETag(book *models.Book) string {
+	return `"` + strconv.Itoa(book.Version) + `"`
+}
+
+func setBookETag(w http.ResponseWriter, book *models.Book) {
+	w.Header().Set("ETag", bookETag(book))
+}
+
+// parseIfMatch turns an If-Match header into the versions a write may apply
+// to. present is false when the header is absent, making the write
+// unconditional. "*" matches any existing book, so it also yields no
+// versions. If-Match uses strong comparison, so weak and foreign tags never
+// match; a header made only of those yields a version no book can have.
+func parseIfMatch(r *http.Request) (versions []int, present bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return nil, false
+	}
+	if header == "*" {
+		return nil, true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		if v, ok := parseETagVersion(tag); ok {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		versions = []int{-1}
+	}
+	return versions, true
+}
+
+// preconditionError reports a missing book as a failed precondition when the
+// request carried If-Match, since no current representation can match it.
+func preconditionError(err error, conditional bool) error {
+	if conditional && errors.Is(err, service.ErrNotFound) {
+		return service.ErrPreconditionFailed
+	}
+	return err
+}
+
+// setBookValidators sends the book's ETag and Last-Modified. Conditional
+// GETs are answered by middlewares.NewHTTPCache.
+func setBookValidators(w http.ResponseWriter, book *models.Book) {
+	setBookETag(w, book)
+	middlewares.SetLastModified(w, book.UpdatedAt)
+}
+
+// catalogNotModified sends the catalog's Last-Modified, the validator for
+// lists and searches, and answers 304 when the client's copy is current so
+// the query itself is skipped. It reports whether the response is done.
+func (h *BookHandler) catalogNotModified(w http.ResponseWriter, r *http.Request) bool {
+	modified, err := h.service.LastModified(r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return true
+	}
+	middlewares.SetLastModified(w, modified)
+	if middlewares.NotModified(r, w.Header()) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+func parseETagVersion(tag string) (int, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	v, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"book-service/internal/models"
+	"book-service/internal/service"
+	"book-service/pkg/middlewares"
+)
+
+type FineHandler struct {
+	service *service.FineService
+}
+
+func NewFineHandler(service *service.FineService) *FineHandler {
+	return &FineHandler{service: service}
+}
+
+// MemberFines lists a member's fines, newest first, with their balance.
+func (h *FineHandler) MemberFines(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "member id must be an integer")
+		return
+	}
+	if !actsFor(r, id) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "reading a member's fines requires an admin token or the member's own bearer token")
+		return
+	}
+
+	fines, err := h.service.ListMemberFines(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fines)
+}
+
+func (h *FineHandler) GetFine(w http.ResponseWriter, r *http.Request) {
+	const forbidden = "reading a fine requires an admin token or the member's own bearer token"
+	if !identified(r) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "fine id must be an integer")
+		return
+	}
+
+	fine, err := h.service.GetFine(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !actsFor(r, fine.MemberID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fine)
+}
+
+// PayFine records that a fine's balance was paid at the desk, which only
+// admins can do.
+func (h *FineHandler) PayFine(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "recording a payment requires an admin token")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "fine id must be an integer")
+		return
+	}
+
+	fine, err := h.service.PayFine(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fine)
+}
+
+// ListClosures lists the days the library is closed between from and to.
+func (h *FineHandler) ListClosures(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	closures, err := h.service.ListClosures(r.Context(), q.Get("from"), q.Get("to"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(closures)
+}
+
+func (h *FineHandler) AddClosure(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "adding a closure day requires an admin token")
+		return
+	}
+
+	var req models.Closure
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON closure")
+		return
+	}
+
+	closure, err := h.service.AddClosure(r.Context(), &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(closure)
+}
+
+func (h *FineHandler) DeleteClosure(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "removing a closure day requires an admin token")
+		return
+	}
+
+	if err := h.service.DeleteClosure(r.Context(), mux.Vars(r)["day"]); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *FineHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/api/members/{id}/fines", h.MemberFines).Methods("GET")
+	router.HandleFunc("/api/fines/{id}:pay", h.PayFine).Methods("POST")
+	router.HandleFunc("/api/fines/{id}", h.GetFine).Methods("GET")
+	router.HandleFunc("/api/closures", h.ListClosures).Methods("GET")
+	router.HandleFunc("/api/closures", h.AddClosure).Methods("POST")
+	router.HandleFunc("/api/closures/{day}", h.DeleteClosure).Methods("DELETE")
+}
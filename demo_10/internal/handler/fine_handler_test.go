@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+	"book-service/internal/service"
+	"book-service/pkg/middlewares"
+)
+
+func TestFineAccess(t *testing.T) {
+	checkedOut := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	store := repository.NewMemoryBookStore()
+
+	// Member 1 owes fine 1 on a late loan; member 2 owes nothing.
+	book, err := store.CreateBook(t.Context(), &models.CreateBookRequest{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412})
+	if err != nil {
+		t.Fatalf("CreateBook() error = %v", err)
+	}
+	if _, err := store.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: "DUNE-1"}, checkedOut, checkedOut); err != nil {
+		t.Fatalf("AddCopy() error = %v", err)
+	}
+	var members []*models.Member
+	for _, email := range []string{"paul@arrakis.example", "chani@arrakis.example"} {
+		member, err := store.CreateMember(t.Context(), &models.MemberRequest{Name: "Member", Email: email})
+		if err != nil {
+			t.Fatalf("CreateMember() error = %v", err)
+		}
+		members = append(members, member)
+	}
+	loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: members[0].ID, At: checkedOut, DueAt: checkedOut.AddDate(0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	if _, err := store.AssessFine(t.Context(), models.Assessment{LoanID: loan.ID, MemberID: members[0].ID, Days: 1, Amount: 25, At: checkedOut.AddDate(0, 0, 2)}); err != nil {
+		t.Fatalf("AssessFine() error = %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(middlewares.NewAdminAuth(map[string]string{"admin": "s3cret"}))
+	router.Use(middlewares.NewJWTAuth(jwtSecret))
+	NewFineHandler(service.NewFineService(store, service.FinePolicy{DailyRate: 25})).RegisterRoutes(router)
+
+	runSteps(t, router, []lendingStep{
+		{name: "anonymous fines", caller: "anonymous", method: http.MethodGet, path: "/api/members/1/fines", want: http.StatusForbidden},
+		{name: "another member's fines", caller: "member 2", method: http.MethodGet, path: "/api/members/1/fines", want: http.StatusForbidden},
+		{name: "fines with a forged token", caller: "forged 1", method: http.MethodGet, path: "/api/members/1/fines", want: http.StatusForbidden},
+		{name: "own fines", caller: "member 1", method: http.MethodGet, path: "/api/members/1/fines", want: http.StatusOK},
+		{name: "admin fines", caller: "admin", method: http.MethodGet, path: "/api/members/1/fines", want: http.StatusOK},
+
+		{name: "anonymous fine", caller: "anonymous", method: http.MethodGet, path: "/api/fines/1", want: http.StatusForbidden},
+		{name: "another member's fine", caller: "member 2", method: http.MethodGet, path: "/api/fines/1", want: http.StatusForbidden},
+		{name: "own fine", caller: "member 1", method: http.MethodGet, path: "/api/fines/1", want: http.StatusOK},
+
+		{name: "payment by a member", caller: "member 1", method: http.MethodPost, path: "/api/fines/1:pay", want: http.StatusForbidden},
+		{name: "payment by an admin", caller: "admin", method: http.MethodPost, path: "/api/fines/1:pay", want: http.StatusOK},
+	})
+}
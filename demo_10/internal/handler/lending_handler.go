@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"book-service/internal/models"
+	"book-service/internal/service"
+	"book-service/pkg/middlewares"
+)
+
+type LendingHandler struct {
+	service *service.LendingService
+}
+
+func NewLendingHandler(service *service.LendingService) *LendingHandler {
+	return &LendingHandler{service: service}
+}
+
+// memberSubjectPrefix marks the bearer token subjects of members: a
+// verified token for "member:7" acts for member 7.
+const memberSubjectPrefix = "member:"
+
+// callerMember returns the member whose verified bearer token the request
+// carries.
+func callerMember(r *http.Request) (int, bool) {
+	sub, ok := middlewares.Subject(r.Context())
+	if !ok {
+		return 0, false
+	}
+	rest, ok := strings.CutPrefix(sub, memberSubjectPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(rest)
+	return id, err == nil
+}
+
+// identified reports whether the request carries an admin token or a
+// member's bearer token, and so may act for someone.
+func identified(r *http.Request) bool {
+	_, ok := callerMember(r)
+	return ok || middlewares.IsAdmin(r.Context())
+}
+
+// actsFor reports whether the request may act for the member: admins act
+// for anyone, members only for themselves.
+func actsFor(r *http.Request, memberID int) bool {
+	if middlewares.IsAdmin(r.Context()) {
+		return true
+	}
+	id, ok := callerMember(r)
+	return ok && id == memberID
+}
+
+// CreateMember registers a member. Members are managed by admins.
+func (h *LendingHandler) CreateMember(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "registering a member requires an admin token")
+		return
+	}
+
+	var req models.MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON member")
+		return
+	}
+
+	member, err := h.service.CreateMember(r.Context(), &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (h *LendingHandler) GetMember(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "member id must be an integer")
+		return
+	}
+	if !actsFor(r, id) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "reading a member requires an admin token or the member's own bearer token")
+		return
+	}
+
+	member, err := h.service.GetMember(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// MemberLoans lists a member's loans, newest first, optionally only those
+// with the given status.
+func (h *LendingHandler) MemberLoans(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "member id must be an integer")
+		return
+	}
+	if !actsFor(r, id) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "reading a member's loans requires an admin token or the member's own bearer token")
+		return
+	}
+
+	q := r.URL.Query()
+	params := models.ListLoansParams{
+		MemberID: id,
+		Status:   models.LoanStatus(q.Get("status")),
+		Cursor:   q.Get("cursor"),
+	}
+	switch params.Status {
+	case "", models.LoanActive, models.LoanOverdue, models.LoanReturned:
+	default:
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery,
+			fmt.Sprintf("status must be one of %s, %s or %s", models.LoanActive, models.LoanOverdue, models.LoanReturned))
+		return
+	}
+	if params.Limit, err = intParam(q, "limit"); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+		return
+	}
+
+	page, err := h.service.ListMemberLoans(r.Context(), params)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// AddCopy adds a physical copy of a book to the collection. Copies are
+// managed by admins.
+func (h *LendingHandler) AddCopy(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "adding a copy requires an admin token")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+
+	var req models.CopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON copy")
+		return
+	}
+
+	c, err := h.service.AddCopy(r.Context(), id, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (h *LendingHandler) ListCopies(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+
+	copies, err := h.service.ListCopies(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(copies)
+}
+
+// Checkout lends a copy of the book to a member, the given copy or any one
+// that is free.
+func (h *LendingHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+
+	var req models.CheckoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON checkout")
+		return
+	}
+	if !actsFor(r, req.MemberID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "checking out for a member requires an admin token or the member's own bearer token")
+		return
+	}
+
+	loan, err := h.service.Checkout(r.Context(), id, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (h *LendingHandler) GetLoan(w http.ResponseWriter, r *http.Request) {
+	const forbidden = "reading a loan requires an admin token or the borrower's own bearer token"
+	if !identified(r) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "loan id must be an integer")
+		return
+	}
+
+	loan, err := h.service.GetLoan(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !actsFor(r, loan.MemberID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (h *LendingHandler) ReturnLoan(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "returning a loan requires an admin token")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "loan id must be an integer")
+		return
+	}
+
+	loan, err := h.service.ReturnLoan(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loan)
+}
+
+// Reserve puts a member in the queue for a book whose copies are all out.
+func (h *LendingHandler) Reserve(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+
+	var req models.ReserveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a valid JSON reservation")
+		return
+	}
+	if !actsFor(r, req.MemberID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "reserving for a member requires an admin token or the member's own bearer token")
+		return
+	}
+
+	hold, err := h.service.Reserve(r.Context(), id, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hold)
+}
+
+// BookHolds lists the queue for a book with each holder's position. The
+// queue is for admins only.
+func (h *LendingHandler) BookHolds(w http.ResponseWriter, r *http.Request) {
+	if !middlewares.IsAdmin(r.Context()) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "the hold queue requires an admin token")
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "book id must be an integer")
+		return
+	}
+
+	holds, err := h.service.ListBookHolds(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holds)
+}
+
+func (h *LendingHandler) GetHold(w http.ResponseWriter, r *http.Request) {
+	const forbidden = "reading a hold requires an admin token or the holder's own bearer token"
+	if !identified(r) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "hold id must be an integer")
+		return
+	}
+
+	hold, err := h.service.GetHold(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !actsFor(r, hold.MemberID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
+
+// CancelHold cancels a hold for its holder, or for any holder on an admin's
+// request.
+func (h *LendingHandler) CancelHold(w http.ResponseWriter, r *http.Request) {
+	const forbidden = "cancelling a hold requires an admin token or the holder's own bearer token"
+	if !identified(r) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidID, "hold id must be an integer")
+		return
+	}
+
+	// A hold's holder never changes, so checking it before the cancel is
+	// as good as checking it inside.
+	held, err := h.service.GetHold(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !actsFor(r, held.MemberID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, forbidden)
+		return
+	}
+
+	hold, err := h.service.CancelHold(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
+
+func (h *LendingHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/api/members", h.CreateMember).Methods("POST")
+	router.HandleFunc("/api/members/{id}/loans", h.MemberLoans).Methods("GET")
+	router.HandleFunc("/api/members/{id}", h.GetMember).Methods("GET")
+	router.HandleFunc("/api/books/{id}:checkout", h.Checkout).Methods("POST")
+	router.HandleFunc("/api/books/{id}:reserve", h.Reserve).Methods("POST")
+	router.HandleFunc("/api/books/{id}/holds", h.BookHolds).Methods("GET")
+	router.HandleFunc("/api/books/{id}/copies", h.ListCopies).Methods("GET")
+	router.HandleFunc("/api/books/{id}/copies", h.AddCopy).Methods("POST")
+	router.HandleFunc("/api/loans/{id}:return", h.ReturnLoan).Methods("POST")
+	router.HandleFunc("/api/loans/{id}", h.GetLoan).Methods("GET")
+	router.HandleFunc("/api/holds/{id}:cancel", h.CancelHold).Methods("POST")
+	router.HandleFunc("/api/holds/{id}", h.GetHold).Methods("GET")
+}
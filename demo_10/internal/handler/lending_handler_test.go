@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+	"book-service/internal/service"
+	"book-service/pkg/middlewares"
+)
+
+var jwtSecret = []byte("jwt-s3cret")
+
+// bearer signs a token for sub with secret.
+func bearer(secret []byte, sub string) string {
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(`{"sub":"`+sub+`"}`))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return "Bearer " + unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// callers are the credentials a lending request can carry: none, the admin
+// token, a member's bearer token, or one signed with the wrong secret.
+var callers = map[string]http.Header{
+	"anonymous": {},
+	"admin":     {middlewares.AdminTokenHeader: {"s3cret"}},
+	"member 1":  {"Authorization": {bearer(jwtSecret, "member:1")}},
+	"member 2":  {"Authorization": {bearer(jwtSecret, "member:2")}},
+	"forged 1":  {"Authorization": {bearer([]byte("guess"), "member:1")}},
+}
+
+// newLendingServer serves the lending routes over a memory store holding
+// book 1 with copies 1 and 2 and members 1 and 2.
+func newLendingServer(t *testing.T) http.Handler {
+	t.Helper()
+	store := repository.NewMemoryBookStore()
+	svc := service.NewLendingService(store, service.LendingConfig{})
+
+	book, err := store.CreateBook(t.Context(), &models.CreateBookRequest{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412})
+	if err != nil {
+		t.Fatalf("CreateBook() error = %v", err)
+	}
+	for _, barcode := range []string{"DUNE-1", "DUNE-2"} {
+		if _, err := svc.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: barcode}); err != nil {
+			t.Fatalf("AddCopy() error = %v", err)
+		}
+	}
+	for _, email := range []string{"paul@arrakis.example", "chani@arrakis.example"} {
+		if _, err := svc.CreateMember(t.Context(), &models.MemberRequest{Name: "Member", Email: email}); err != nil {
+			t.Fatalf("CreateMember() error = %v", err)
+		}
+	}
+
+	router := mux.NewRouter()
+	router.Use(middlewares.NewAdminAuth(map[string]string{"admin": "s3cret"}))
+	router.Use(middlewares.NewJWTAuth(jwtSecret))
+	NewLendingHandler(svc).RegisterRoutes(router)
+	return router
+}
+
+type lendingStep struct {
+	name   string
+	caller string
+	method string
+	path   string
+	body   string
+	want   int
+}
+
+// runSteps sends each step's request in order and checks its status.
+func runSteps(t *testing.T, h http.Handler, steps []lendingStep) {
+	t.Helper()
+	for _, st := range steps {
+		t.Run(st.name, func(t *testing.T) {
+			req := httptest.NewRequest(st.method, st.path, strings.NewReader(st.body))
+			for k, v := range callers[st.caller] {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != st.want {
+				t.Errorf("%s %s as %s = %d, want %d: %s", st.method, st.path, st.caller, rec.Code, st.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestLoanAccess(t *testing.T) {
+	h := newLendingServer(t)
+
+	runSteps(t, h, []lendingStep{
+		{name: "anonymous checkout", caller: "anonymous", method: http.MethodPost, path: "/api/books/1:checkout", body: `{"member_id":1}`, want: http.StatusForbidden},
+		{name: "checkout for another member", caller: "member 2", method: http.MethodPost, path: "/api/books/1:checkout", body: `{"member_id":1}`, want: http.StatusForbidden},
+		{name: "checkout with a forged token", caller: "forged 1", method: http.MethodPost, path: "/api/books/1:checkout", body: `{"member_id":1}`, want: http.StatusForbidden},
+		{name: "own checkout", caller: "member 1", method: http.MethodPost, path: "/api/books/1:checkout", body: `{"member_id":1}`, want: http.StatusCreated},
+		{name: "admin checkout", caller: "admin", method: http.MethodPost, path: "/api/books/1:checkout", body: `{"member_id":2}`, want: http.StatusCreated},
+
+		{name: "anonymous loan", caller: "anonymous", method: http.MethodGet, path: "/api/loans/1", want: http.StatusForbidden},
+		{name: "another member's loan", caller: "member 2", method: http.MethodGet, path: "/api/loans/1", want: http.StatusForbidden},
+		{name: "own loan", caller: "member 1", method: http.MethodGet, path: "/api/loans/1", want: http.StatusOK},
+		{name: "admin loan", caller: "admin", method: http.MethodGet, path: "/api/loans/2", want: http.StatusOK},
+
+		{name: "anonymous member", caller: "anonymous", method: http.MethodGet, path: "/api/members/1", want: http.StatusForbidden},
+		{name: "another member", caller: "member 2", method: http.MethodGet, path: "/api/members/1", want: http.StatusForbidden},
+		{name: "own member", caller: "member 1", method: http.MethodGet, path: "/api/members/1", want: http.StatusOK},
+		{name: "another member's loans", caller: "member 2", method: http.MethodGet, path: "/api/members/1/loans", want: http.StatusForbidden},
+		{name: "own loans", caller: "member 1", method: http.MethodGet, path: "/api/members/1/loans", want: http.StatusOK},
+
+		{name: "return by a member", caller: "member 1", method: http.MethodPost, path: "/api/loans/1:return", want: http.StatusForbidden},
+		{name: "return by an admin", caller: "admin", method: http.MethodPost, path: "/api/loans/1:return", want: http.StatusOK},
+	})
+}
+
+func TestHoldAccess(t *testing.T) {
+	h := newLendingServer(t)
+
+	runSteps(t, h, []lendingStep{
+		{name: "anonymous reserve", caller: "anonymous", method: http.MethodPost, path: "/api/books/1:reserve", body: `{"member_id":1}`, want: http.StatusForbidden},
+		{name: "reserve for another member", caller: "member 2", method: http.MethodPost, path: "/api/books/1:reserve", body: `{"member_id":1}`, want: http.StatusForbidden},
+		{name: "reserve with a forged token", caller: "forged 1", method: http.MethodPost, path: "/api/books/1:reserve", body: `{"member_id":1}`, want: http.StatusForbidden},
+		{name: "own reserve", caller: "member 1", method: http.MethodPost, path: "/api/books/1:reserve", body: `{"member_id":1}`, want: http.StatusCreated},
+		{name: "admin reserve", caller: "admin", method: http.MethodPost, path: "/api/books/1:reserve", body: `{"member_id":2}`, want: http.StatusCreated},
+
+		{name: "anonymous hold", caller: "anonymous", method: http.MethodGet, path: "/api/holds/1", want: http.StatusForbidden},
+		{name: "another member's hold", caller: "member 2", method: http.MethodGet, path: "/api/holds/1", want: http.StatusForbidden},
+		{name: "own hold", caller: "member 1", method: http.MethodGet, path: "/api/holds/1", want: http.StatusOK},
+		{name: "admin hold", caller: "admin", method: http.MethodGet, path: "/api/holds/1", want: http.StatusOK},
+
+		{name: "anonymous cancel", caller: "anonymous", method: http.MethodPost, path: "/api/holds/1:cancel", want: http.StatusForbidden},
+		{name: "cancel another member's hold", caller: "member 2", method: http.MethodPost, path: "/api/holds/1:cancel", want: http.StatusForbidden},
+		{name: "cancel own hold", caller: "member 1", method: http.MethodPost, path: "/api/holds/1:cancel", want: http.StatusOK},
+		{name: "admin cancels a member's hold", caller: "admin", method: http.MethodPost, path: "/api/holds/2:cancel", want: http.StatusOK},
+	})
+}
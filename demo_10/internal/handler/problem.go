@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"book-service/internal/service"
+	"book-service/pkg/logging"
+)
+
+// Problem is an RFC 7807 problem details body. Code is a stable, machine
+// readable identifier clients can branch on.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Code     string `json:"code"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Errors []service.FieldError `json:"errors,omitempty"`
+}
+
+const (
+	CodeInvalidBody  = "invalid_body"
+	CodeInvalidID    = "invalid_id"
+	CodeInvalidQuery = "invalid_query"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodePrecondition = "precondition_failed"
+	CodeValidation   = "validation_failed"
+	CodeUnavailable  = "unavailable"
+	CodeTimeout      = "timeout"
+	CodeClientClosed = "client_closed_request"
+	CodeInternal     = "internal_error"
+)
+
+// StatusClientClosedRequest is the non-standard status nginx introduced for
+// requests the client abandoned before a response was ready. Nobody reads
+// the body; it shows up in access logs and metrics.
+const StatusClientClosedRequest = 499
+
+func newProblem(r *http.Request, status int, code, detail string) Problem {
+	title := http.StatusText(status)
+	if status == StatusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+	return Problem{
+		Type:     "/problems/" + strings.ReplaceAll(code, "_", "-"),
+		Title:    title,
+		Status:   status,
+		Code:     code,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblemBody(w, newProblem(r, status, code, detail))
+}
+
+func writeProblemBody(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeError maps service errors onto problem responses. Anything not
+// recognised is reported as a 500 without leaking the underlying message;
+// the message is logged instead.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		p := newProblem(r, http.StatusUnprocessableEntity, CodeValidation, "one or more fields are invalid")
+		p.Errors = validationErr.Errors
+		writeProblemBody(w, p)
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		writeProblem(w, r, http.StatusConflict, CodeConflict, err.Error())
+	case errors.Is(err, service.ErrPreconditionFailed):
+		writeProblem(w, r, http.StatusPreconditionFailed, CodePrecondition, "the book has been modified since it was last fetched")
+	case errors.Is(err, service.ErrValidation):
+		writeProblem(w, r, http.StatusUnprocessableEntity, CodeValidation, err.Error())
+	case errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrInvalidSort), errors.Is(err, service.ErrInvalidSearch):
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidQuery, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		logging.FromContext(r.Context()).Warn("request timed out", "error", err)
+		writeProblem(w, r, http.StatusGatewayTimeout, CodeTimeout, "the book store did not respond in time")
+	case errors.Is(err, context.Canceled):
+		writeProblem(w, r, StatusClientClosedRequest, CodeClientClosed, "the client closed the request")
+	case errors.Is(err, service.ErrUnavailable):
+		logging.FromContext(r.Context()).Error("book store unavailable", "error", err)
+		w.Header().Set("Retry-After", "5")
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, "the book store is temporarily unavailable")
+	default:
+		logging.FromContext(r.Context()).Error("unhandled error", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "an unexpected error occurred")
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"book-service/internal/service"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "not found", err: service.ErrNotFound, wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "specific not found", err: service.ErrLoanNotFound, wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "conflict", err: service.ErrConflict, wantStatus: http.StatusConflict, wantCode: CodeConflict},
+		{name: "wrapped conflict", err: fmt.Errorf("%w: Rob Pike", service.ErrAuthorAmbiguous), wantStatus: http.StatusConflict, wantCode: CodeConflict},
+		{name: "precondition failed", err: service.ErrPreconditionFailed, wantStatus: http.StatusPreconditionFailed, wantCode: CodePrecondition},
+		{name: "validation", err: service.ErrValidation, wantStatus: http.StatusUnprocessableEntity, wantCode: CodeValidation},
+		{name: "invalid cursor", err: service.ErrInvalidCursor, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidQuery},
+		{name: "invalid sort", err: service.ErrInvalidSort, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidQuery},
+		{name: "invalid search", err: service.ErrInvalidSearch, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidQuery},
+		{name: "timeout", err: context.DeadlineExceeded, wantStatus: http.StatusGatewayTimeout, wantCode: CodeTimeout},
+		{name: "client gone", err: context.Canceled, wantStatus: StatusClientClosedRequest, wantCode: CodeClientClosed},
+		{name: "unavailable", err: service.ErrUnavailable, wantStatus: http.StatusServiceUnavailable, wantCode: CodeUnavailable},
+		{name: "unknown", err: errors.New("pq: password authentication failed"), wantStatus: http.StatusInternalServerError, wantCode: CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, httptest.NewRequest(http.MethodGet, "/api/books/1", nil), tt.err)
+
+			if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", ct)
+			}
+			var p Problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if rec.Code != tt.wantStatus || p.Status != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("writeError() = %d %d %q, want %d %q", rec.Code, p.Status, p.Code, tt.wantStatus, tt.wantCode)
+			}
+			if want := "/problems/" + strings.ReplaceAll(tt.wantCode, "_", "-"); p.Type != want || p.Instance != "/api/books/1" {
+				t.Errorf("writeError() type, instance = %q, %q, want %q, /api/books/1", p.Type, p.Instance, want)
+			}
+			if strings.Contains(p.Detail, "password") {
+				t.Errorf("writeError() detail = %q leaks the underlying error", p.Detail)
+			}
+		})
+	}
+}
+
+func TestWriteErrorValidationFields(t *testing.T) {
+	err := &service.ValidationError{Errors: []service.FieldError{{Field: "isbn", Code: "invalid", Message: "must be a valid ISBN-13"}}}
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodPost, "/api/books", nil), err)
+
+	var p Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity || p.Code != CodeValidation || len(p.Errors) != 1 || p.Errors[0].Field != "isbn" {
+		t.Errorf("writeError() = %d %+v, want 422 listing the isbn field", rec.Code, p)
+	}
+}
+
+func TestWriteErrorUnavailableRetryAfter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/api/books", nil), service.ErrUnavailable)
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After = %q, want 5", got)
+	}
+}
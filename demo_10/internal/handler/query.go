@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"book-service/internal/models"
+)
+
+func parseListBooksParams(q url.Values) (models.ListBooksParams, error) {
+	params := models.ListBooksParams{
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+		Filter: models.BookFilter{
+			Author:     q.Get("author"),
+			ISBNPrefix: q.Get("isbn_prefix"),
+		},
+	}
+
+	var err error
+	if params.Limit, err = intParam(q, "limit"); err != nil {
+		return params, err
+	}
+	if params.Filter.PublishedFrom, err = timeParam(q, "published_from"); err != nil {
+		return params, err
+	}
+	if params.Filter.PublishedTo, err = timeParam(q, "published_to"); err != nil {
+		return params, err
+	}
+	if params.Filter.MinPages, err = intPtrParam(q, "min_pages"); err != nil {
+		return params, err
+	}
+	if params.Filter.MaxPages, err = intPtrParam(q, "max_pages"); err != nil {
+		return params, err
+	}
+	if params.Filter.AuthorID, err = intParam(q, "author_id"); err != nil {
+		return params, err
+	}
+	if raw := q.Get("include_deleted"); raw != "" {
+		if params.Filter.IncludeDeleted, err = strconv.ParseBool(raw); err != nil {
+			return params, fmt.Errorf("invalid include_deleted: must be true or false")
+		}
+	}
+
+	return params, nil
+}
+
+func intParam(q url.Values, name string) (int, error) {
+	v, err := intPtrParam(q, name)
+	if err != nil || v == nil {
+		return 0, err
+	}
+	return *v, nil
+}
+
+func intPtrParam(q url.Values, name string) (*int, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: must be an integer", name)
+	}
+	return &v, nil
+}
+
+// timeParam accepts either a full RFC 3339 timestamp or a plain date.
+func timeParam(q url.Values, name string) (*time.Time, error) {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s: must be a date (YYYY-MM-DD) or RFC 3339 timestamp", name)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"book-service/internal/repository"
+	"book-service/internal/service"
+)
+
+func TestParseListBooksParams(t *testing.T) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	params, err := parseListBooksParams(url.Values{
+		"sort":           {"-published"},
+		"cursor":         {"abc"},
+		"limit":          {"5"},
+		"author":         {"Pike"},
+		"published_from": {"2020-01-01"},
+		"min_pages":      {"0"},
+	})
+	if err != nil {
+		t.Fatalf("parseListBooksParams() error = %v", err)
+	}
+	if params.Sort != "-published" || params.Cursor != "abc" || params.Limit != 5 || params.Filter.Author != "Pike" {
+		t.Errorf("parseListBooksParams() = %+v", params)
+	}
+	if params.Filter.PublishedFrom == nil || !params.Filter.PublishedFrom.Equal(from) {
+		t.Errorf("published_from = %v, want %v", params.Filter.PublishedFrom, from)
+	}
+	if params.Filter.MinPages == nil || *params.Filter.MinPages != 0 || params.Filter.MaxPages != nil {
+		t.Errorf("min_pages, max_pages = %v, %v, want 0 and unset", params.Filter.MinPages, params.Filter.MaxPages)
+	}
+
+	for _, q := range []url.Values{
+		{"limit": {"ten"}},
+		{"published_to": {"yesterday"}},
+		{"max_pages": {"1.5"}},
+		{"include_deleted": {"maybe"}},
+	} {
+		if _, err := parseListBooksParams(q); err == nil {
+			t.Errorf("parseListBooksParams(%v) error = nil, want one", q)
+		}
+	}
+}
+
+func TestListBooksRejectsBadQueries(t *testing.T) {
+	router := mux.NewRouter()
+	NewBookHandler(service.NewBookService(repository.NewMemoryBookStore())).RegisterRoutes(router)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "tampered cursor", query: "cursor=eyJzIjoidGl0bGUifQ"},
+		{name: "garbage cursor", query: "cursor=not-a-cursor"},
+		{name: "unknown sort", query: "sort=isbn"},
+		{name: "limit not a number", query: "limit=ten"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/books?"+tt.query, nil))
+
+			var p Problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if rec.Code != http.StatusBadRequest || p.Code != CodeInvalidQuery {
+				t.Errorf("GET /api/books?%s = %d %q, want %d %q", tt.query, rec.Code, p.Code, http.StatusBadRequest, CodeInvalidQuery)
+			}
+		})
+	}
+}
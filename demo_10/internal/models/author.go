@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type Author struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AuthorRequest struct {
+	Name string `json:"name"`
+}
+
+type ListAuthorsParams struct {
+	// Name keeps authors whose name contains it, ignoring case.
+	Name   string
+	Cursor string
+	Limit  int
+}
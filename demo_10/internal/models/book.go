@@ -2,29 +2,103 @@ package models
 
 import "time"
 
+// Book is a catalog entry. Author is the byline as credited on the book and
+// AuthorIDs are the authors it refers to, in credit order.
 type Book struct {
 	ID        int       `json:"id"`
 	Title     string    `json:"title"`
 	Author    string    `json:"author"`
+	AuthorIDs []int     `json:"author_ids"`
 	ISBN      string    `json:"isbn"`
 	Pages     int       `json:"pages"`
 	Published time.Time `json:"published"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+	// DeletedAt is set while the book is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
+// CreateBookRequest needs Author, AuthorIDs or both. With only Author the
+// authors are looked up, or created, by the names in the byline; with only
+// AuthorIDs the byline is made from their names.
 type CreateBookRequest struct {
 	Title     string    `json:"title"`
 	Author    string    `json:"author"`
+	AuthorIDs []int     `json:"author_ids,omitempty"`
 	ISBN      string    `json:"isbn"`
 	Pages     int       `json:"pages"`
 	Published time.Time `json:"published"`
 }
 
+// UpdateBookRequest keeps Author and AuthorIDs in step like
+// CreateBookRequest when only one of them is set.
 type UpdateBookRequest struct {
 	Title     *string    `json:"title,omitempty"`
 	Author    *string    `json:"author,omitempty"`
+	AuthorIDs *[]int     `json:"author_ids,omitempty"`
 	ISBN      *string    `json:"isbn,omitempty"`
 	Pages     *int       `json:"pages,omitempty"`
 	Published *time.Time `json:"published,omitempty"`
 }
+
+type BookFilter struct {
+	Author        string
+	ISBNPrefix    string
+	PublishedFrom *time.Time
+	PublishedTo   *time.Time
+	MinPages      *int
+	MaxPages      *int
+	// IncludeDeleted lists books in the trash alongside live ones.
+	IncludeDeleted bool
+	// AuthorID, when not zero, keeps books credited to that author.
+	AuthorID int
+}
+
+type ListBooksParams struct {
+	Filter BookFilter
+	Sort   string
+	Cursor string
+	Limit  int
+}
+
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+type UpsertOutcome string
+
+const (
+	UpsertCreated   UpsertOutcome = "created"
+	UpsertUpdated   UpsertOutcome = "updated"
+	UpsertUnchanged UpsertOutcome = "unchanged"
+)
+
+type UpsertResult struct {
+	ISBN    string
+	Outcome UpsertOutcome
+	// ID and Version identify the row written. They are zero for unchanged
+	// books and in dry runs.
+	ID      int
+	Version int
+}
+
+type SearchParams struct {
+	Query  string
+	Lang   string
+	Cursor string
+	Limit  int
+}
+
+type SearchHighlight struct {
+	Title  string `json:"title"`
+	Author string `json:"author"`
+}
+
+type SearchResult struct {
+	Book
+	Rank      float64         `json:"rank"`
+	Highlight SearchHighlight `json:"highlight"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Fine is what a member owes, in cents, for keeping a loan past its due
+// date. While the loan is open the accrual job keeps the fine up to date;
+// once the loan is returned it is assessed a last time and becomes final.
+type Fine struct {
+	ID       int `json:"id"`
+	LoanID   int `json:"loan_id"`
+	MemberID int `json:"member_id"`
+	// Days counts the overdue days charged for.
+	Days       int        `json:"days"`
+	Amount     int        `json:"amount"`
+	Paid       int        `json:"paid"`
+	Final      bool       `json:"final"`
+	AssessedAt time.Time  `json:"assessed_at"`
+	PaidAt     *time.Time `json:"paid_at,omitempty"`
+}
+
+// Balance is what is left to pay.
+func (f *Fine) Balance() int {
+	return f.Amount - f.Paid
+}
+
+// MemberFines lists a member's fines with the total left to pay.
+type MemberFines struct {
+	Data    []Fine `json:"data"`
+	Balance int    `json:"balance"`
+}
+
+// OverdueLoan is a loan that accrues a fine, with the type of the member
+// who borrowed it.
+type OverdueLoan struct {
+	Loan
+	MemberType MemberType
+}
+
+// Assessment is a fine worked out for a loan, as the store records it.
+type Assessment struct {
+	LoanID   int
+	MemberID int
+	Days     int
+	Amount   int
+	Final    bool
+	At       time.Time
+}
+
+// Closure is a day the library is closed. Fines do not accrue on it. Day is
+// a date in the library's time zone, as YYYY-MM-DD.
+type Closure struct {
+	Day    string `json:"day"`
+	Reason string `json:"reason,omitempty"`
+}
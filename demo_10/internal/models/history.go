@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type BookAction string
+
+const (
+	BookCreated  BookAction = "created"
+	BookUpdated  BookAction = "updated"
+	BookDeleted  BookAction = "deleted"
+	BookRestored BookAction = "restored"
+	BookImported BookAction = "imported"
+	BookReverted BookAction = "reverted"
+)
+
+// BookRevision is one entry in a book's history. Revision is the book's
+// version after the change.
+type BookRevision struct {
+	BookID    int        `json:"book_id"`
+	Revision  int        `json:"revision"`
+	Action    BookAction `json:"action"`
+	Actor     string     `json:"actor,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	ChangedAt time.Time  `json:"changed_at"`
+	// Changes holds the old and new value of every field the change
+	// touched, keyed by the field's JSON name. Old values of a new book are
+	// null.
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+type HistoryParams struct {
+	Cursor string
+	Limit  int
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type HoldStatus string
+
+const (
+	HoldWaiting   HoldStatus = "waiting"
+	HoldReady     HoldStatus = "ready"
+	HoldFulfilled HoldStatus = "fulfilled"
+	HoldCancelled HoldStatus = "cancelled"
+	HoldExpired   HoldStatus = "expired"
+)
+
+// Hold is a member's place in the queue for a book. When a copy comes free
+// it is set aside for the first waiting holder, who has until PickupBy to
+// check it out before it passes to the next one.
+type Hold struct {
+	ID       int        `json:"id"`
+	BookID   int        `json:"book_id"`
+	MemberID int        `json:"member_id"`
+	Status   HoldStatus `json:"status"`
+	// Position counts from 1 among the book's waiting holds.
+	Position  int        `json:"position,omitempty"`
+	CopyID    *int       `json:"copy_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadyAt   *time.Time `json:"ready_at,omitempty"`
+	PickupBy  *time.Time `json:"pickup_by,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+}
+
+type ReserveRequest struct {
+	MemberID int `json:"member_id"`
+}
+
+// Reserve is a reservation with its times worked out. PickupBy applies if a
+// copy is free to set aside straight away.
+type Reserve struct {
+	BookID   int
+	MemberID int
+	At       time.Time
+	PickupBy time.Time
+}
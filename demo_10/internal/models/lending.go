@@ -0,0 +1,103 @@
+package models
+
+import "time"
+
+// MemberType sets the cap on a member's fines.
+type MemberType string
+
+const (
+	MemberStandard MemberType = "standard"
+	MemberStudent  MemberType = "student"
+	MemberSenior   MemberType = "senior"
+)
+
+var MemberTypes = []MemberType{MemberStandard, MemberStudent, MemberSenior}
+
+// Member is someone who can borrow copies.
+type Member struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Type      MemberType `json:"type"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// MemberRequest registers a member, as a standard member unless Type says
+// otherwise.
+type MemberRequest struct {
+	Name  string     `json:"name"`
+	Email string     `json:"email"`
+	Type  MemberType `json:"type,omitempty"`
+}
+
+// Copy is one physical copy of a book. OnLoan is set while it is checked
+// out.
+type Copy struct {
+	ID        int       `json:"id"`
+	BookID    int       `json:"book_id"`
+	Barcode   string    `json:"barcode"`
+	OnLoan    bool      `json:"on_loan"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CopyRequest struct {
+	Barcode string `json:"barcode"`
+}
+
+type LoanStatus string
+
+const (
+	LoanActive   LoanStatus = "active"
+	LoanOverdue  LoanStatus = "overdue"
+	LoanReturned LoanStatus = "returned"
+)
+
+// Loan is a copy lent to a member. Status is derived from the dates when the
+// loan is read.
+type Loan struct {
+	ID           int        `json:"id"`
+	CopyID       int        `json:"copy_id"`
+	BookID       int        `json:"book_id"`
+	MemberID     int        `json:"member_id"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty"`
+	Status       LoanStatus `json:"status"`
+}
+
+// StatusAt is the loan's status at now: returned once ReturnedAt is set,
+// otherwise overdue after DueAt.
+func (l *Loan) StatusAt(now time.Time) LoanStatus {
+	switch {
+	case l.ReturnedAt != nil:
+		return LoanReturned
+	case now.After(l.DueAt):
+		return LoanOverdue
+	}
+	return LoanActive
+}
+
+// CheckoutRequest lends a copy of a book to a member. Without CopyID any
+// copy that is not on loan is picked.
+type CheckoutRequest struct {
+	MemberID int `json:"member_id"`
+	CopyID   int `json:"copy_id,omitempty"`
+}
+
+// Checkout is a checkout with its dates worked out, as the store records it.
+type Checkout struct {
+	BookID   int
+	MemberID int
+	CopyID   int
+	At       time.Time
+	DueAt    time.Time
+}
+
+type ListLoansParams struct {
+	MemberID int
+	// Status, when set, keeps only loans in that status at Now.
+	Status LoanStatus
+	Now    time.Time
+	Cursor string
+	Limit  int
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"book-service/internal/models"
+)
+
+const authorColumns = "id, name, created_at, updated_at"
+
+func scanAuthor(row rowScanner) (*models.Author, error) {
+	var author models.Author
+	if err := row.Scan(&author.ID, &author.Name, &author.CreatedAt, &author.UpdatedAt); err != nil {
+		return nil, translateError(err)
+	}
+	return &author, nil
+}
+
+func (r *BookRepository) CreateAuthor(ctx context.Context, req *models.AuthorRequest) (*models.Author, error) {
+	ctx, end := r.startOp(ctx, "CreateAuthor")
+	defer end()
+
+	query := `
+		INSERT INTO authors (name, created_at, updated_at)
+		VALUES ($1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING ` + authorColumns
+	ctx, span := startQuery(ctx, "authors.insert", query)
+	defer span.End()
+
+	author, err := scanAuthor(r.db.QueryRowContext(ctx, query, req.Name))
+	return author, specificError(recordError(ctx, span, err), ErrAuthorNotFound, ErrConflict)
+}
+
+func (r *BookRepository) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	ctx, end := r.startOp(ctx, "GetAuthor")
+	defer end()
+
+	query := `SELECT ` + authorColumns + ` FROM authors WHERE id = $1`
+	ctx, span := startQuery(ctx, "authors.select_by_id", query)
+	defer span.End()
+
+	author, err := scanAuthor(r.db.QueryRowContext(ctx, query, id))
+	return author, specificError(recordError(ctx, span, err), ErrAuthorNotFound, ErrConflict)
+}
+
+func (r *BookRepository) ListAuthors(ctx context.Context, params models.ListAuthorsParams) (*models.Page[models.Author], error) {
+	ctx, end := r.startOp(ctx, "ListAuthors")
+	defer end()
+
+	after, err := decodeAuthorCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{after}
+	query := `SELECT ` + authorColumns + ` FROM authors WHERE id > $1`
+	if params.Name != "" {
+		args = append(args, params.Name)
+		query += fmt.Sprintf(" AND position(lower($%d) in lower(name)) > 0", len(args))
+	}
+	// Fetch one extra row to find out whether another page follows.
+	args = append(args, params.Limit+1)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	ctx, span := startQuery(ctx, "authors.list", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	authors := make([]models.Author, 0, params.Limit+1)
+	for rows.Next() {
+		author, err := scanAuthor(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		authors = append(authors, *author)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return authorPage(authors, params.Limit), nil
+}
+
+func (r *BookRepository) UpdateAuthor(ctx context.Context, id int, req *models.AuthorRequest) (*models.Author, error) {
+	ctx, end := r.startOp(ctx, "UpdateAuthor")
+	defer end()
+
+	query := `
+		UPDATE authors
+		SET name = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING ` + authorColumns
+	ctx, span := startQuery(ctx, "authors.update", query)
+	defer span.End()
+
+	author, err := scanAuthor(r.db.QueryRowContext(ctx, query, req.Name, id))
+	return author, specificError(recordError(ctx, span, err), ErrAuthorNotFound, ErrConflict)
+}
+
+func (r *BookRepository) DeleteAuthor(ctx context.Context, id int) error {
+	ctx, end := r.startOp(ctx, "DeleteAuthor")
+	defer end()
+
+	// book_authors references authors without ON DELETE CASCADE, so an
+	// author still credited on a book, trashed or not, fails with 23503.
+	query := `DELETE FROM authors WHERE id = $1`
+	ctx, span := startQuery(ctx, "authors.delete", query)
+	defer span.End()
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return specificError(recordError(ctx, span, translateError(err)), ErrAuthorNotFound, ErrAuthorHasBooks)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return recordError(ctx, span, translateError(err))
+	}
+	if n == 0 {
+		return ErrAuthorNotFound
+	}
+	return nil
+}
+
+// creditAuthors resolves credits within tx, creating the authors named in
+// bylines that do not exist yet.
+func creditAuthors(ctx context.Context, tx *sql.Tx, credits []credit) error {
+	return resolveCredits(credits,
+		func(ids []int) (map[int]string, error) { return authorNamesByID(ctx, tx, ids) },
+		func(names []string) (map[string]int, error) { return ensureAuthors(ctx, tx, names) },
+	)
+}
+
+func authorNamesByID(ctx context.Context, tx *sql.Tx, ids []int) (map[int]string, error) {
+	query := `SELECT id, name FROM authors WHERE id = ANY($1)`
+	ctx, span := startQuery(ctx, "authors.select_by_ids", query)
+	defer span.End()
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(int64s(ids)))
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	names := make(map[int]string, len(ids))
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, recordError(ctx, span, translateError(err))
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return names, nil
+}
+
+// ensureAuthors returns the IDs of the named authors, inserting the missing
+// ones, and fails with ErrAuthorAmbiguous for a name more than one author
+// goes by. Names are not unique, so writers crediting the same name take a
+// lock on it until they commit, in a fixed order, rather than both finding
+// it missing. The lookup runs as its own statement so that it sees authors
+// the writer it waited for committed.
+func ensureAuthors(ctx context.Context, tx *sql.Tx, names []string) (map[string]int, error) {
+	lock := `
+		SELECT pg_advisory_xact_lock(key)
+		FROM (SELECT DISTINCT hashtext('authors.name:' || name) AS key FROM unnest($1::text[]) AS name ORDER BY key) keys`
+	qctx, span := startQuery(ctx, "authors.lock_names", lock)
+	_, err := tx.ExecContext(qctx, lock, pq.Array(names))
+	recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	insert := `
+		INSERT INTO authors (name, created_at, updated_at)
+		SELECT missing.name, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM unnest($1::text[]) AS missing (name)
+		WHERE NOT EXISTS (SELECT 1 FROM authors WHERE authors.name = missing.name)`
+	qctx, span = startQuery(ctx, "authors.insert_missing", insert)
+	_, err = tx.ExecContext(qctx, insert, pq.Array(names))
+	recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	query := `SELECT id, name FROM authors WHERE name = ANY($1)`
+	ctx, span = startQuery(ctx, "authors.select_by_names", query)
+	defer span.End()
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(names))
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int, len(names))
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, recordError(ctx, span, translateError(err))
+		}
+		if _, ok := ids[name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrAuthorAmbiguous, name)
+		}
+		ids[name] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return ids, nil
+}
+
+// setBookAuthors replaces the authors credited on each book with the given
+// IDs, in order.
+func setBookAuthors(ctx context.Context, tx *sql.Tx, authors map[int][]int) error {
+	if len(authors) == 0 {
+		return nil
+	}
+
+	var books, bookIDs, authorIDs, positions []int64
+	for bookID, ids := range authors {
+		books = append(books, int64(bookID))
+		for i, id := range ids {
+			bookIDs = append(bookIDs, int64(bookID))
+			authorIDs = append(authorIDs, int64(id))
+			positions = append(positions, int64(i))
+		}
+	}
+
+	query := `DELETE FROM book_authors WHERE book_id = ANY($1)`
+	qctx, span := startQuery(ctx, "book_authors.delete", query)
+	_, err := tx.ExecContext(qctx, query, pq.Array(books))
+	recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil || len(bookIDs) == 0 {
+		return translateError(err)
+	}
+
+	query = `
+		INSERT INTO book_authors (book_id, author_id, position)
+		SELECT * FROM unnest($1::int[], $2::int[], $3::int[])`
+	ctx, span = startQuery(ctx, "book_authors.insert", query)
+	defer span.End()
+
+	_, err = tx.ExecContext(ctx, query, pq.Array(bookIDs), pq.Array(authorIDs), pq.Array(positions))
+	return recordError(ctx, span, translateError(err))
+}
+
+func authorPage(authors []models.Author, limit int) *models.Page[models.Author] {
+	page := &models.Page[models.Author]{Data: authors}
+	if len(authors) > limit {
+		page.Data = authors[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeAuthorCursor(page.Data[limit-1].ID)
+	}
+	return page
+}
+
+func int64s(values []int) []int64 {
+	out := make([]int64, len(values))
+	for i, v := range values {
+		out[i] = int64(v)
+	}
+	return out
+}
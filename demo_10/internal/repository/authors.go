@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"book-service/internal/models"
+)
+
+// bylineSeparator splits a byline such as "Kernighan & Ritchie" or
+// "A, B and C" into names. Migration 0007 splits existing bylines with the
+// same expression.
+var bylineSeparator = regexp.MustCompile(`\s*(?:,|;|&|\s+and\s+)\s*`)
+
+// SplitByline returns the distinct names credited in a byline, in order.
+func SplitByline(byline string) []string {
+	var names []string
+	for _, name := range bylineSeparator.Split(strings.TrimSpace(byline), -1) {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return distinct(names)
+}
+
+// credit is what a write says about a book's authors: the byline, the
+// author IDs, or both.
+type credit struct {
+	Author    string
+	AuthorIDs []int
+}
+
+// updateCredit returns the credit an update makes, or false if it leaves the
+// authors alone. next is the book with the update applied; the side of the
+// credit the update does not set is recomputed from the other.
+func updateCredit(next *models.Book, req *models.UpdateBookRequest) (credit, bool) {
+	var c credit
+	if req.Author != nil {
+		c.Author = next.Author
+	}
+	if req.AuthorIDs != nil {
+		c.AuthorIDs = next.AuthorIDs
+	}
+	return c, req.Author != nil || req.AuthorIDs != nil
+}
+
+// importCredit returns the credit an imported row makes. A row that repeats
+// the byline of the book it updates keeps that book's author IDs.
+func importCredit(b *models.CreateBookRequest, existing *models.Book) credit {
+	c := credit{Author: b.Author, AuthorIDs: b.AuthorIDs}
+	if len(c.AuthorIDs) == 0 && existing != nil && existing.Author == b.Author {
+		c.AuthorIDs = existing.AuthorIDs
+	}
+	return c
+}
+
+// resolveCredits fills in whichever half of each credit is missing: the
+// byline from the names of the authors, or the author IDs from the names in
+// the byline, creating authors that do not exist yet. names looks up authors
+// by ID and ensure finds or creates them by name; each is called once for
+// the whole batch.
+func resolveCredits(credits []credit, names func(ids []int) (map[int]string, error), ensure func(names []string) (map[string]int, error)) error {
+	var wantIDs []int
+	var wantNames []string
+	for _, c := range credits {
+		if len(c.AuthorIDs) > 0 {
+			wantIDs = append(wantIDs, c.AuthorIDs...)
+			continue
+		}
+		wantNames = append(wantNames, SplitByline(c.Author)...)
+	}
+
+	var byID map[int]string
+	var byName map[string]int
+	var err error
+	if len(wantIDs) > 0 {
+		if byID, err = names(distinct(wantIDs)); err != nil {
+			return err
+		}
+	}
+	if len(wantNames) > 0 {
+		if byName, err = ensure(distinct(wantNames)); err != nil {
+			return err
+		}
+	}
+
+	for i := range credits {
+		c := &credits[i]
+		if len(c.AuthorIDs) == 0 {
+			c.AuthorIDs = []int{}
+			for _, name := range SplitByline(c.Author) {
+				c.AuthorIDs = append(c.AuthorIDs, byName[name])
+			}
+			continue
+		}
+
+		c.AuthorIDs = distinct(c.AuthorIDs)
+		credited := make([]string, len(c.AuthorIDs))
+		for j, id := range c.AuthorIDs {
+			name, ok := byID[id]
+			if !ok {
+				return fmt.Errorf("%w: author %d does not exist", ErrValidation, id)
+			}
+			credited[j] = name
+		}
+		if c.Author == "" {
+			c.Author = strings.Join(credited, ", ")
+		}
+	}
+	return nil
+}
+
+// distinct drops repeated values, keeping the first of each in order.
+func distinct[T comparable](values []T) []T {
+	seen := make(map[T]bool, len(values))
+	out := make([]T, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// authorCursor pages through authors in id order.
+type authorCursor struct {
+	ID int `json:"i"`
+}
+
+func encodeAuthorCursor(id int) string {
+	data, _ := json.Marshal(authorCursor{ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeAuthorCursor returns the id the next page starts after. An empty
+// cursor starts at the beginning.
+func decodeAuthorCursor(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var c authorCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return c.ID, nil
+}
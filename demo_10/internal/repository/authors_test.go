@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitByline(t *testing.T) {
+	tests := []struct {
+		byline string
+		want   []string
+	}{
+		{byline: "Frank Herbert", want: []string{"Frank Herbert"}},
+		{byline: " Kernighan & Ritchie ", want: []string{"Kernighan", "Ritchie"}},
+		{byline: "Aho, Lam, Sethi and Ullman", want: []string{"Aho", "Lam", "Sethi", "Ullman"}},
+		{byline: "Abelson; Sussman; Abelson", want: []string{"Abelson", "Sussman"}},
+		{byline: "Sandra Andersen", want: []string{"Sandra Andersen"}},
+		{byline: "  ", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.byline, func(t *testing.T) {
+			if got := SplitByline(tt.byline); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitByline(%q) = %q, want %q", tt.byline, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,188 +1,946 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"book-service/internal/models"
 )
 
+// bookColumns reads a book with its author IDs in credit order. In a
+// RETURNING clause the IDs are those committed before the statement.
+const bookColumns = "id, title, author, isbn, pages, published, created_at, updated_at, version, deleted_at, " +
+	"ARRAY(SELECT author_id FROM book_authors WHERE book_id = books.id ORDER BY position)"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook reads bookColumns, followed by any extra columns into extra.
+func scanBook(row rowScanner, extra ...interface{}) (*models.Book, error) {
+	var (
+		book      models.Book
+		authorIDs pq.Int64Array
+	)
+	dest := []interface{}{
+		&book.ID,
+		&book.Title,
+		&book.Author,
+		&book.ISBN,
+		&book.Pages,
+		&book.Published,
+		&book.CreatedAt,
+		&book.UpdatedAt,
+		&book.Version,
+		&book.DeletedAt,
+		&authorIDs,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, translateError(err)
+	}
+	book.AuthorIDs = make([]int, len(authorIDs))
+	for i, id := range authorIDs {
+		book.AuthorIDs[i] = int(id)
+	}
+	return &book, nil
+}
+
+type BookRepositoryConfig struct {
+	// Metrics records query durations. Nil records nothing.
+	Metrics *QueryMetrics
+	// Timeouts bounds each operation. The zero value means no timeouts;
+	// DefaultQueryTimeouts is a sensible start.
+	Timeouts QueryTimeouts
+}
+
+// QueryTimeouts bounds how long a store operation may run before it is
+// cancelled and reported as context.DeadlineExceeded.
+type QueryTimeouts struct {
+	// Default applies to operations without their own entry. Zero means no
+	// timeout.
+	Default time.Duration
+	// Operations overrides Default by operation name, e.g. "ListBooks". Zero
+	// disables the timeout for that operation.
+	Operations map[string]time.Duration
+}
+
+// DefaultQueryTimeouts keeps interactive queries short while leaving room
+// for bulk upserts. Exports stream for as long as the client keeps reading,
+// so they are bounded by the request instead.
+var DefaultQueryTimeouts = QueryTimeouts{
+	Default: 5 * time.Second,
+	Operations: map[string]time.Duration{
+		"UpsertBooks": 30 * time.Second,
+		"ExportBooks": 0,
+	},
+}
+
+// For returns the timeout for op.
+func (t QueryTimeouts) For(op string) time.Duration {
+	if d, ok := t.Operations[op]; ok {
+		return d
+	}
+	return t.Default
+}
+
 type BookRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	metrics  *QueryMetrics
+	timeouts QueryTimeouts
 }
 
-func NewBookRepository(db *sql.DB) *BookRepository {
-	return &BookRepository{db: db}
+// NewBookRepository returns a Postgres-backed store.
+func NewBookRepository(db *sql.DB, cfg BookRepositoryConfig) *BookRepository {
+	return &BookRepository{db: db, metrics: cfg.Metrics, timeouts: cfg.Timeouts}
 }
 
-func (r *BookRepository) CreateBook(book *models.CreateBookRequest) (*models.Book, error) {
+func (r *BookRepository) CreateBook(ctx context.Context, book *models.CreateBookRequest) (*models.Book, error) {
+	ctx, end := r.startOp(ctx, "CreateBook")
+	defer end()
+
 	query := `
 		INSERT INTO books (title, author, isbn, pages, published, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-		RETURNING id, title, author, isbn, pages, published, created_at, updated_at
-	`
+		RETURNING ` + bookColumns
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
 
-	var createdBook models.Book
-	err := r.db.QueryRow(
+	credits := []credit{{Author: book.Author, AuthorIDs: book.AuthorIDs}}
+	if err := creditAuthors(ctx, tx, credits); err != nil {
+		return nil, err
+	}
+
+	qctx, span := startQuery(ctx, "books.insert", query)
+	created, err := scanBook(tx.QueryRowContext(
+		qctx,
 		query,
 		book.Title,
-		book.Author,
+		credits[0].Author,
 		book.ISBN,
 		book.Pages,
 		book.Published,
-	).Scan(
-		&createdBook.ID,
-		&createdBook.Title,
-		&createdBook.Author,
-		&createdBook.ISBN,
-		&createdBook.Pages,
-		&createdBook.Published,
-		&createdBook.CreatedAt,
-		&createdBook.UpdatedAt,
+	))
+	recordError(qctx, span, err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setBookAuthors(ctx, tx, map[int][]int{created.ID: credits[0].AuthorIDs}); err != nil {
+		return nil, err
+	}
+	created.AuthorIDs = credits[0].AuthorIDs
+
+	if err := insertRevisions(ctx, tx, newRevision(ctx, models.BookCreated, nil, created)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return created, nil
+}
+
+func (r *BookRepository) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	ctx, end := r.startOp(ctx, "GetBookByID")
+	defer end()
+
+	query := `SELECT ` + bookColumns + ` FROM books WHERE id = $1 AND deleted_at IS NULL`
+	ctx, span := startQuery(ctx, "books.select_by_id", query)
+	defer span.End()
+
+	book, err := scanBook(r.db.QueryRowContext(ctx, query, id))
+	return book, recordError(ctx, span, err)
+}
+
+func (r *BookRepository) ListBooks(ctx context.Context, params models.ListBooksParams) (*models.Page[models.Book], error) {
+	ctx, end := r.startOp(ctx, "ListBooks")
+	defer end()
+
+	spec, err := parseSort(params.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		conditions []string
+		args       []interface{}
 	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
+	f := params.Filter
+	if !f.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if f.Author != "" {
+		conditions = append(conditions, fmt.Sprintf("position(lower(%s) in lower(author)) > 0", arg(f.Author)))
+	}
+	if f.ISBNPrefix != "" {
+		conditions = append(conditions, fmt.Sprintf("left(isbn, length(%[1]s)) = %[1]s", arg(f.ISBNPrefix)))
+	}
+	if f.AuthorID != 0 {
+		conditions = append(conditions, "id IN (SELECT book_id FROM book_authors WHERE author_id = "+arg(f.AuthorID)+")")
+	}
+	if f.PublishedFrom != nil {
+		conditions = append(conditions, "published >= "+arg(*f.PublishedFrom))
+	}
+	if f.PublishedTo != nil {
+		conditions = append(conditions, "published <= "+arg(*f.PublishedTo))
+	}
+	if f.MinPages != nil {
+		conditions = append(conditions, "pages >= "+arg(*f.MinPages))
+	}
+	if f.MaxPages != nil {
+		conditions = append(conditions, "pages <= "+arg(*f.MaxPages))
+	}
+
+	direction, op := "ASC", ">"
+	if spec.desc {
+		direction, op = "DESC", "<"
+	}
+
+	if params.Cursor != "" {
+		value, id, err := decodeCursor(params.Cursor, spec)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", spec.field.column, op, arg(value), arg(id)))
+	}
+
+	query := `SELECT ` + bookColumns + ` FROM books`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Fetch one extra row to find out whether another page follows.
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT %[3]s", spec.field.column, direction, arg(params.Limit+1))
+
+	ctx, span := startQuery(ctx, "books.list", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	books := make([]models.Book, 0, params.Limit+1)
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		books = append(books, *book)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+
+	page := &models.Page[models.Book]{Data: books}
+	if len(books) > params.Limit {
+		page.Data = books[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(spec, &page.Data[params.Limit-1])
+	}
+
+	return page, nil
+}
+
+func (r *BookRepository) SearchBooks(ctx context.Context, params models.SearchParams) (*models.Page[models.SearchResult], error) {
+	ctx, end := r.startOp(ctx, "SearchBooks")
+	defer end()
+
+	cfg, err := searchConfig(params.Lang)
 	if err != nil {
 		return nil, err
 	}
+	terms := searchTerms(params.Query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: query has no searchable words", ErrInvalidSearch)
+	}
+	tsquery := prefixTSQuery(terms)
+	offset, err := decodeSearchCursor(params.Cursor, tsquery, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	vector := "search_vector"
+	if cfg != DefaultSearchLang {
+		vector = fmt.Sprintf("setweight(to_tsvector('%[1]s', title), 'A') || setweight(to_tsvector('%[1]s', author), 'B')", cfg)
+	}
+	headline := fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", highlightStart, highlightStop)
+
+	// Rank and page in the inner query so ts_headline only runs on the rows
+	// that are returned.
+	query := fmt.Sprintf(`
+		SELECT %[1]s, ranked.rank,
+			ts_headline('%[2]s', title, to_tsquery('%[2]s', $1), $2),
+			ts_headline('%[2]s', author, to_tsquery('%[2]s', $1), $2)
+		FROM (
+			SELECT id AS match_id, ts_rank_cd(%[3]s, q) AS rank
+			FROM books, to_tsquery('%[2]s', $1) AS q
+			WHERE deleted_at IS NULL AND %[3]s @@ q
+			ORDER BY rank DESC, id
+			LIMIT $3 OFFSET $4
+		) ranked
+		JOIN books ON books.id = ranked.match_id
+		ORDER BY ranked.rank DESC, id`, bookColumns, cfg, vector)
+
+	ctx, span := startQuery(ctx, "books.search", query)
+	defer span.End()
 
-	return &createdBook, nil
+	rows, err := r.db.QueryContext(ctx, query, tsquery, headline, params.Limit+1, offset)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	results := make([]models.SearchResult, 0, params.Limit+1)
+	for rows.Next() {
+		var res models.SearchResult
+		book, err := scanBook(rows, &res.Rank, &res.Highlight.Title, &res.Highlight.Author)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		res.Book = *book
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+
+	page := &models.Page[models.SearchResult]{Data: results}
+	if len(results) > params.Limit {
+		page.Data = results[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeSearchCursor(tsquery, cfg, offset+params.Limit)
+	}
+	return page, nil
 }
 
-func (r *BookRepository) GetBookByID(id int) (*models.Book, error) {
-	query := `
-		SELECT id, title, author, isbn, pages, published, created_at, updated_at
-		FROM books WHERE id = $1
-	`
+// UpdateBook applies a partial update inside a transaction. The row is
+// locked while the new values are computed, so concurrent updates serialize
+// instead of overwriting each other. When ifMatch is not empty the update
+// only happens if the current version is one of the listed versions.
+func (r *BookRepository) UpdateBook(ctx context.Context, id int, book *models.UpdateBookRequest, ifMatch []int) (*models.Book, error) {
+	ctx, end := r.startOp(ctx, "UpdateBook")
+	defer end()
 
-	var book models.Book
-	err := r.db.QueryRow(query, id).Scan(
-		&book.ID,
-		&book.Title,
-		&book.Author,
-		&book.ISBN,
-		&book.Pages,
-		&book.Published,
-		&book.CreatedAt,
-		&book.UpdatedAt,
-	)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	current, err := r.lockBook(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !versionMatches(current.Version, ifMatch) {
+		return nil, ErrPreconditionFailed
+	}
+
+	updated, err := writeUpdate(ctx, tx, current, book, models.BookUpdated)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+
+	return updated, nil
+}
 
+// RevertBook sets a live book's fields back to what they were at rev,
+// recording the revert as a new revision.
+func (r *BookRepository) RevertBook(ctx context.Context, id, rev int, ifMatch []int) (*models.Book, error) {
+	ctx, end := r.startOp(ctx, "RevertBook")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	current, err := r.lockBook(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !versionMatches(current.Version, ifMatch) {
+		return nil, ErrPreconditionFailed
+	}
+
+	query := `SELECT snapshot FROM book_history WHERE book_id = $1 AND revision = $2`
+	qctx, span := startQuery(ctx, "book_history.select_snapshot", query)
+	var snapshot []byte
+	err = recordError(qctx, span, translateError(tx.QueryRowContext(qctx, query, id, rev).Scan(&snapshot)))
+	span.End()
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: revision %d does not exist", ErrNotFound, rev)
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("book not found")
+		return nil, err
+	}
+	var state bookState
+	if err := json.Unmarshal(snapshot, &state); err != nil {
+		return nil, fmt.Errorf("decode revision %d: %w", rev, err)
+	}
+
+	reverted, err := writeUpdate(ctx, tx, current, state.updateRequest(), models.BookReverted)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return reverted, nil
+}
+
+// BookHistory lists a book's revisions, newest first.
+func (r *BookRepository) BookHistory(ctx context.Context, id int, params models.HistoryParams) (*models.Page[models.BookRevision], error) {
+	ctx, end := r.startOp(ctx, "BookHistory")
+	defer end()
+
+	below, err := decodeHistoryCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT book_id, revision, action, actor, request_id, changed_at, changes
+		FROM book_history
+		WHERE book_id = $1 AND revision < $2
+		ORDER BY revision DESC
+		LIMIT $3`
+	revisions, err := r.listRevisions(ctx, query, id, below, params.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Books written before history was kept have none, so an empty first
+	// page only means "not found" if the book does not exist either.
+	if len(revisions) == 0 && params.Cursor == "" {
+		query := `SELECT EXISTS (SELECT 1 FROM books WHERE id = $1)`
+		qctx, span := startQuery(ctx, "books.exists", query)
+		var exists bool
+		err := recordError(qctx, span, translateError(r.db.QueryRowContext(qctx, query, id).Scan(&exists)))
+		span.End()
+		if err != nil {
+			return nil, err
 		}
+		if !exists {
+			return nil, ErrNotFound
+		}
+	}
+
+	return historyPage(revisions, params.Limit), nil
+}
+
+// DeleteBook moves a book to the trash, where it stays until RestoreBook
+// brings it back or PurgeDeletedBooks removes it. When ifMatch is not empty
+// the delete only happens if the current version is one of the listed
+// versions.
+func (r *BookRepository) DeleteBook(ctx context.Context, id int, ifMatch []int) error {
+	ctx, end := r.startOp(ctx, "DeleteBook")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	current, err := r.lockBook(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !versionMatches(current.Version, ifMatch) {
+		return ErrPreconditionFailed
+	}
+
+	// Checkouts lock the book row too, so none can start while this looks.
+	onLoan := `SELECT EXISTS (SELECT 1 FROM loans WHERE book_id = $1 AND returned_at IS NULL)`
+	qctx, span := startQuery(ctx, "loans.exists_open_for_book", onLoan)
+	var lent bool
+	err = tx.QueryRowContext(qctx, onLoan, id).Scan(&lent)
+	recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil {
+		return translateError(err)
+	}
+	if lent {
+		return ErrBookOnLoan
+	}
+
+	query := `
+		UPDATE books
+		SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1
+		RETURNING ` + bookColumns
+	qctx, span = startQuery(ctx, "books.soft_delete", query)
+	trashed, err := scanBook(tx.QueryRowContext(qctx, query, id))
+	recordError(qctx, span, err)
+	span.End()
+	if err != nil {
+		return err
+	}
+
+	if err := insertRevisions(ctx, tx, newRevision(ctx, models.BookDeleted, current, trashed)); err != nil {
+		return err
+	}
+	return txError(ctx, tx.Commit())
+}
+
+// RestoreBook takes a book out of the trash. It fails with ErrConflict when a
+// live book has taken its ISBN in the meantime.
+func (r *BookRepository) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	ctx, end := r.startOp(ctx, "RestoreBook")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + bookColumns + ` FROM books WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`
+	qctx, span := startQuery(ctx, "books.select_trashed_for_update", query)
+	trashed, err := scanBook(tx.QueryRowContext(qctx, query, id))
+	recordError(qctx, span, err)
+	span.End()
+	if err != nil {
 		return nil, err
 	}
 
-	return &book, nil
+	query = `
+		UPDATE books
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		WHERE id = $1
+		RETURNING ` + bookColumns
+	qctx, span = startQuery(ctx, "books.restore", query)
+	restored, err := scanBook(tx.QueryRowContext(qctx, query, id))
+	recordError(qctx, span, err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertRevisions(ctx, tx, newRevision(ctx, models.BookRestored, trashed, restored)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return restored, nil
 }
 
-func (r *BookRepository) GetAllBooks() ([]models.Book, error) {
+// PurgeDeletedBooks permanently removes books trashed before cutoff and
+// reports how many it removed.
+func (r *BookRepository) PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error) {
+	ctx, end := r.startOp(ctx, "PurgeDeletedBooks")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	// Books whose copies have been lent stay in the trash, so their loans
+	// and fines are kept. Locking the rest keeps checkouts off them, and
+	// their copies go first.
+	copies := `
+		DELETE FROM copies
+		WHERE book_id IN (
+			SELECT id FROM books
+			WHERE deleted_at < $1 AND NOT EXISTS (SELECT 1 FROM loans WHERE loans.book_id = books.id)
+			FOR UPDATE
+		)`
+	qctx, span := startQuery(ctx, "copies.purge", copies)
+	_, err = tx.ExecContext(qctx, copies, cutoff.UTC())
+	recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil {
+		return 0, translateError(err)
+	}
+
 	query := `
-		SELECT id, title, author, isbn, pages, published, created_at, updated_at
-		FROM books ORDER BY created_at DESC
-	`
+		DELETE FROM books
+		WHERE deleted_at < $1 AND NOT EXISTS (SELECT 1 FROM loans WHERE loans.book_id = books.id)`
+	qctx, span = startQuery(ctx, "books.purge", query)
+	res, err := tx.ExecContext(qctx, query, cutoff.UTC())
+	recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil {
+		return 0, translateError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return int(n), txError(ctx, tx.Commit())
+}
+
+func (r *BookRepository) UpsertBooks(ctx context.Context, books []models.CreateBookRequest, dryRun bool) ([]models.UpsertResult, error) {
+	ctx, end := r.startOp(ctx, "UpsertBooks")
+	defer end()
 
-	rows, err := r.db.Query(query)
+	if len(books) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	// Lock the books about to be updated so their history shows what the
+	// import replaced.
+	var existing map[string]*models.Book
+	if !dryRun {
+		if existing, err = lockBooksByISBN(ctx, tx, books); err != nil {
+			return nil, err
+		}
+	}
+
+	credits := make([]credit, len(books))
+	for i := range books {
+		credits[i] = importCredit(&books[i], existing[books[i].ISBN])
+	}
+	if err := creditAuthors(ctx, tx, credits); err != nil {
+		return nil, err
+	}
+
+	values := make([]string, len(books))
+	args := make([]interface{}, 0, len(books)*5)
+	for i, b := range books {
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)", n+1, n+2, n+3, n+4, n+5)
+		args = append(args, b.Title, credits[i].Author, b.ISBN, b.Pages, b.Published)
+	}
+
+	// Rows whose values already match are left alone and so are missing from
+	// RETURNING; xmax is zero only for freshly inserted rows.
+	query := `
+		INSERT INTO books (title, author, isbn, pages, published, created_at, updated_at)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (isbn) WHERE deleted_at IS NULL DO UPDATE
+		SET title = EXCLUDED.title, author = EXCLUDED.author, pages = EXCLUDED.pages,
+			published = EXCLUDED.published, updated_at = CURRENT_TIMESTAMP, version = books.version + 1
+		WHERE (books.title, books.author, books.pages, books.published)
+			IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.author, EXCLUDED.pages, EXCLUDED.published)
+		RETURNING ` + bookColumns + `, (xmax = 0) AS inserted`
+
+	written, err := upsertOutcomes(ctx, tx, query, args)
 	if err != nil {
 		return nil, err
 	}
+
+	results := make([]models.UpsertResult, len(books))
+	authors := make(map[int][]int, len(written))
+	var revisions []revision
+	for i, b := range books {
+		w, ok := written[b.ISBN]
+		if !ok {
+			results[i] = models.UpsertResult{ISBN: b.ISBN, Outcome: models.UpsertUnchanged}
+			continue
+		}
+		res := models.UpsertResult{ISBN: b.ISBN, Outcome: models.UpsertUpdated, ID: w.book.ID, Version: w.book.Version}
+		if w.inserted {
+			res.Outcome = models.UpsertCreated
+		}
+		if dryRun {
+			res.ID, res.Version = 0, 0
+		} else {
+			w.book.AuthorIDs = credits[i].AuthorIDs
+			authors[w.book.ID] = credits[i].AuthorIDs
+			revisions = append(revisions, newRevision(ctx, models.BookImported, existing[b.ISBN], w.book))
+		}
+		results[i] = res
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	if err := setBookAuthors(ctx, tx, authors); err != nil {
+		return nil, err
+	}
+	if err := insertRevisions(ctx, tx, revisions...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return results, nil
+}
+
+func (r *BookRepository) ExportBooks(ctx context.Context, fn func(book *models.Book) error) error {
+	ctx, end := r.startOp(ctx, "ExportBooks")
+	defer end()
+
+	query := `SELECT ` + bookColumns + ` FROM books WHERE deleted_at IS NULL ORDER BY id`
+	ctx, span := startQuery(ctx, "books.export", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return recordError(ctx, span, translateError(err))
+	}
 	defer rows.Close()
 
-	var books []models.Book
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(
-			&book.ID,
-			&book.Title,
-			&book.Author,
-			&book.ISBN,
-			&book.Pages,
-			&book.Published,
-			&book.CreatedAt,
-			&book.UpdatedAt,
-		)
+		book, err := scanBook(rows)
 		if err != nil {
-			return nil, err
+			return recordError(ctx, span, err)
+		}
+		if err := fn(book); err != nil {
+			return err
 		}
-		books = append(books, book)
 	}
+	return recordError(ctx, span, translateError(rows.Err()))
+}
+
+func (r *BookRepository) LastModified(ctx context.Context) (time.Time, error) {
+	ctx, end := r.startOp(ctx, "LastModified")
+	defer end()
 
-	return books, rows.Err()
+	// Trashed rows count too, so deleting a book moves this forward.
+	query := `SELECT max(updated_at) FROM books`
+	ctx, span := startQuery(ctx, "books.last_modified", query)
+	defer span.End()
+
+	var modified sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query).Scan(&modified); err != nil {
+		return time.Time{}, recordError(ctx, span, translateError(err))
+	}
+	return modified.Time, nil
 }
 
-func (r *BookRepository) UpdateBook(id int, book *models.UpdateBookRequest) (*models.Book, error) {
-	// Get current book first
-	current, err := r.GetBookByID(id)
+func (r *BookRepository) listRevisions(ctx context.Context, query string, args ...interface{}) ([]models.BookRevision, error) {
+	ctx, span := startQuery(ctx, "book_history.list", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, recordError(ctx, span, translateError(err))
 	}
+	defer rows.Close()
 
-	// Apply updates
-	if book.Title != nil {
-		current.Title = *book.Title
+	revisions := make([]models.BookRevision, 0)
+	for rows.Next() {
+		var (
+			rev     models.BookRevision
+			changes []byte
+		)
+		if err := rows.Scan(&rev.BookID, &rev.Revision, &rev.Action, &rev.Actor, &rev.RequestID, &rev.ChangedAt, &changes); err != nil {
+			return nil, recordError(ctx, span, translateError(err))
+		}
+		if err := json.Unmarshal(changes, &rev.Changes); err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return revisions, nil
+}
+
+// lockBook reads a live book and locks its row until tx ends.
+func (r *BookRepository) lockBook(ctx context.Context, tx *sql.Tx, id int) (*models.Book, error) {
+	query := `SELECT ` + bookColumns + ` FROM books WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	ctx, span := startQuery(ctx, "books.select_for_update", query)
+	defer span.End()
+
+	book, err := scanBook(tx.QueryRowContext(ctx, query, id))
+	return book, recordError(ctx, span, err)
+}
+
+// lockBooksByISBN reads and locks the live books with the ISBNs of books
+// until tx ends, keyed by ISBN.
+func lockBooksByISBN(ctx context.Context, tx *sql.Tx, books []models.CreateBookRequest) (map[string]*models.Book, error) {
+	isbns := make([]string, len(books))
+	for i, b := range books {
+		isbns[i] = b.ISBN
+	}
+
+	query := `SELECT ` + bookColumns + ` FROM books WHERE isbn = ANY($1) AND deleted_at IS NULL FOR UPDATE`
+	ctx, span := startQuery(ctx, "books.select_by_isbn_for_update", query)
+	defer span.End()
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(isbns))
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	locked := make(map[string]*models.Book, len(books))
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		locked[book.ISBN] = book
 	}
-	if book.Author != nil {
-		current.Author = *book.Author
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
 	}
-	if book.ISBN != nil {
-		current.ISBN = *book.ISBN
+	return locked, nil
+}
+
+// upsertedBook is a row the upsert statement inserted or updated.
+type upsertedBook struct {
+	book     *models.Book
+	inserted bool
+}
+
+// upsertOutcomes runs the upsert statement and reports the rows it inserted
+// or updated, keyed by ISBN.
+func upsertOutcomes(ctx context.Context, tx *sql.Tx, query string, args []interface{}) (map[string]upsertedBook, error) {
+	ctx, span := startQuery(ctx, "books.upsert", query)
+	defer span.End()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
 	}
-	if book.Pages != nil {
-		current.Pages = *book.Pages
+	defer rows.Close()
+
+	written := make(map[string]upsertedBook)
+	for rows.Next() {
+		var w upsertedBook
+		if w.book, err = scanBook(rows, &w.inserted); err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		written[w.book.ISBN] = w
 	}
-	if book.Published != nil {
-		current.Published = *book.Published
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return written, nil
+}
+
+// writeUpdate applies req to current, a book locked by tx, and records the
+// change under action.
+func writeUpdate(ctx context.Context, tx *sql.Tx, current *models.Book, req *models.UpdateBookRequest, action models.BookAction) (*models.Book, error) {
+	next := *current
+	applyUpdate(&next, req)
+
+	// Books keep their byline and author IDs in step, so setting one
+	// recomputes the other. The authors are replaced before the update so
+	// its RETURNING sees them.
+	if c, ok := updateCredit(&next, req); ok {
+		credits := []credit{c}
+		if err := creditAuthors(ctx, tx, credits); err != nil {
+			return nil, err
+		}
+		next.Author, next.AuthorIDs = credits[0].Author, credits[0].AuthorIDs
+		if err := setBookAuthors(ctx, tx, map[int][]int{current.ID: next.AuthorIDs}); err != nil {
+			return nil, err
+		}
 	}
 
 	query := `
 		UPDATE books
-		SET title = $1, author = $2, isbn = $3, pages = $4, published = $5, updated_at = CURRENT_TIMESTAMP
+		SET title = $1, author = $2, isbn = $3, pages = $4, published = $5,
+			updated_at = CURRENT_TIMESTAMP, version = version + 1
 		WHERE id = $6
-		RETURNING id, title, author, isbn, pages, published, created_at, updated_at
-	`
+		RETURNING ` + bookColumns
 
-	var updatedBook models.Book
-	err = r.db.QueryRow(
+	qctx, span := startQuery(ctx, "books.update", query)
+	updated, err := scanBook(tx.QueryRowContext(
+		qctx,
 		query,
-		current.Title,
-		current.Author,
-		current.ISBN,
-		current.Pages,
-		current.Published,
-		id,
-	).Scan(
-		&updatedBook.ID,
-		&updatedBook.Title,
-		&updatedBook.Author,
-		&updatedBook.ISBN,
-		&updatedBook.Pages,
-		&updatedBook.Published,
-		&updatedBook.CreatedAt,
-		&updatedBook.UpdatedAt,
-	)
-
+		next.Title,
+		next.Author,
+		next.ISBN,
+		next.Pages,
+		next.Published,
+		current.ID,
+	))
+	recordError(qctx, span, err)
+	span.End()
 	if err != nil {
 		return nil, err
 	}
 
-	return &updatedBook, nil
+	if err := insertRevisions(ctx, tx, newRevision(ctx, action, current, updated)); err != nil {
+		return nil, err
+	}
+	return updated, nil
 }
 
-func (r *BookRepository) DeleteBook(id int) error {
-	query := `DELETE FROM books WHERE id = $1`
-	result, err := r.db.Exec(query, id)
-	if err != nil {
-		return err
+// insertRevisions appends revisions to book_history in tx, so they commit or
+// roll back with the writes they describe.
+func insertRevisions(ctx context.Context, tx *sql.Tx, revisions ...revision) error {
+	if len(revisions) == 0 {
+		return nil
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+	values := make([]string, len(revisions))
+	args := make([]interface{}, 0, len(revisions)*8)
+	for i, rev := range revisions {
+		changes, err := json.Marshal(rev.Changes)
+		if err != nil {
+			return err
+		}
+		snapshot, err := json.Marshal(rev.State)
+		if err != nil {
+			return err
+		}
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+		args = append(args, rev.BookID, rev.Revision, string(rev.Action), rev.Actor, rev.RequestID, rev.ChangedAt, string(changes), string(snapshot))
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("book not found")
+	query := `
+		INSERT INTO book_history (book_id, revision, action, actor, request_id, changed_at, changes, snapshot)
+		VALUES ` + strings.Join(values, ", ")
+	ctx, span := startQuery(ctx, "book_history.insert", query)
+	defer span.End()
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return recordError(ctx, span, translateError(err))
+}
+
+func applyUpdate(book *models.Book, req *models.UpdateBookRequest) {
+	if req.Title != nil {
+		book.Title = *req.Title
+	}
+	if req.Author != nil {
+		book.Author = *req.Author
 	}
+	if req.AuthorIDs != nil {
+		book.AuthorIDs = *req.AuthorIDs
+	}
+	if req.ISBN != nil {
+		book.ISBN = *req.ISBN
+	}
+	if req.Pages != nil {
+		book.Pages = *req.Pages
+	}
+	if req.Published != nil {
+		book.Published = *req.Published
+	}
+}
 
-	return nil
+func versionMatches(version int, ifMatch []int) bool {
+	return len(ifMatch) == 0 || slices.Contains(ifMatch, version)
 }
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryTimeoutsFor(t *testing.T) {
+	timeouts := QueryTimeouts{
+		Default:    5 * time.Second,
+		Operations: map[string]time.Duration{"SearchBooks": 2 * time.Second, "ExportBooks": 0},
+	}
+
+	tests := []struct {
+		op   string
+		want time.Duration
+	}{
+		{op: "GetBookByID", want: 5 * time.Second},
+		{op: "SearchBooks", want: 2 * time.Second},
+		{op: "ExportBooks", want: 0},
+	}
+	for _, tt := range tests {
+		if got := timeouts.For(tt.op); got != tt.want {
+			t.Errorf("For(%q) = %v, want %v", tt.op, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+
+	"book-service/internal/models"
+	"book-service/pkg/logging"
+)
+
+const (
+	DefaultBookCacheSize     = 10000
+	DefaultBookCacheLocalTTL = 10 * time.Second
+)
+
+// tombstoneVersion fences a deleted book: no load can outrank it.
+const tombstoneVersion = math.MaxInt32
+
+type BookCacheConfig struct {
+	// Size is the most books the in-process LRU holds. Defaults to
+	// DefaultBookCacheSize.
+	Size int
+	// LocalTTL bounds how long a replica serves a book from its own memory.
+	// Other replicas' writes reach it through Redis, so this only matters
+	// if an invalidation message is lost. Defaults to
+	// DefaultBookCacheLocalTTL.
+	LocalTTL time.Duration
+	// Redis adds a tier shared between replicas and broadcasts
+	// invalidations to their LRUs. Optional.
+	Redis *RedisBookCache
+	// Registerer receives the hit and miss counters. A nil Registerer means
+	// the global registry.
+	Registerer prometheus.Registerer
+}
+
+// CachedBookStore is a read-through cache for GetBookByID in front of another
+// BookStore. Lookups try the in-process LRU, then Redis, then the store, and
+// concurrent misses for the same book share one load.
+//
+// Every cached entry carries the book's Version and is only replaced by a
+// newer one, so a load that raced a write can never overwrite what the write
+// cached. Writes go to the store first and then update the cache: UpdateBook,
+// RevertBook and RestoreBook cache the new book, DeleteBook leaves a tombstone and
+// UpsertBooks fences off the versions it replaced. Lists, searches and
+// exports are not cached.
+type CachedBookStore struct {
+	next    BookStore
+	local   *localBookCache
+	redis   *RedisBookCache
+	group   singleflight.Group
+	metrics *cacheMetrics
+}
+
+// cacheEntry is a book at Version. An entry without a Book is a fence: it
+// keeps any load at or below Version out of the cache.
+type cacheEntry struct {
+	Version int
+	Book    *models.Book
+}
+
+// fence is the highest version e rules out.
+func (e cacheEntry) fence() int {
+	if e.Book != nil {
+		return e.Version - 1
+	}
+	return e.Version
+}
+
+// rank orders entries for setIfNewer: a fence at a version outranks the book
+// at that version, and the next version outranks the fence.
+func (e cacheEntry) rank() int64 {
+	r := int64(e.Version) * 2
+	if e.Book == nil {
+		r++
+	}
+	return r
+}
+
+func NewCachedBookStore(next BookStore, cfg BookCacheConfig) (*CachedBookStore, error) {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultBookCacheSize
+	}
+	if cfg.LocalTTL <= 0 {
+		cfg.LocalTTL = DefaultBookCacheLocalTTL
+	}
+	metrics, err := newCacheMetrics(cfg.Registerer)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedBookStore{
+		next:    next,
+		local:   &localBookCache{lru: expirable.NewLRU[int, cacheEntry](cfg.Size, nil, cfg.LocalTTL)},
+		redis:   cfg.Redis,
+		metrics: metrics,
+	}, nil
+}
+
+// Listen applies invalidations broadcast by other replicas to the local LRU
+// until ctx is done. It returns immediately without a Redis tier.
+func (s *CachedBookStore) Listen(ctx context.Context) {
+	if s.redis == nil {
+		return
+	}
+	s.redis.subscribe(ctx, func(id, fence int) {
+		if fence == 0 {
+			s.local.remove(id)
+			return
+		}
+		s.local.setIfNewer(id, cacheEntry{Version: fence})
+	})
+}
+
+func (s *CachedBookStore) CreateBook(ctx context.Context, book *models.CreateBookRequest) (*models.Book, error) {
+	return s.next.CreateBook(ctx, book)
+}
+
+func (s *CachedBookStore) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	if e, ok := s.local.get(id); ok && e.Book != nil {
+		s.metrics.record(tierLocal, true)
+		return copyBook(e.Book), nil
+	}
+	s.metrics.record(tierLocal, false)
+
+	// The load is shared by everyone waiting on id, so it must not be
+	// cancelled by whichever caller happened to start it.
+	ch := s.group.DoChan(strconv.Itoa(id), func() (interface{}, error) {
+		return s.load(context.WithoutCancel(ctx), id)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return copyBook(res.Val.(*models.Book)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *CachedBookStore) ListBooks(ctx context.Context, params models.ListBooksParams) (*models.Page[models.Book], error) {
+	return s.next.ListBooks(ctx, params)
+}
+
+func (s *CachedBookStore) SearchBooks(ctx context.Context, params models.SearchParams) (*models.Page[models.SearchResult], error) {
+	return s.next.SearchBooks(ctx, params)
+}
+
+func (s *CachedBookStore) UpdateBook(ctx context.Context, id int, req *models.UpdateBookRequest, ifMatch []int) (*models.Book, error) {
+	book, err := s.next.UpdateBook(ctx, id, req, ifMatch)
+	if err != nil {
+		s.discard(ctx, id, err)
+		return nil, err
+	}
+	s.invalidate(ctx, id, cacheEntry{Version: book.Version, Book: book})
+	return copyBook(book), nil
+}
+
+func (s *CachedBookStore) DeleteBook(ctx context.Context, id int, ifMatch []int) error {
+	if err := s.next.DeleteBook(ctx, id, ifMatch); err != nil {
+		s.discard(ctx, id, err)
+		return err
+	}
+	s.invalidate(ctx, id, cacheEntry{Version: tombstoneVersion})
+	return nil
+}
+
+func (s *CachedBookStore) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	book, err := s.next.RestoreBook(ctx, id)
+	if err != nil {
+		s.discard(ctx, id, err)
+		return nil, err
+	}
+	// The tombstone DeleteBook left outranks every version, so it has to go
+	// before the restored book can be cached.
+	s.drop(ctx, id)
+	s.invalidate(ctx, id, cacheEntry{Version: book.Version, Book: book})
+	return copyBook(book), nil
+}
+
+func (s *CachedBookStore) RevertBook(ctx context.Context, id, rev int, ifMatch []int) (*models.Book, error) {
+	book, err := s.next.RevertBook(ctx, id, rev, ifMatch)
+	if err != nil {
+		s.discard(ctx, id, err)
+		return nil, err
+	}
+	s.invalidate(ctx, id, cacheEntry{Version: book.Version, Book: book})
+	return copyBook(book), nil
+}
+
+func (s *CachedBookStore) BookHistory(ctx context.Context, id int, params models.HistoryParams) (*models.Page[models.BookRevision], error) {
+	return s.next.BookHistory(ctx, id, params)
+}
+
+// PurgeDeletedBooks only removes books that are already tombstoned.
+func (s *CachedBookStore) PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error) {
+	return s.next.PurgeDeletedBooks(ctx, cutoff)
+}
+
+func (s *CachedBookStore) UpsertBooks(ctx context.Context, books []models.CreateBookRequest, dryRun bool) ([]models.UpsertResult, error) {
+	results, err := s.next.UpsertBooks(ctx, books, dryRun)
+	if err != nil || dryRun {
+		return results, err
+	}
+	for _, res := range results {
+		if res.Outcome == models.UpsertUpdated {
+			s.invalidate(ctx, res.ID, cacheEntry{Version: res.Version - 1})
+		}
+	}
+	return results, nil
+}
+
+func (s *CachedBookStore) CreateAuthor(ctx context.Context, req *models.AuthorRequest) (*models.Author, error) {
+	return s.next.CreateAuthor(ctx, req)
+}
+
+func (s *CachedBookStore) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	return s.next.GetAuthor(ctx, id)
+}
+
+func (s *CachedBookStore) ListAuthors(ctx context.Context, params models.ListAuthorsParams) (*models.Page[models.Author], error) {
+	return s.next.ListAuthors(ctx, params)
+}
+
+// UpdateAuthor leaves cached books alone: they refer to authors by ID only.
+func (s *CachedBookStore) UpdateAuthor(ctx context.Context, id int, req *models.AuthorRequest) (*models.Author, error) {
+	return s.next.UpdateAuthor(ctx, id, req)
+}
+
+func (s *CachedBookStore) DeleteAuthor(ctx context.Context, id int) error {
+	return s.next.DeleteAuthor(ctx, id)
+}
+
+func (s *CachedBookStore) ExportBooks(ctx context.Context, fn func(book *models.Book) error) error {
+	return s.next.ExportBooks(ctx, fn)
+}
+
+func (s *CachedBookStore) LastModified(ctx context.Context) (time.Time, error) {
+	return s.next.LastModified(ctx)
+}
+
+// load reads id from Redis or, failing that, the store, caching what it finds.
+// A Redis error is treated as a miss.
+func (s *CachedBookStore) load(ctx context.Context, id int) (*models.Book, error) {
+	if s.redis != nil {
+		e, ok, err := s.redis.get(ctx, id)
+		switch {
+		case err != nil:
+			s.redisError(ctx, "get", err)
+		case ok && e.Book != nil:
+			s.metrics.record(tierRedis, true)
+			s.local.setIfNewer(id, e)
+			return e.Book, nil
+		default:
+			s.metrics.record(tierRedis, false)
+		}
+	}
+
+	book, err := s.next.GetBookByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	e := cacheEntry{Version: book.Version, Book: book}
+	if s.redis != nil {
+		if err := s.redis.setIfNewer(ctx, id, e); err != nil {
+			s.redisError(ctx, "set", err)
+		}
+	}
+	s.local.setIfNewer(id, e)
+	return book, nil
+}
+
+// invalidate records a committed write to id in both tiers and tells the
+// other replicas to drop what they hold. It runs even if the caller has gone
+// away, since the write already happened.
+func (s *CachedBookStore) invalidate(ctx context.Context, id int, e cacheEntry) {
+	ctx = context.WithoutCancel(ctx)
+	if s.redis != nil {
+		if err := s.redis.setIfNewer(ctx, id, e); err != nil {
+			s.redisError(ctx, "set", err)
+		}
+		if err := s.redis.publish(ctx, id, e.fence()); err != nil {
+			s.redisError(ctx, "publish", err)
+		}
+	}
+	s.local.setIfNewer(id, e)
+}
+
+// discard drops id after a failed write unless the error proves nothing was
+// written. The new version is unknown, so the entry is removed outright.
+func (s *CachedBookStore) discard(ctx context.Context, id int, err error) {
+	if errors.Is(err, ErrPreconditionFailed) || errors.Is(err, ErrConflict) || errors.Is(err, ErrValidation) {
+		return
+	}
+	s.drop(ctx, id)
+}
+
+// drop removes id from every tier on every replica, whatever it holds.
+func (s *CachedBookStore) drop(ctx context.Context, id int) {
+	ctx = context.WithoutCancel(ctx)
+	if s.redis != nil {
+		if err := s.redis.remove(ctx, id); err != nil {
+			s.redisError(ctx, "remove", err)
+		}
+		if err := s.redis.publish(ctx, id, 0); err != nil {
+			s.redisError(ctx, "publish", err)
+		}
+	}
+	s.local.remove(id)
+}
+
+func (s *CachedBookStore) redisError(ctx context.Context, op string, err error) {
+	s.metrics.errors.WithLabelValues(op).Inc()
+	logging.FromContext(ctx).Warn("book cache unavailable", "operation", op, "error", err)
+}
+
+// localBookCache is the in-process tier.
+type localBookCache struct {
+	// mu makes setIfNewer's compare and swap atomic; the LRU locks itself
+	// for everything else.
+	mu  sync.Mutex
+	lru *expirable.LRU[int, cacheEntry]
+}
+
+func (c *localBookCache) get(id int) (cacheEntry, bool) {
+	return c.lru.Get(id)
+}
+
+func (c *localBookCache) setIfNewer(id int, e cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cur, ok := c.lru.Peek(id); ok && cur.rank() >= e.rank() {
+		return
+	}
+	c.lru.Add(id, e)
+}
+
+func (c *localBookCache) remove(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Remove(id)
+}
+
+const (
+	tierLocal = "local"
+	tierRedis = "redis"
+)
+
+type cacheMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+func newCacheMetrics(reg prometheus.Registerer) (*cacheMetrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m := &cacheMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "book_cache_requests_total",
+			Help: "Total number of book cache lookups by tier and result (hit or miss)",
+		}, []string{"tier", "result"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "book_cache_errors_total",
+			Help: "Total number of failed Redis book cache operations",
+		}, []string{"operation"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.errors} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *cacheMetrics) record(tier string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.requests.WithLabelValues(tier, result).Inc()
+}
+
+// copyBook keeps callers from mutating a cached or stored book.
+func copyBook(b *models.Book) *models.Book {
+	c := *b
+	c.AuthorIDs = slices.Clone(b.AuthorIDs)
+	return &c
+}
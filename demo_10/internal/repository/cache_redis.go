@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"book-service/internal/models"
+	"book-service/pkg/logging"
+)
+
+// setIfNewerScript stores "<rank>|<book json>" unless the key already holds
+// an entry of equal or higher rank, making version checks atomic across
+// replicas.
+var setIfNewerScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	local rank = tonumber(string.match(cur, '^(%d+)|'))
+	if rank and rank >= tonumber(ARGV[1]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1] .. '|' .. ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+const (
+	DefaultRedisBookCacheTTL = 5 * time.Minute
+	defaultRedisCacheTimeout = 100 * time.Millisecond
+)
+
+// RedisBookCache is the cache tier shared between replicas. It also carries
+// invalidations to every replica's LRU over pub/sub.
+type RedisBookCache struct {
+	client  redis.UniversalClient
+	prefix  string
+	channel string
+	// TTL is how long an entry lives in Redis.
+	TTL time.Duration
+	// Timeout bounds each call so a slow Redis degrades into a cache miss
+	// instead of stalling requests.
+	Timeout time.Duration
+}
+
+func NewRedisBookCache(client redis.UniversalClient) *RedisBookCache {
+	return &RedisBookCache{
+		client:  client,
+		prefix:  "book:",
+		channel: "book:invalidate",
+		TTL:     DefaultRedisBookCacheTTL,
+		Timeout: defaultRedisCacheTimeout,
+	}
+}
+
+func (c *RedisBookCache) key(id int) string {
+	return c.prefix + strconv.Itoa(id)
+}
+
+func (c *RedisBookCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Timeout > 0 {
+		return context.WithTimeout(ctx, c.Timeout)
+	}
+	return ctx, func() {}
+}
+
+func (c *RedisBookCache) get(ctx context.Context, id int) (cacheEntry, bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	val, err := c.client.Get(ctx, c.key(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return cacheEntry{}, false, nil
+	}
+	if err != nil {
+		return cacheEntry{}, false, err
+	}
+
+	rank, data, ok := strings.Cut(val, "|")
+	if !ok {
+		return cacheEntry{}, false, fmt.Errorf("malformed cache entry %q", c.key(id))
+	}
+	r, err := strconv.ParseInt(rank, 10, 64)
+	if err != nil {
+		return cacheEntry{}, false, fmt.Errorf("malformed cache entry %q: %w", c.key(id), err)
+	}
+	e := cacheEntry{Version: int(r / 2)}
+	if data != "" {
+		e.Book = new(models.Book)
+		if err := json.Unmarshal([]byte(data), e.Book); err != nil {
+			return cacheEntry{}, false, fmt.Errorf("malformed cache entry %q: %w", c.key(id), err)
+		}
+	}
+	return e, true, nil
+}
+
+func (c *RedisBookCache) setIfNewer(ctx context.Context, id int, e cacheEntry) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	var data []byte
+	if e.Book != nil {
+		var err error
+		if data, err = json.Marshal(e.Book); err != nil {
+			return err
+		}
+	}
+	return setIfNewerScript.Run(ctx, c.client, []string{c.key(id)}, e.rank(), data, c.TTL.Milliseconds()).Err()
+}
+
+func (c *RedisBookCache) remove(ctx context.Context, id int) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.Del(ctx, c.key(id)).Err()
+}
+
+// publish announces that versions of id up to fence are stale. A zero fence
+// means the version is unknown and the entry should be dropped.
+func (c *RedisBookCache) publish(ctx context.Context, id, fence int) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.client.Publish(ctx, c.channel, fmt.Sprintf("%d %d", id, fence)).Err()
+}
+
+// subscribe calls fn for every invalidation published until ctx is done. The
+// subscription reconnects on its own; messages sent while it is disconnected
+// are lost and the local TTL bounds the staleness that causes.
+func (c *RedisBookCache) subscribe(ctx context.Context, fn func(id, fence int)) {
+	sub := c.client.Subscribe(ctx, c.channel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var id, fence int
+			if _, err := fmt.Sscanf(msg.Payload, "%d %d", &id, &fence); err != nil {
+				logging.FromContext(ctx).Warn("ignoring malformed book cache invalidation", "payload", msg.Payload)
+				continue
+			}
+			fn(id, fence)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+
+	"book-service/internal/models"
+)
+
+func newTestCache(t *testing.T, next BookStore, redisCache *RedisBookCache) *CachedBookStore {
+	t.Helper()
+	store, err := NewCachedBookStore(next, BookCacheConfig{Redis: redisCache, Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("NewCachedBookStore() error = %v", err)
+	}
+	return store
+}
+
+func newTestRedisCache(t *testing.T, mr *miniredis.Miniredis) *RedisBookCache {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisBookCache(client)
+}
+
+func TestCachedBookStore(t *testing.T) {
+	t.Run("local", func(t *testing.T) {
+		runBookStoreConformance(t, func(t *testing.T) BookStore {
+			return newTestCache(t, NewMemoryBookStore(), nil)
+		})
+	})
+	t.Run("redis", func(t *testing.T) {
+		runBookStoreConformance(t, func(t *testing.T) BookStore {
+			return newTestCache(t, NewMemoryBookStore(), newTestRedisCache(t, miniredis.RunT(t)))
+		})
+	})
+}
+
+func TestCachedBookStoreHitsAndWrites(t *testing.T) {
+	mr := miniredis.RunT(t)
+	backing := NewMemoryBookStore()
+	store := newTestCache(t, backing, newTestRedisCache(t, mr))
+
+	book, err := store.CreateBook(t.Context(), &models.CreateBookRequest{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412})
+	if err != nil {
+		t.Fatalf("CreateBook() error = %v", err)
+	}
+
+	for range 3 {
+		if _, err := store.GetBookByID(t.Context(), book.ID); err != nil {
+			t.Fatalf("GetBookByID() error = %v", err)
+		}
+	}
+	requests := store.metrics.requests
+	if got := testutil.ToFloat64(requests.WithLabelValues(tierLocal, "hit")); got != 2 {
+		t.Errorf("local hits = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues(tierLocal, "miss")); got != 1 {
+		t.Errorf("local misses = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues(tierRedis, "miss")); got != 1 {
+		t.Errorf("redis misses = %v, want 1", got)
+	}
+
+	title := "Dune Messiah"
+	if _, err := store.UpdateBook(t.Context(), book.ID, &models.UpdateBookRequest{Title: &title}, nil); err != nil {
+		t.Fatalf("UpdateBook() error = %v", err)
+	}
+	got, err := store.GetBookByID(t.Context(), book.ID)
+	if err != nil {
+		t.Fatalf("GetBookByID() error = %v", err)
+	}
+	if got.Title != title || got.Version != 2 {
+		t.Errorf("GetBookByID() after update = %+v, want %q at version 2", got, title)
+	}
+
+	// A fresh replica sharing Redis sees the update too.
+	other := newTestCache(t, backing, newTestRedisCache(t, mr))
+	if got, err := other.GetBookByID(t.Context(), book.ID); err != nil || got.Version != 2 {
+		t.Errorf("other replica GetBookByID() = %+v, %v, want version 2", got, err)
+	}
+	if got := testutil.ToFloat64(other.metrics.requests.WithLabelValues(tierRedis, "hit")); got != 1 {
+		t.Errorf("other replica redis hits = %v, want 1", got)
+	}
+
+	// Upserts fence off the old version in both tiers.
+	edition := models.CreateBookRequest{Title: "Dune, 40th anniversary", Author: book.Author, ISBN: book.ISBN, Pages: book.Pages}
+	if _, err := store.UpsertBooks(t.Context(), []models.CreateBookRequest{edition}, false); err != nil {
+		t.Fatalf("UpsertBooks() error = %v", err)
+	}
+	if got, err := store.GetBookByID(t.Context(), book.ID); err != nil || got.Title != edition.Title {
+		t.Errorf("GetBookByID() after upsert = %+v, %v, want %q", got, err, edition.Title)
+	}
+
+	if err := store.DeleteBook(t.Context(), book.ID, nil); err != nil {
+		t.Fatalf("DeleteBook() error = %v", err)
+	}
+	if _, err := store.GetBookByID(t.Context(), book.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetBookByID() after delete error = %v, want ErrNotFound", err)
+	}
+
+	// Restoring replaces the tombstone in both tiers.
+	restored, err := store.RestoreBook(t.Context(), book.ID)
+	if err != nil {
+		t.Fatalf("RestoreBook() error = %v", err)
+	}
+	for name, s := range map[string]*CachedBookStore{"writer": store, "fresh replica": newTestCache(t, backing, newTestRedisCache(t, mr))} {
+		if got, err := s.GetBookByID(t.Context(), book.ID); err != nil || got.Version != restored.Version {
+			t.Errorf("%s GetBookByID() after restore = %+v, %v, want version %d", name, got, err, restored.Version)
+		}
+	}
+}
+
+func TestCachedBookStoreIgnoresStaleLoads(t *testing.T) {
+	book := &models.Book{ID: 1, Title: "new", Version: 2}
+	old := &models.Book{ID: 1, Title: "old", Version: 1}
+
+	for _, tc := range []struct {
+		name  string
+		write cacheEntry
+	}{
+		{"update", cacheEntry{Version: book.Version, Book: book}},
+		{"upsert", cacheEntry{Version: book.Version - 1}},
+		{"delete", cacheEntry{Version: tombstoneVersion}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			redisCache := newTestRedisCache(t, miniredis.RunT(t))
+			store := newTestCache(t, NewMemoryBookStore(), redisCache)
+
+			// A load that read the old row before the write committed
+			// finishes after it.
+			store.invalidate(t.Context(), 1, tc.write)
+			stale := cacheEntry{Version: old.Version, Book: old}
+			if err := redisCache.setIfNewer(t.Context(), 1, stale); err != nil {
+				t.Fatalf("setIfNewer() error = %v", err)
+			}
+			store.local.setIfNewer(1, stale)
+
+			for tier, get := range map[string]func() (cacheEntry, bool){
+				tierLocal: func() (cacheEntry, bool) { return store.local.get(1) },
+				tierRedis: func() (cacheEntry, bool) {
+					e, ok, err := redisCache.get(t.Context(), 1)
+					if err != nil {
+						t.Fatalf("get() error = %v", err)
+					}
+					return e, ok
+				},
+			} {
+				if e, _ := get(); e.Book != nil && e.Book.Version == old.Version {
+					t.Errorf("%s tier holds stale version after %s", tier, tc.name)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedBookStoreCollapsesConcurrentMisses(t *testing.T) {
+	backing := &blockingStore{BookStore: NewMemoryBookStore(), release: make(chan struct{})}
+	book, err := backing.CreateBook(t.Context(), &models.CreateBookRequest{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412})
+	if err != nil {
+		t.Fatalf("CreateBook() error = %v", err)
+	}
+	store := newTestCache(t, backing, nil)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.GetBookByID(t.Context(), book.ID); err != nil {
+				t.Errorf("GetBookByID() error = %v", err)
+			}
+		}()
+	}
+	// Every caller has missed the LRU and joined the load once the miss
+	// counter reaches callers.
+	misses := store.metrics.requests.WithLabelValues(tierLocal, "miss")
+	for testutil.ToFloat64(misses) < callers {
+		time.Sleep(time.Millisecond)
+	}
+	close(backing.release)
+	wg.Wait()
+
+	if got := backing.loads.Load(); got != 1 {
+		t.Errorf("store loads = %d, want 1", got)
+	}
+}
+
+func TestCachedBookStoreListen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	backing := NewMemoryBookStore()
+	book, err := backing.CreateBook(t.Context(), &models.CreateBookRequest{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412})
+	if err != nil {
+		t.Fatalf("CreateBook() error = %v", err)
+	}
+
+	reader := newTestCache(t, backing, newTestRedisCache(t, mr))
+	writer := newTestCache(t, backing, newTestRedisCache(t, mr))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reader.Listen(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for len(mr.PubSubChannels("")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := reader.GetBookByID(t.Context(), book.ID); err != nil {
+		t.Fatalf("GetBookByID() error = %v", err)
+	}
+	if err := writer.DeleteBook(t.Context(), book.ID, nil); err != nil {
+		t.Fatalf("DeleteBook() error = %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := reader.GetBookByID(t.Context(), book.ID)
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reader still serves deleted book, error = %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// blockingStore holds GetBookByID until release is closed and counts calls.
+type blockingStore struct {
+	BookStore
+	release chan struct{}
+	loads   atomic.Int32
+}
+
+func (s *blockingStore) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	s.loads.Add(1)
+	<-s.release
+	return s.BookStore.GetBookByID(ctx, id)
+}
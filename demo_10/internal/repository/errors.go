@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotFound    = errors.New("book not found")
+	ErrConflict    = errors.New("book conflicts with an existing book")
+	ErrValidation  = errors.New("book data rejected by storage")
+	ErrUnavailable = errors.New("storage unavailable")
+
+	ErrPreconditionFailed = errors.New("book version does not match")
+
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidSearch = errors.New("invalid search")
+
+	ErrAuthorNotFound = &kindError{msg: "author not found", kind: ErrNotFound}
+	ErrAuthorHasBooks = &kindError{msg: "the author is still credited on books", kind: ErrConflict}
+	// ErrAuthorAmbiguous is returned for a byline naming an author that more
+	// than one author goes by; the credit needs author_ids instead.
+	ErrAuthorAmbiguous = &kindError{msg: "more than one author has this name, credit them by author_ids", kind: ErrConflict}
+
+	// ErrBookOnLoan is returned for deleting a book while copies of it are
+	// out, since purging it later would lose their loans.
+	ErrBookOnLoan = &kindError{msg: "copies of the book are on loan", kind: ErrConflict}
+
+	ErrMemberNotFound  = &kindError{msg: "member not found", kind: ErrNotFound}
+	ErrMemberExists    = &kindError{msg: "a member with this email already exists", kind: ErrConflict}
+	ErrCopyNotFound    = &kindError{msg: "copy not found", kind: ErrNotFound}
+	ErrCopyExists      = &kindError{msg: "a copy with this barcode already exists", kind: ErrConflict}
+	ErrCopyOnLoan      = &kindError{msg: "the copy is already on loan", kind: ErrConflict}
+	ErrCopyOnHold      = &kindError{msg: "the copy is set aside for another member", kind: ErrConflict}
+	ErrNoCopyAvailable = &kindError{msg: "every copy of the book is on loan or on hold", kind: ErrConflict}
+	ErrLoanNotFound    = &kindError{msg: "loan not found", kind: ErrNotFound}
+	ErrLoanReturned    = &kindError{msg: "the loan has already been returned", kind: ErrConflict}
+	ErrHoldNotFound    = &kindError{msg: "hold not found", kind: ErrNotFound}
+	ErrHoldExists      = &kindError{msg: "the member already holds the book", kind: ErrConflict}
+	ErrHoldClosed      = &kindError{msg: "the hold is no longer open", kind: ErrConflict}
+	ErrFineNotFound    = &kindError{msg: "fine not found", kind: ErrNotFound}
+	ErrFinePaid        = &kindError{msg: "the fine has nothing left to pay", kind: ErrConflict}
+	ErrClosureNotFound = &kindError{msg: "closure day not found", kind: ErrNotFound}
+	ErrClosureExists   = &kindError{msg: "the library is already closed that day", kind: ErrConflict}
+)
+
+// kindError is a specific case of one of the general sentinels above, with
+// its own message. It matches the general sentinel with errors.Is, so code
+// that only knows about ErrNotFound or ErrConflict still handles it.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// specificError turns the general sentinels into the specific cases a
+// resource has, such as ErrNotFound into ErrAuthorNotFound.
+func specificError(err, notFound, conflict error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return notFound
+	case errors.Is(err, ErrConflict):
+		return conflict
+	}
+	return err
+}
+
+// translateError maps driver errors onto the package's sentinel errors so
+// callers can branch with errors.Is instead of inspecting driver types. The
+// original error is kept in the chain for logging.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505": // unique_violation
+			return fmt.Errorf("%w: %s", ErrConflict, conflictDetail(pqErr))
+		case pqErr.Code == "23503": // foreign_key_violation
+			return fmt.Errorf("%w: %s", ErrConflict, pqErr.Message)
+		case pqErr.Code.Class() == "22", pqErr.Code == "23502", pqErr.Code == "23514":
+			// data_exception, not_null_violation, check_violation
+			return fmt.Errorf("%w: %s", ErrValidation, pqErr.Message)
+		case pqErr.Code.Class() == "08", pqErr.Code.Class() == "53", pqErr.Code.Class() == "57":
+			// connection_exception, insufficient_resources, operator_intervention
+			return fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
+
+// contextError reports a failure caused by ctx ending as the context's own
+// error, so callers can tell a deadline or a disconnected client apart from
+// a broken database. Postgres reports a cancelled statement as
+// query_canceled, which would otherwise look like ErrUnavailable.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
+}
+
+func conflictDetail(err *pq.Error) string {
+	if err.Constraint == "books_isbn_live_key" {
+		return "a book with this ISBN already exists"
+	}
+	if err.Detail != "" {
+		return err.Detail
+	}
+	return err.Message
+}
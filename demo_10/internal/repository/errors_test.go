@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestContextError(t *testing.T) {
+	queryCanceled := &pq.Error{Code: "57014", Message: "canceling statement due to user request"}
+
+	expired, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want error
+	}{
+		{name: "deadline", ctx: expired, err: translateError(queryCanceled), want: context.DeadlineExceeded},
+		{name: "client gone", ctx: canceled, err: translateError(queryCanceled), want: context.Canceled},
+		{name: "live context", ctx: t.Context(), err: translateError(queryCanceled), want: ErrUnavailable},
+		{name: "no error", ctx: expired, err: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contextError(tt.ctx, tt.err)
+			if !errors.Is(got, tt.want) || (tt.want == nil) != (got == nil) {
+				t.Errorf("contextError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
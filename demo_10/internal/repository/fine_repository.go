@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"book-service/internal/models"
+)
+
+const (
+	fineColumns    = "id, loan_id, member_id, days, amount, paid, final, assessed_at, paid_at"
+	closureColumns = "to_char(day, 'YYYY-MM-DD'), reason"
+)
+
+func scanFine(row rowScanner) (*models.Fine, error) {
+	var f models.Fine
+	if err := row.Scan(&f.ID, &f.LoanID, &f.MemberID, &f.Days, &f.Amount, &f.Paid, &f.Final, &f.AssessedAt, &f.PaidAt); err != nil {
+		return nil, translateError(err)
+	}
+	return &f, nil
+}
+
+func scanClosure(row rowScanner) (*models.Closure, error) {
+	var c models.Closure
+	if err := row.Scan(&c.Day, &c.Reason); err != nil {
+		return nil, translateError(err)
+	}
+	return &c, nil
+}
+
+func (r *BookRepository) OverdueLoans(ctx context.Context, now time.Time) ([]models.OverdueLoan, error) {
+	ctx, end := r.startOp(ctx, "OverdueLoans")
+	defer end()
+
+	query := `
+		SELECT ` + loanColumns + `, (SELECT type FROM members WHERE members.id = loans.member_id)
+		FROM loans
+		WHERE (returned_at IS NULL AND due_at < $1)
+			OR (returned_at > due_at AND NOT EXISTS (SELECT 1 FROM fines WHERE fines.loan_id = loans.id AND fines.final))
+		ORDER BY id`
+	ctx, span := startQuery(ctx, "loans.select_overdue", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	var loans []models.OverdueLoan
+	for rows.Next() {
+		var l models.OverdueLoan
+		if err := rows.Scan(&l.ID, &l.CopyID, &l.BookID, &l.MemberID, &l.CheckedOutAt, &l.DueAt, &l.ReturnedAt, &l.MemberType); err != nil {
+			return nil, recordError(ctx, span, translateError(err))
+		}
+		loans = append(loans, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return loans, nil
+}
+
+// AssessFine upserts on the loan. The conditional update leaves a final or
+// newer fine alone, so an accrual run that read the loan before it was
+// returned cannot undo the assessment made on return.
+func (r *BookRepository) AssessFine(ctx context.Context, a models.Assessment) (*models.Fine, error) {
+	ctx, end := r.startOp(ctx, "AssessFine")
+	defer end()
+
+	query := `
+		INSERT INTO fines (loan_id, member_id, days, amount, final, assessed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (loan_id) DO UPDATE
+		SET days = EXCLUDED.days, amount = EXCLUDED.amount, final = EXCLUDED.final, assessed_at = EXCLUDED.assessed_at
+		WHERE NOT fines.final AND fines.assessed_at <= EXCLUDED.assessed_at
+		RETURNING ` + fineColumns
+	qctx, span := startQuery(ctx, "fines.upsert", query)
+	fine, err := scanFine(r.db.QueryRowContext(qctx, query, a.LoanID, a.MemberID, a.Days, a.Amount, a.Final, a.At))
+	recordError(qctx, span, err)
+	span.End()
+	if !errors.Is(err, ErrNotFound) {
+		return fine, specificError(err, ErrLoanNotFound, ErrConflict)
+	}
+
+	// The fine was left alone; return it as it stands.
+	query = `SELECT ` + fineColumns + ` FROM fines WHERE loan_id = $1`
+	ctx, span = startQuery(ctx, "fines.select_by_loan", query)
+	defer span.End()
+
+	fine, err = scanFine(r.db.QueryRowContext(ctx, query, a.LoanID))
+	return fine, specificError(recordError(ctx, span, err), ErrFineNotFound, ErrConflict)
+}
+
+func (r *BookRepository) GetFine(ctx context.Context, id int) (*models.Fine, error) {
+	ctx, end := r.startOp(ctx, "GetFine")
+	defer end()
+
+	return r.getFine(ctx, id)
+}
+
+func (r *BookRepository) getFine(ctx context.Context, id int) (*models.Fine, error) {
+	query := `SELECT ` + fineColumns + ` FROM fines WHERE id = $1`
+	ctx, span := startQuery(ctx, "fines.select_by_id", query)
+	defer span.End()
+
+	fine, err := scanFine(r.db.QueryRowContext(ctx, query, id))
+	return fine, specificError(recordError(ctx, span, err), ErrFineNotFound, ErrConflict)
+}
+
+func (r *BookRepository) ListFines(ctx context.Context, memberID int) ([]models.Fine, error) {
+	ctx, end := r.startOp(ctx, "ListFines")
+	defer end()
+
+	query := `SELECT ` + fineColumns + ` FROM fines WHERE member_id = $1 AND amount > 0 ORDER BY id DESC`
+	ctx, span := startQuery(ctx, "fines.list", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, memberID)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	fines := []models.Fine{}
+	for rows.Next() {
+		fine, err := scanFine(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		fines = append(fines, *fine)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return fines, nil
+}
+
+func (r *BookRepository) PayFine(ctx context.Context, id int, at time.Time) (*models.Fine, error) {
+	ctx, end := r.startOp(ctx, "PayFine")
+	defer end()
+
+	query := `
+		UPDATE fines SET paid = amount, paid_at = $2
+		WHERE id = $1 AND paid < amount
+		RETURNING ` + fineColumns
+	qctx, span := startQuery(ctx, "fines.pay", query)
+	fine, err := scanFine(r.db.QueryRowContext(qctx, query, id, at))
+	recordError(qctx, span, err)
+	span.End()
+	if !errors.Is(err, ErrNotFound) {
+		return fine, err
+	}
+
+	// Nothing was updated: either the fine does not exist or it is paid.
+	if _, err := r.getFine(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, ErrFinePaid
+}
+
+func (r *BookRepository) AddClosure(ctx context.Context, c *models.Closure) (*models.Closure, error) {
+	ctx, end := r.startOp(ctx, "AddClosure")
+	defer end()
+
+	query := `INSERT INTO closures (day, reason) VALUES ($1, $2) RETURNING ` + closureColumns
+	ctx, span := startQuery(ctx, "closures.insert", query)
+	defer span.End()
+
+	added, err := scanClosure(r.db.QueryRowContext(ctx, query, c.Day, c.Reason))
+	return added, specificError(recordError(ctx, span, err), ErrClosureNotFound, ErrClosureExists)
+}
+
+func (r *BookRepository) ListClosures(ctx context.Context, from, to string) ([]models.Closure, error) {
+	ctx, end := r.startOp(ctx, "ListClosures")
+	defer end()
+
+	query := `SELECT ` + closureColumns + ` FROM closures WHERE day BETWEEN $1 AND $2 ORDER BY day`
+	ctx, span := startQuery(ctx, "closures.list", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	closures := []models.Closure{}
+	for rows.Next() {
+		c, err := scanClosure(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		closures = append(closures, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return closures, nil
+}
+
+func (r *BookRepository) DeleteClosure(ctx context.Context, day string) error {
+	ctx, end := r.startOp(ctx, "DeleteClosure")
+	defer end()
+
+	query := `DELETE FROM closures WHERE day = $1`
+	ctx, span := startQuery(ctx, "closures.delete", query)
+	defer span.End()
+
+	res, err := r.db.ExecContext(ctx, query, day)
+	if err != nil {
+		return recordError(ctx, span, translateError(err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return recordError(ctx, span, translateError(err))
+	}
+	if n == 0 {
+		return ErrClosureNotFound
+	}
+	return nil
+}
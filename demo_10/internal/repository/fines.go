@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"book-service/internal/models"
+)
+
+// FineStore adds fines and the closure calendar to the lending workflow.
+// The store records the amounts it is given; working them out is up to the
+// caller, so that the arithmetic follows one policy and one clock.
+type FineStore interface {
+	LendingStore
+
+	// OverdueLoans lists the loans whose fines need assessing at now: open
+	// loans past their due date, and loans returned late whose fine is not
+	// final yet.
+	OverdueLoans(ctx context.Context, now time.Time) ([]models.OverdueLoan, error)
+	// AssessFine records the fine on a loan. It replaces an earlier
+	// assessment unless that one was final or is newer, and returns the
+	// fine as it stands.
+	AssessFine(ctx context.Context, a models.Assessment) (*models.Fine, error)
+	GetFine(ctx context.Context, id int) (*models.Fine, error)
+	// ListFines lists a member's fines that charge anything, newest first.
+	ListFines(ctx context.Context, memberID int) ([]models.Fine, error)
+	// PayFine settles what is left to pay on a fine, failing with
+	// ErrFinePaid if that is nothing.
+	PayFine(ctx context.Context, id int, at time.Time) (*models.Fine, error)
+
+	AddClosure(ctx context.Context, c *models.Closure) (*models.Closure, error)
+	// ListClosures lists the closure days from from to to, both included,
+	// in order.
+	ListClosures(ctx context.Context, from, to string) ([]models.Closure, error)
+	DeleteClosure(ctx context.Context, day string) error
+}
+
+var (
+	_ FineStore = (*BookRepository)(nil)
+	_ FineStore = (*MemoryBookStore)(nil)
+)
+
+// memberType defaults a member request's type.
+func memberType(t models.MemberType) models.MemberType {
+	if t == "" {
+		return models.MemberStandard
+	}
+	return t
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"time"
+
+	"book-service/internal/models"
+	"book-service/pkg/logging"
+)
+
+// bookState is the part of a book its history tracks, and what a revert
+// puts back. The JSON names match models.Book.
+type bookState struct {
+	Title     string     `json:"title"`
+	Author    string     `json:"author"`
+	AuthorIDs []int      `json:"author_ids"`
+	ISBN      string     `json:"isbn"`
+	Pages     int        `json:"pages"`
+	Published time.Time  `json:"published"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+func stateOf(book *models.Book) bookState {
+	return bookState{
+		Title:     book.Title,
+		Author:    book.Author,
+		AuthorIDs: book.AuthorIDs,
+		ISBN:      book.ISBN,
+		Pages:     book.Pages,
+		Published: book.Published,
+		DeletedAt: book.DeletedAt,
+	}
+}
+
+// updateRequest sets every field a revert restores. Whether the book is in
+// the trash is left alone. Revisions recorded before books had author IDs
+// restore the byline only, which credits the authors it names.
+func (s bookState) updateRequest() *models.UpdateBookRequest {
+	req := &models.UpdateBookRequest{
+		Title:     &s.Title,
+		Author:    &s.Author,
+		ISBN:      &s.ISBN,
+		Pages:     &s.Pages,
+		Published: &s.Published,
+	}
+	if s.AuthorIDs != nil {
+		req.AuthorIDs = &s.AuthorIDs
+	}
+	return req
+}
+
+// revision is a history entry together with the state the change left the
+// book in.
+type revision struct {
+	models.BookRevision
+	State bookState
+}
+
+// newRevision records the write that took a book from before, nil for a new
+// book, to after. The actor and request ID come from ctx.
+func newRevision(ctx context.Context, action models.BookAction, before, after *models.Book) revision {
+	state := stateOf(after)
+	return revision{
+		BookRevision: models.BookRevision{
+			BookID:    after.ID,
+			Revision:  after.Version,
+			Action:    action,
+			Actor:     logging.Actor(ctx),
+			RequestID: logging.RequestID(ctx),
+			ChangedAt: after.UpdatedAt,
+			Changes:   diffStates(before, state),
+		},
+		State: state,
+	}
+}
+
+func diffStates(before *models.Book, after bookState) map[string]models.FieldChange {
+	old := map[string]json.RawMessage{}
+	if before != nil {
+		old = stateFields(stateOf(before))
+	}
+
+	changes := make(map[string]models.FieldChange)
+	for name, value := range stateFields(after) {
+		prev, ok := old[name]
+		if !ok {
+			prev = json.RawMessage("null")
+		}
+		if !bytes.Equal(prev, value) {
+			changes[name] = models.FieldChange{Before: prev, After: value}
+		}
+	}
+	return changes
+}
+
+func stateFields(s bookState) map[string]json.RawMessage {
+	data, _ := json.Marshal(s)
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// historyCursor pages backwards through a book's revisions.
+type historyCursor struct {
+	Revision int `json:"r"`
+}
+
+func encodeHistoryCursor(rev int) string {
+	data, _ := json.Marshal(historyCursor{Revision: rev})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeHistoryCursor returns the revision the next page starts below. An
+// empty cursor starts at the newest revision.
+func decodeHistoryCursor(s string) (int, error) {
+	if s == "" {
+		return math.MaxInt32, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var c historyCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Revision < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return c.Revision, nil
+}
+
+// historyPage trims revisions, fetched newest first with one extra, to a
+// page.
+func historyPage(revisions []models.BookRevision, limit int) *models.Page[models.BookRevision] {
+	page := &models.Page[models.BookRevision]{Data: revisions}
+	if len(revisions) > limit {
+		page.Data = revisions[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeHistoryCursor(page.Data[limit-1].Revision)
+	}
+	return page
+}
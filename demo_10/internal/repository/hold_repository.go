@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"book-service/internal/models"
+)
+
+// Every change to a book's holds runs in a transaction that first locks the
+// book's row with lockQueue, so the queue of each book changes one
+// transaction at a time. Checkout shares that lock, so it never lends a copy
+// while one is being set aside.
+
+const holdColumns = "id, book_id, member_id, status, copy_id, created_at, ready_at, pickup_by, closed_at, " +
+	"CASE WHEN status = 'waiting' THEN (SELECT count(*) FROM holds q WHERE q.book_id = holds.book_id AND q.status = 'waiting' AND q.id <= holds.id) ELSE 0 END"
+
+func scanHold(row rowScanner) (*models.Hold, error) {
+	var (
+		h      models.Hold
+		copyID sql.NullInt64
+	)
+	if err := row.Scan(&h.ID, &h.BookID, &h.MemberID, &h.Status, &copyID, &h.CreatedAt, &h.ReadyAt, &h.PickupBy, &h.ClosedAt, &h.Position); err != nil {
+		return nil, translateError(err)
+	}
+	if copyID.Valid {
+		id := int(copyID.Int64)
+		h.CopyID = &id
+	}
+	return &h, nil
+}
+
+func (r *BookRepository) Reserve(ctx context.Context, res models.Reserve) (*models.Hold, error) {
+	ctx, end := r.startOp(ctx, "Reserve")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	if err := lockMember(ctx, tx, res.MemberID); err != nil {
+		return nil, err
+	}
+	if err := lockQueue(ctx, tx, res.BookID, true); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO holds (book_id, member_id, status, created_at)
+		VALUES ($1, $2, 'waiting', $3)
+		RETURNING id`
+	qctx, span := startQuery(ctx, "holds.insert", query)
+	var id int
+	err = translateError(tx.QueryRowContext(qctx, query, res.BookID, res.MemberID, res.At).Scan(&id))
+	recordError(qctx, span, err)
+	span.End()
+	if err != nil {
+		return nil, specificError(err, ErrHoldNotFound, ErrHoldExists)
+	}
+
+	if err := fillHolds(ctx, tx, res.BookID, res.At, res.PickupBy); err != nil {
+		return nil, err
+	}
+	hold, err := getHold(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return hold, nil
+}
+
+func (r *BookRepository) GetHold(ctx context.Context, id int) (*models.Hold, error) {
+	ctx, end := r.startOp(ctx, "GetHold")
+	defer end()
+
+	return getHold(ctx, r.db, id)
+}
+
+// CancelHold passes a copy set aside for the hold on to the next holder.
+func (r *BookRepository) CancelHold(ctx context.Context, id int, at, pickupBy time.Time) (*models.Hold, error) {
+	ctx, end := r.startOp(ctx, "CancelHold")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	hold, err := getHold(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockQueue(ctx, tx, hold.BookID, false); err != nil {
+		return nil, err
+	}
+
+	// The status is checked again now that the queue is locked.
+	query := `
+		UPDATE holds SET status = 'cancelled', closed_at = $2
+		WHERE id = $1 AND status IN ('waiting', 'ready')`
+	qctx, span := startQuery(ctx, "holds.cancel", query)
+	res, err := tx.ExecContext(qctx, query, id, at)
+	err = recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, translateError(err)
+	} else if n == 0 {
+		return nil, ErrHoldClosed
+	}
+
+	if err := fillHolds(ctx, tx, hold.BookID, at, pickupBy); err != nil {
+		return nil, err
+	}
+	if hold, err = getHold(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return hold, nil
+}
+
+func (r *BookRepository) ListHolds(ctx context.Context, bookID int) ([]models.Hold, error) {
+	ctx, end := r.startOp(ctx, "ListHolds")
+	defer end()
+
+	exists := `SELECT EXISTS (SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL)`
+	qctx, span := startQuery(ctx, "books.exists_live", exists)
+	var live bool
+	err := recordError(qctx, span, translateError(r.db.QueryRowContext(qctx, exists, bookID).Scan(&live)))
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	if !live {
+		return nil, ErrNotFound
+	}
+
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE book_id = $1 AND status IN ('waiting', 'ready') ORDER BY id`
+	ctx, span = startQuery(ctx, "holds.list_open", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	holds := []models.Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		holds = append(holds, *hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return holds, nil
+}
+
+// ExpireHolds works through the books that need it one transaction at a
+// time, so replicas running it together only wait for each other book by
+// book.
+func (r *BookRepository) ExpireHolds(ctx context.Context, at, pickupBy time.Time) (int, error) {
+	ctx, end := r.startOp(ctx, "ExpireHolds")
+	defer end()
+
+	books, err := r.queuesToSweep(ctx, at)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, bookID := range books {
+		n, err := r.sweepQueue(ctx, bookID, at, pickupBy)
+		if err != nil {
+			return expired, err
+		}
+		expired += n
+	}
+	return expired, nil
+}
+
+// queuesToSweep finds the books with a ready hold past its deadline or a
+// free copy that someone is waiting for.
+func (r *BookRepository) queuesToSweep(ctx context.Context, at time.Time) ([]int, error) {
+	query := `
+		SELECT book_id FROM holds WHERE status = 'ready' AND pickup_by < $1
+		UNION
+		SELECT holds.book_id FROM holds JOIN copies ON copies.book_id = holds.book_id
+		WHERE holds.status = 'waiting'
+			AND NOT EXISTS (SELECT 1 FROM loans WHERE loans.copy_id = copies.id AND loans.returned_at IS NULL)
+			AND NOT EXISTS (SELECT 1 FROM holds h WHERE h.copy_id = copies.id AND h.status = 'ready')`
+	ctx, span := startQuery(ctx, "holds.select_queues_to_sweep", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, at)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	var books []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, recordError(ctx, span, translateError(err))
+		}
+		books = append(books, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return books, nil
+}
+
+func (r *BookRepository) sweepQueue(ctx context.Context, bookID int, at, pickupBy time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	// A book purged since it was found has no holds left to sweep.
+	if err := lockQueue(ctx, tx, bookID, false); errors.Is(err, ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	query := `
+		UPDATE holds SET status = 'expired', closed_at = $2
+		WHERE book_id = $1 AND status = 'ready' AND pickup_by < $2`
+	qctx, span := startQuery(ctx, "holds.expire", query)
+	res, err := tx.ExecContext(qctx, query, bookID, at)
+	err = recordError(qctx, span, translateError(err))
+	span.End()
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	if err := fillHolds(ctx, tx, bookID, at, pickupBy); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, txError(ctx, err)
+	}
+	return int(n), nil
+}
+
+// lockQueue locks a book's row for the rest of tx, failing with ErrNotFound
+// if the book does not exist or, when live is set, is in the trash. It takes
+// FOR NO KEY UPDATE, which conflicts with the FOR SHARE that Checkout holds,
+// but not with inserting rows that reference the book.
+func lockQueue(ctx context.Context, tx *sql.Tx, bookID int, live bool) error {
+	query := `SELECT 1 FROM books WHERE id = $1 AND (deleted_at IS NULL OR NOT $2) FOR NO KEY UPDATE`
+	ctx, span := startQuery(ctx, "books.select_for_no_key_update", query)
+	defer span.End()
+
+	var one int
+	return recordError(ctx, span, translateError(tx.QueryRowContext(ctx, query, bookID, live).Scan(&one)))
+}
+
+// fillHolds sets aside the free copies of a book for its waiting holders,
+// lowest copy id to the longest waiting. Callers hold the book's queue lock.
+func fillHolds(ctx context.Context, tx *sql.Tx, bookID int, at, pickupBy time.Time) error {
+	query := `
+		WITH free AS (
+			SELECT id, row_number() OVER (ORDER BY id) AS n FROM copies
+			WHERE book_id = $1
+				AND NOT EXISTS (SELECT 1 FROM loans WHERE loans.copy_id = copies.id AND loans.returned_at IS NULL)
+				AND NOT EXISTS (SELECT 1 FROM holds WHERE holds.copy_id = copies.id AND holds.status = 'ready')
+		), queue AS (
+			SELECT id, row_number() OVER (ORDER BY id) AS n FROM holds
+			WHERE book_id = $1 AND status = 'waiting'
+		)
+		UPDATE holds SET status = 'ready', copy_id = free.id, ready_at = $2, pickup_by = $3
+		FROM queue JOIN free ON free.n = queue.n
+		WHERE holds.id = queue.id`
+	ctx, span := startQuery(ctx, "holds.fill", query)
+	defer span.End()
+
+	_, err := tx.ExecContext(ctx, query, bookID, at, pickupBy)
+	return recordError(ctx, span, translateError(err))
+}
+
+// readyHold returns the copy set aside for a member's ready hold on a book,
+// or zero.
+func readyHold(ctx context.Context, tx *sql.Tx, bookID, memberID int) (int, error) {
+	query := `SELECT copy_id FROM holds WHERE book_id = $1 AND member_id = $2 AND status = 'ready'`
+	ctx, span := startQuery(ctx, "holds.select_ready", query)
+	defer span.End()
+
+	var copyID int
+	err := translateError(tx.QueryRowContext(ctx, query, bookID, memberID).Scan(&copyID))
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return copyID, recordError(ctx, span, err)
+}
+
+// copyOnHold reports whether a copy is set aside for a ready hold.
+func copyOnHold(ctx context.Context, tx *sql.Tx, id int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM holds WHERE copy_id = $1 AND status = 'ready')`
+	ctx, span := startQuery(ctx, "holds.exists_ready", query)
+	defer span.End()
+
+	var onHold bool
+	err := tx.QueryRowContext(ctx, query, id).Scan(&onHold)
+	return onHold, recordError(ctx, span, translateError(err))
+}
+
+// fulfilHolds closes the member's hold on a book once they have borrowed a
+// copy of it: a waiting one, or the ready one for that copy.
+func fulfilHolds(ctx context.Context, tx *sql.Tx, bookID, memberID, copyID int, at time.Time) error {
+	query := `
+		UPDATE holds SET status = 'fulfilled', closed_at = $4
+		WHERE book_id = $1 AND member_id = $2
+			AND (status = 'waiting' OR (status = 'ready' AND copy_id = $3))`
+	ctx, span := startQuery(ctx, "holds.fulfil", query)
+	defer span.End()
+
+	_, err := tx.ExecContext(ctx, query, bookID, memberID, copyID, at)
+	return recordError(ctx, span, translateError(err))
+}
+
+// querier is what getHold needs from a *sql.DB or *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func getHold(ctx context.Context, q querier, id int) (*models.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE id = $1`
+	ctx, span := startQuery(ctx, "holds.select_by_id", query)
+	defer span.End()
+
+	hold, err := scanHold(q.QueryRowContext(ctx, query, id))
+	return hold, specificError(recordError(ctx, span, err), ErrHoldNotFound, ErrConflict)
+}
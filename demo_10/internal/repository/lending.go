@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"time"
+
+	"book-service/internal/models"
+)
+
+// LendingStore keeps library members, the copies of each book and the loans
+// of copies to members. BookRepository implements it on Postgres and
+// MemoryBookStore in process; the book cache has nothing to add and does
+// not.
+//
+// A copy has at most one open loan and is set aside for at most one ready
+// hold, and only that holder can borrow it. Whenever a copy comes free it is
+// set aside for the book's first waiting holder, until the pickupBy deadline
+// the caller passes in. Times are supplied by the caller so that due dates,
+// deadlines and statuses follow one clock.
+type LendingStore interface {
+	CreateMember(ctx context.Context, req *models.MemberRequest) (*models.Member, error)
+	GetMember(ctx context.Context, id int) (*models.Member, error)
+
+	// AddCopy adds a copy of a live book.
+	AddCopy(ctx context.Context, bookID int, req *models.CopyRequest, at, pickupBy time.Time) (*models.Copy, error)
+	// ListCopies lists the copies of a live book in id order.
+	ListCopies(ctx context.Context, bookID int) ([]models.Copy, error)
+
+	// Checkout lends a copy of a live book. A member with a ready hold gets
+	// the copy set aside for them; otherwise, with CopyID zero, it picks a
+	// copy that is neither on loan nor on hold, failing with
+	// ErrNoCopyAvailable if there is none. It fulfils the member's hold on
+	// the book.
+	Checkout(ctx context.Context, c models.Checkout) (*models.Loan, error)
+	// ReturnLoan closes an open loan at the given time.
+	ReturnLoan(ctx context.Context, id int, at, pickupBy time.Time) (*models.Loan, error)
+	GetLoan(ctx context.Context, id int) (*models.Loan, error)
+	// ListLoans lists a member's loans, newest first.
+	ListLoans(ctx context.Context, params models.ListLoansParams) (*models.Page[models.Loan], error)
+
+	// Reserve puts a member at the back of the queue for a live book,
+	// failing with ErrHoldExists if they are already in it.
+	Reserve(ctx context.Context, r models.Reserve) (*models.Hold, error)
+	GetHold(ctx context.Context, id int) (*models.Hold, error)
+	// CancelHold closes a waiting or ready hold.
+	CancelHold(ctx context.Context, id int, at, pickupBy time.Time) (*models.Hold, error)
+	// ListHolds lists the open holds on a book in queue order.
+	ListHolds(ctx context.Context, bookID int) ([]models.Hold, error)
+	// ExpireHolds expires ready holds whose deadline passed before at, passes
+	// their copies on and sets aside any other free copies that holders are
+	// waiting for. It returns how many holds expired.
+	ExpireHolds(ctx context.Context, at, pickupBy time.Time) (int, error)
+}
+
+var (
+	_ LendingStore = (*BookRepository)(nil)
+	_ LendingStore = (*MemoryBookStore)(nil)
+)
+
+// loanCursor pages backwards through loans by id.
+type loanCursor struct {
+	ID int `json:"i"`
+}
+
+func encodeLoanCursor(id int) string {
+	data, _ := json.Marshal(loanCursor{ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeLoanCursor returns the id the next page starts below. An empty
+// cursor starts at the newest loan.
+func decodeLoanCursor(s string) (int, error) {
+	if s == "" {
+		return math.MaxInt32, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var c loanCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return c.ID, nil
+}
+
+func loanPage(loans []models.Loan, limit int) *models.Page[models.Loan] {
+	page := &models.Page[models.Loan]{Data: loans}
+	if len(loans) > limit {
+		page.Data = loans[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeLoanCursor(page.Data[limit-1].ID)
+	}
+	return page
+}
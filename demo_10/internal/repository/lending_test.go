@@ -0,0 +1,679 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"book-service/internal/models"
+)
+
+type lendingBookStore interface {
+	BookStore
+	FineStore
+}
+
+// runLendingStoreConformance is the contract every LendingStore
+// implementation must satisfy. newStore must return an empty store.
+func runLendingStoreConformance(t *testing.T, newStore func(t *testing.T) lendingBookStore) {
+	checkedOut := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	due := checkedOut.AddDate(0, 0, 21)
+	// pickupBy is the deadline for copies set aside by returns; the hold
+	// tests pass their own.
+	pickupBy := due.AddDate(0, 0, 3)
+
+	// setup creates a book with n copies and a member.
+	setup := func(t *testing.T, store lendingBookStore, n int) (*models.Book, []*models.Copy, *models.Member) {
+		book, err := store.CreateBook(t.Context(), &models.CreateBookRequest{
+			Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412, Published: checkedOut.AddDate(-60, 0, 0),
+		})
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		copies := make([]*models.Copy, n)
+		for i := range copies {
+			if copies[i], err = store.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: "DUNE-" + string(rune('A'+i))}, checkedOut, pickupBy); err != nil {
+				t.Fatalf("AddCopy() error = %v", err)
+			}
+		}
+		member, err := store.CreateMember(t.Context(), &models.MemberRequest{Name: "Paul Atreides", Email: "paul@example.com"})
+		if err != nil {
+			t.Fatalf("CreateMember() error = %v", err)
+		}
+		return book, copies, member
+	}
+
+	// newMembers creates n more members.
+	newMembers := func(t *testing.T, store lendingBookStore, n int) []*models.Member {
+		members := make([]*models.Member, n)
+		for i := range members {
+			var err error
+			members[i], err = store.CreateMember(t.Context(), &models.MemberRequest{Name: fmt.Sprintf("Member %d", i), Email: fmt.Sprintf("member%d@example.com", i)})
+			if err != nil {
+				t.Fatalf("CreateMember() error = %v", err)
+			}
+		}
+		return members
+	}
+
+	t.Run("members and copies", func(t *testing.T) {
+		store := newStore(t)
+		book, copies, member := setup(t, store, 1)
+
+		if got, err := store.GetMember(t.Context(), member.ID); err != nil || *got != *member {
+			t.Errorf("GetMember() = %+v, %v, want %+v", got, err, member)
+		}
+		if _, err := store.CreateMember(t.Context(), &models.MemberRequest{Name: "Paul", Email: member.Email}); !errors.Is(err, ErrMemberExists) {
+			t.Errorf("CreateMember() duplicate email error = %v, want ErrMemberExists", err)
+		}
+		if _, err := store.GetMember(t.Context(), member.ID+1); !errors.Is(err, ErrMemberNotFound) || !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetMember() unknown error = %v, want ErrMemberNotFound", err)
+		}
+
+		if _, err := store.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: copies[0].Barcode}, checkedOut, pickupBy); !errors.Is(err, ErrCopyExists) {
+			t.Errorf("AddCopy() duplicate barcode error = %v, want ErrCopyExists", err)
+		}
+		if _, err := store.AddCopy(t.Context(), book.ID+1, &models.CopyRequest{Barcode: "X"}, checkedOut, pickupBy); !errors.Is(err, ErrNotFound) {
+			t.Errorf("AddCopy() unknown book error = %v, want ErrNotFound", err)
+		}
+		got, err := store.ListCopies(t.Context(), book.ID)
+		if err != nil || len(got) != 1 || got[0].ID != copies[0].ID || got[0].OnLoan {
+			t.Errorf("ListCopies() = %+v, %v, want the one copy, not on loan", got, err)
+		}
+	})
+
+	t.Run("checkout and return", func(t *testing.T) {
+		store := newStore(t)
+		book, copies, member := setup(t, store, 2)
+
+		loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due})
+		if err != nil {
+			t.Fatalf("Checkout() error = %v", err)
+		}
+		if loan.CopyID != copies[0].ID || loan.BookID != book.ID || !loan.CheckedOutAt.Equal(checkedOut) || !loan.DueAt.Equal(due) || loan.ReturnedAt != nil {
+			t.Errorf("Checkout() = %+v, want the first copy, open, due %v", loan, due)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, CopyID: copies[0].ID, At: checkedOut, DueAt: due}); !errors.Is(err, ErrCopyOnLoan) {
+			t.Errorf("Checkout() of a copy on loan error = %v, want ErrCopyOnLoan", err)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due}); err != nil {
+			t.Fatalf("Checkout() second copy error = %v", err)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due}); !errors.Is(err, ErrNoCopyAvailable) {
+			t.Errorf("Checkout() with every copy out error = %v, want ErrNoCopyAvailable", err)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID + 1, At: checkedOut, DueAt: due}); !errors.Is(err, ErrMemberNotFound) {
+			t.Errorf("Checkout() unknown member error = %v, want ErrMemberNotFound", err)
+		}
+		if list, err := store.ListCopies(t.Context(), book.ID); err != nil || !list[0].OnLoan || !list[1].OnLoan {
+			t.Errorf("ListCopies() = %+v, %v, want both on loan", list, err)
+		}
+
+		returnedAt := due.AddDate(0, 0, 3)
+		returned, err := store.ReturnLoan(t.Context(), loan.ID, returnedAt, pickupBy)
+		if err != nil || returned.ReturnedAt == nil || !returned.ReturnedAt.Equal(returnedAt) {
+			t.Fatalf("ReturnLoan() = %+v, %v, want returned at %v", returned, err, returnedAt)
+		}
+		if _, err := store.ReturnLoan(t.Context(), loan.ID, returnedAt, pickupBy); !errors.Is(err, ErrLoanReturned) {
+			t.Errorf("ReturnLoan() twice error = %v, want ErrLoanReturned", err)
+		}
+		if _, err := store.ReturnLoan(t.Context(), loan.ID+100, returnedAt, pickupBy); !errors.Is(err, ErrLoanNotFound) {
+			t.Errorf("ReturnLoan() unknown error = %v, want ErrLoanNotFound", err)
+		}
+		again, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: returnedAt, DueAt: returnedAt.AddDate(0, 0, 21)})
+		if err != nil || again.CopyID != copies[0].ID {
+			t.Errorf("Checkout() after return = %+v, %v, want the returned copy", again, err)
+		}
+	})
+
+	t.Run("list loans by status", func(t *testing.T) {
+		store := newStore(t)
+		book, _, member := setup(t, store, 3)
+
+		var loans []*models.Loan
+		for i := range 3 {
+			at := checkedOut.AddDate(0, 0, i*10)
+			loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: at, DueAt: at.AddDate(0, 0, 21)})
+			if err != nil {
+				t.Fatalf("Checkout() error = %v", err)
+			}
+			loans = append(loans, loan)
+		}
+		if _, err := store.ReturnLoan(t.Context(), loans[0].ID, checkedOut.AddDate(0, 0, 5), pickupBy); err != nil {
+			t.Fatalf("ReturnLoan() error = %v", err)
+		}
+
+		// At now, loan 1 is returned, loan 2 (due day 31) overdue and loan 3
+		// (due day 41) active.
+		now := checkedOut.AddDate(0, 0, 35)
+		tests := []struct {
+			status models.LoanStatus
+			want   []int
+		}{
+			{status: "", want: []int{loans[2].ID, loans[1].ID, loans[0].ID}},
+			{status: models.LoanReturned, want: []int{loans[0].ID}},
+			{status: models.LoanOverdue, want: []int{loans[1].ID}},
+			{status: models.LoanActive, want: []int{loans[2].ID}},
+		}
+		for _, tt := range tests {
+			t.Run(string(tt.status), func(t *testing.T) {
+				page, err := store.ListLoans(t.Context(), models.ListLoansParams{MemberID: member.ID, Status: tt.status, Now: now, Limit: 10})
+				if err != nil {
+					t.Fatalf("ListLoans() error = %v", err)
+				}
+				var got []int
+				for _, l := range page.Data {
+					got = append(got, l.ID)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("ListLoans(%q) = %v, want %v", tt.status, got, tt.want)
+				}
+			})
+		}
+
+		first, err := store.ListLoans(t.Context(), models.ListLoansParams{MemberID: member.ID, Now: now, Limit: 2})
+		if err != nil || len(first.Data) != 2 || !first.HasMore {
+			t.Fatalf("ListLoans() first page = %+v, %v, want two loans and more", first, err)
+		}
+		rest, err := store.ListLoans(t.Context(), models.ListLoansParams{MemberID: member.ID, Now: now, Cursor: first.NextCursor, Limit: 2})
+		if err != nil || len(rest.Data) != 1 || rest.HasMore || rest.Data[0].ID != loans[0].ID {
+			t.Errorf("ListLoans() second page = %+v, %v, want the oldest loan", rest, err)
+		}
+	})
+
+	t.Run("concurrent checkouts lend each copy once", func(t *testing.T) {
+		store := newStore(t)
+		book, copies, member := setup(t, store, 3)
+
+		const workers = 8
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			lent    = map[int]int{}
+			refused int
+		)
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due})
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					lent[loan.CopyID]++
+				case errors.Is(err, ErrNoCopyAvailable):
+					refused++
+				default:
+					t.Errorf("Checkout() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(lent) != len(copies) || refused != workers-len(copies) {
+			t.Errorf("lent %v and refused %d, want each of %d copies once", lent, refused, len(copies))
+		}
+		for id, n := range lent {
+			if n != 1 {
+				t.Errorf("copy %d lent %d times", id, n)
+			}
+		}
+	})
+
+	t.Run("holds queue and pass on uncollected copies", func(t *testing.T) {
+		store := newStore(t)
+		book, copies, borrower := setup(t, store, 1)
+		members := newMembers(t, store, 2)
+
+		loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: borrower.ID, At: checkedOut, DueAt: due})
+		if err != nil {
+			t.Fatalf("Checkout() error = %v", err)
+		}
+		var holds []*models.Hold
+		for i, m := range members {
+			hold, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID, MemberID: m.ID, At: checkedOut.Add(time.Duration(i) * time.Hour), PickupBy: pickupBy})
+			if err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+			if hold.Status != models.HoldWaiting || hold.Position != i+1 || hold.CopyID != nil {
+				t.Errorf("Reserve() = %+v, want waiting at position %d", hold, i+1)
+			}
+			holds = append(holds, hold)
+		}
+		if _, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID, MemberID: members[0].ID, At: checkedOut, PickupBy: pickupBy}); !errors.Is(err, ErrHoldExists) {
+			t.Errorf("Reserve() twice error = %v, want ErrHoldExists", err)
+		}
+		if _, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID + 1, MemberID: members[0].ID, At: checkedOut, PickupBy: pickupBy}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Reserve() unknown book error = %v, want ErrNotFound", err)
+		}
+
+		// The return sets the copy aside for the first holder only.
+		returnedAt := due.AddDate(0, 0, -1)
+		firstPickup := returnedAt.AddDate(0, 0, 3)
+		if _, err := store.ReturnLoan(t.Context(), loan.ID, returnedAt, firstPickup); err != nil {
+			t.Fatalf("ReturnLoan() error = %v", err)
+		}
+		first, err := store.GetHold(t.Context(), holds[0].ID)
+		if err != nil || first.Status != models.HoldReady || first.CopyID == nil || *first.CopyID != copies[0].ID || !first.PickupBy.Equal(firstPickup) {
+			t.Fatalf("GetHold() first = %+v, %v, want ready with the copy until %v", first, err, firstPickup)
+		}
+		if queue, err := store.ListHolds(t.Context(), book.ID); err != nil || len(queue) != 2 || queue[0].ID != holds[0].ID || queue[1].Position != 1 {
+			t.Errorf("ListHolds() = %+v, %v, want the ready hold then the second at position 1", queue, err)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: members[1].ID, At: returnedAt, DueAt: due}); !errors.Is(err, ErrNoCopyAvailable) {
+			t.Errorf("Checkout() by the second holder error = %v, want ErrNoCopyAvailable", err)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: members[1].ID, CopyID: copies[0].ID, At: returnedAt, DueAt: due}); !errors.Is(err, ErrCopyOnHold) {
+			t.Errorf("Checkout() of the held copy error = %v, want ErrCopyOnHold", err)
+		}
+
+		// Uncollected by the deadline, it passes to the second holder.
+		if n, err := store.ExpireHolds(t.Context(), firstPickup, firstPickup.AddDate(0, 0, 3)); err != nil || n != 0 {
+			t.Errorf("ExpireHolds() at the deadline = %d, %v, want 0", n, err)
+		}
+		expiredAt := firstPickup.Add(time.Minute)
+		secondPickup := expiredAt.AddDate(0, 0, 3)
+		if n, err := store.ExpireHolds(t.Context(), expiredAt, secondPickup); err != nil || n != 1 {
+			t.Errorf("ExpireHolds() past the deadline = %d, %v, want 1", n, err)
+		}
+		if got, err := store.GetHold(t.Context(), holds[0].ID); err != nil || got.Status != models.HoldExpired || got.ClosedAt == nil || !got.ClosedAt.Equal(expiredAt) {
+			t.Errorf("GetHold() first = %+v, %v, want expired at %v", got, err, expiredAt)
+		}
+		second, err := store.GetHold(t.Context(), holds[1].ID)
+		if err != nil || second.Status != models.HoldReady || *second.CopyID != copies[0].ID || !second.PickupBy.Equal(secondPickup) {
+			t.Fatalf("GetHold() second = %+v, %v, want ready until %v", second, err, secondPickup)
+		}
+
+		lent, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: members[1].ID, At: expiredAt, DueAt: expiredAt.AddDate(0, 0, 21)})
+		if err != nil || lent.CopyID != copies[0].ID {
+			t.Fatalf("Checkout() by the holder = %+v, %v, want the copy set aside", lent, err)
+		}
+		if got, err := store.GetHold(t.Context(), holds[1].ID); err != nil || got.Status != models.HoldFulfilled {
+			t.Errorf("GetHold() second = %+v, %v, want fulfilled", got, err)
+		}
+		if queue, err := store.ListHolds(t.Context(), book.ID); err != nil || len(queue) != 0 {
+			t.Errorf("ListHolds() = %+v, %v, want an empty queue", queue, err)
+		}
+	})
+
+	t.Run("cancelling a hold passes its copy on", func(t *testing.T) {
+		store := newStore(t)
+		book, copies, _ := setup(t, store, 1)
+		members := newMembers(t, store, 2)
+
+		// With the copy free, the first reservation gets it at once.
+		var holds []*models.Hold
+		for _, m := range members {
+			hold, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID, MemberID: m.ID, At: checkedOut, PickupBy: pickupBy})
+			if err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+			holds = append(holds, hold)
+		}
+		if holds[0].Status != models.HoldReady || *holds[0].CopyID != copies[0].ID || holds[1].Status != models.HoldWaiting || holds[1].Position != 1 {
+			t.Fatalf("Reserve() = %+v, %+v, want the first ready and the second waiting", holds[0], holds[1])
+		}
+
+		cancelled, err := store.CancelHold(t.Context(), holds[0].ID, checkedOut.Add(time.Hour), pickupBy)
+		if err != nil || cancelled.Status != models.HoldCancelled {
+			t.Fatalf("CancelHold() = %+v, %v, want cancelled", cancelled, err)
+		}
+		if got, err := store.GetHold(t.Context(), holds[1].ID); err != nil || got.Status != models.HoldReady || *got.CopyID != copies[0].ID {
+			t.Errorf("GetHold() second = %+v, %v, want ready with the copy", got, err)
+		}
+		if _, err := store.CancelHold(t.Context(), holds[0].ID, checkedOut.Add(time.Hour), pickupBy); !errors.Is(err, ErrHoldClosed) {
+			t.Errorf("CancelHold() twice error = %v, want ErrHoldClosed", err)
+		}
+		if _, err := store.CancelHold(t.Context(), holds[1].ID+100, checkedOut, pickupBy); !errors.Is(err, ErrHoldNotFound) || !errors.Is(err, ErrNotFound) {
+			t.Errorf("CancelHold() unknown error = %v, want ErrHoldNotFound", err)
+		}
+	})
+
+	t.Run("a new copy is set aside for the first waiting holder", func(t *testing.T) {
+		store := newStore(t)
+		book, _, _ := setup(t, store, 0)
+		members := newMembers(t, store, 2)
+
+		var holds []*models.Hold
+		for i, m := range members {
+			hold, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID, MemberID: m.ID, At: checkedOut.Add(time.Duration(i) * time.Hour), PickupBy: pickupBy})
+			if err != nil || hold.Status != models.HoldWaiting {
+				t.Fatalf("Reserve() = %+v, %v, want waiting", hold, err)
+			}
+			holds = append(holds, hold)
+		}
+
+		addedAt := checkedOut.AddDate(0, 0, 1)
+		added, err := store.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: "DUNE-NEW"}, addedAt, addedAt.AddDate(0, 0, 3))
+		if err != nil {
+			t.Fatalf("AddCopy() error = %v", err)
+		}
+		if got, err := store.GetHold(t.Context(), holds[0].ID); err != nil || got.Status != models.HoldReady || *got.CopyID != added.ID || !got.PickupBy.Equal(addedAt.AddDate(0, 0, 3)) {
+			t.Errorf("GetHold() first = %+v, %v, want ready with the new copy", got, err)
+		}
+		if got, err := store.GetHold(t.Context(), holds[1].ID); err != nil || got.Status != models.HoldWaiting || got.Position != 1 {
+			t.Errorf("GetHold() second = %+v, %v, want still waiting first in line", got, err)
+		}
+	})
+
+	t.Run("concurrent returns and reserves keep the queue in order", func(t *testing.T) {
+		store := newStore(t)
+		book, copies, borrower := setup(t, store, 4)
+		members := newMembers(t, store, 6)
+
+		var loans []*models.Loan
+		for range copies {
+			loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: borrower.ID, At: checkedOut, DueAt: due})
+			if err != nil {
+				t.Fatalf("Checkout() error = %v", err)
+			}
+			loans = append(loans, loan)
+		}
+
+		var wg sync.WaitGroup
+		for _, m := range members {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID, MemberID: m.ID, At: checkedOut, PickupBy: pickupBy}); err != nil {
+					t.Errorf("Reserve() error = %v", err)
+				}
+			}()
+		}
+		for _, loan := range loans {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := store.ReturnLoan(t.Context(), loan.ID, due, pickupBy); err != nil {
+					t.Errorf("ReturnLoan() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Returns that came before the reserves left copies free; the sweep
+		// sets those aside as well.
+		if _, err := store.ExpireHolds(t.Context(), due, pickupBy); err != nil {
+			t.Fatalf("ExpireHolds() error = %v", err)
+		}
+
+		queue, err := store.ListHolds(t.Context(), book.ID)
+		if err != nil || len(queue) != len(members) {
+			t.Fatalf("ListHolds() = %+v, %v, want %d holds", queue, err, len(members))
+		}
+		setAside := map[int]bool{}
+		for i, h := range queue {
+			if i < len(copies) {
+				if h.Status != models.HoldReady || h.CopyID == nil || setAside[*h.CopyID] {
+					t.Errorf("hold %d = %+v, want ready with a copy of its own", i, h)
+				} else {
+					setAside[*h.CopyID] = true
+				}
+				continue
+			}
+			if h.Status != models.HoldWaiting || h.Position != i-len(copies)+1 {
+				t.Errorf("hold %d = %+v, want waiting at position %d", i, h, i-len(copies)+1)
+			}
+		}
+	})
+
+	t.Run("concurrent reserves by one member queue them once", func(t *testing.T) {
+		store := newStore(t)
+		book, _, member := setup(t, store, 0)
+
+		const workers = 5
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			reserved int
+		)
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID, MemberID: member.ID, At: checkedOut, PickupBy: pickupBy})
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					reserved++
+				case !errors.Is(err, ErrHoldExists):
+					t.Errorf("Reserve() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if reserved != 1 {
+			t.Errorf("%d reserves succeeded, want 1", reserved)
+		}
+	})
+
+	t.Run("members default to the standard type", func(t *testing.T) {
+		store := newStore(t)
+		_, _, member := setup(t, store, 0)
+		if member.Type != models.MemberStandard {
+			t.Errorf("CreateMember() type = %q, want %q", member.Type, models.MemberStandard)
+		}
+		student, err := store.CreateMember(t.Context(), &models.MemberRequest{Name: "Jessica", Email: "jessica@example.com", Type: models.MemberStudent})
+		if err != nil {
+			t.Fatalf("CreateMember() error = %v", err)
+		}
+		if got, err := store.GetMember(t.Context(), student.ID); err != nil || got.Type != models.MemberStudent {
+			t.Errorf("GetMember() = %+v, %v, want a student", got, err)
+		}
+	})
+
+	t.Run("fines accrue, settle and are paid", func(t *testing.T) {
+		store := newStore(t)
+		book, _, member := setup(t, store, 2)
+		onTime := newMembers(t, store, 1)[0]
+
+		loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due})
+		if err != nil {
+			t.Fatalf("Checkout() error = %v", err)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: onTime.ID, At: checkedOut, DueAt: due.AddDate(0, 1, 0)}); err != nil {
+			t.Fatalf("Checkout() error = %v", err)
+		}
+
+		later := due.AddDate(0, 0, 5)
+		overdue, err := store.OverdueLoans(t.Context(), later)
+		if err != nil || len(overdue) != 1 || overdue[0].ID != loan.ID || overdue[0].MemberType != models.MemberStandard {
+			t.Fatalf("OverdueLoans() = %+v, %v, want the late loan only", overdue, err)
+		}
+
+		fine, err := store.AssessFine(t.Context(), models.Assessment{LoanID: loan.ID, MemberID: member.ID, Days: 4, Amount: 100, At: later})
+		if err != nil || fine.Amount != 100 || fine.Final {
+			t.Fatalf("AssessFine() = %+v, %v, want 100 and not final", fine, err)
+		}
+		if got, err := store.AssessFine(t.Context(), models.Assessment{LoanID: loan.ID, MemberID: member.ID, Days: 3, Amount: 75, At: later.Add(-time.Hour)}); err != nil || got.Amount != 100 {
+			t.Errorf("AssessFine() older = %+v, %v, want the newer 100 kept", got, err)
+		}
+
+		returnedAt := later.Add(time.Hour)
+		if _, err := store.ReturnLoan(t.Context(), loan.ID, returnedAt, pickupBy); err != nil {
+			t.Fatalf("ReturnLoan() error = %v", err)
+		}
+		if overdue, err := store.OverdueLoans(t.Context(), later); err != nil || len(overdue) != 1 || overdue[0].ReturnedAt == nil {
+			t.Errorf("OverdueLoans() after a late return = %+v, %v, want it until its fine is final", overdue, err)
+		}
+		final, err := store.AssessFine(t.Context(), models.Assessment{LoanID: loan.ID, MemberID: member.ID, Days: 5, Amount: 125, Final: true, At: returnedAt})
+		if err != nil || final.ID != fine.ID || final.Amount != 125 || !final.Final {
+			t.Fatalf("AssessFine() final = %+v, %v, want fine %d settled at 125", final, err, fine.ID)
+		}
+		if got, err := store.AssessFine(t.Context(), models.Assessment{LoanID: loan.ID, MemberID: member.ID, Days: 9, Amount: 225, At: returnedAt.Add(time.Hour)}); err != nil || got.Amount != 125 {
+			t.Errorf("AssessFine() after final = %+v, %v, want 125 kept", got, err)
+		}
+		if overdue, err := store.OverdueLoans(t.Context(), later); err != nil || len(overdue) != 0 {
+			t.Errorf("OverdueLoans() after the final fine = %+v, %v, want none", overdue, err)
+		}
+		if _, err := store.AssessFine(t.Context(), models.Assessment{LoanID: loan.ID + 100, MemberID: member.ID, At: later}); !errors.Is(err, ErrLoanNotFound) {
+			t.Errorf("AssessFine() unknown loan error = %v, want ErrLoanNotFound", err)
+		}
+
+		if list, err := store.ListFines(t.Context(), member.ID); err != nil || len(list) != 1 || list[0].Balance() != 125 {
+			t.Errorf("ListFines() = %+v, %v, want one fine owing 125", list, err)
+		}
+		if list, err := store.ListFines(t.Context(), onTime.ID); err != nil || len(list) != 0 {
+			t.Errorf("ListFines() of a member with no fines = %+v, %v, want none", list, err)
+		}
+
+		paidAt := returnedAt.AddDate(0, 0, 1)
+		paid, err := store.PayFine(t.Context(), fine.ID, paidAt)
+		if err != nil || paid.Paid != 125 || paid.Balance() != 0 || paid.PaidAt == nil || !paid.PaidAt.Equal(paidAt) {
+			t.Fatalf("PayFine() = %+v, %v, want it paid at %v", paid, err, paidAt)
+		}
+		if _, err := store.PayFine(t.Context(), fine.ID, paidAt); !errors.Is(err, ErrFinePaid) {
+			t.Errorf("PayFine() twice error = %v, want ErrFinePaid", err)
+		}
+		if _, err := store.PayFine(t.Context(), fine.ID+100, paidAt); !errors.Is(err, ErrFineNotFound) {
+			t.Errorf("PayFine() unknown error = %v, want ErrFineNotFound", err)
+		}
+		if got, err := store.GetFine(t.Context(), fine.ID); err != nil || got.Paid != 125 {
+			t.Errorf("GetFine() = %+v, %v, want it paid", got, err)
+		}
+	})
+
+	t.Run("closures", func(t *testing.T) {
+		store := newStore(t)
+		for _, day := range []string{"2025-12-26", "2025-12-25", "2026-01-01"} {
+			if _, err := store.AddClosure(t.Context(), &models.Closure{Day: day, Reason: "Holiday"}); err != nil {
+				t.Fatalf("AddClosure(%s) error = %v", day, err)
+			}
+		}
+		if _, err := store.AddClosure(t.Context(), &models.Closure{Day: "2025-12-25"}); !errors.Is(err, ErrClosureExists) {
+			t.Errorf("AddClosure() twice error = %v, want ErrClosureExists", err)
+		}
+
+		list, err := store.ListClosures(t.Context(), "2025-12-25", "2025-12-31")
+		if err != nil {
+			t.Fatalf("ListClosures() error = %v", err)
+		}
+		if got := []string{list[0].Day, list[len(list)-1].Day}; len(list) != 2 || !slices.Equal(got, []string{"2025-12-25", "2025-12-26"}) || list[0].Reason != "Holiday" {
+			t.Errorf("ListClosures() = %+v, want Christmas and Boxing Day in order", list)
+		}
+
+		if err := store.DeleteClosure(t.Context(), "2025-12-26"); err != nil {
+			t.Fatalf("DeleteClosure() error = %v", err)
+		}
+		if err := store.DeleteClosure(t.Context(), "2025-12-26"); !errors.Is(err, ErrClosureNotFound) {
+			t.Errorf("DeleteClosure() twice error = %v, want ErrClosureNotFound", err)
+		}
+		if list, err := store.ListClosures(t.Context(), "2025-01-01", "2026-12-31"); err != nil || len(list) != 2 {
+			t.Errorf("ListClosures() after delete = %+v, %v, want 2", list, err)
+		}
+	})
+
+	t.Run("books on loan cannot be deleted and lent books are not purged", func(t *testing.T) {
+		store := newStore(t)
+		book, _, member := setup(t, store, 1)
+
+		loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due})
+		if err != nil {
+			t.Fatalf("Checkout() error = %v", err)
+		}
+		if err := store.DeleteBook(t.Context(), book.ID, nil); !errors.Is(err, ErrBookOnLoan) || !errors.Is(err, ErrConflict) {
+			t.Errorf("DeleteBook() with a copy on loan error = %v, want ErrBookOnLoan", err)
+		}
+		if _, err := store.ReturnLoan(t.Context(), loan.ID, due, pickupBy); err != nil {
+			t.Fatalf("ReturnLoan() error = %v", err)
+		}
+		if err := store.DeleteBook(t.Context(), book.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() after the return error = %v", err)
+		}
+		if _, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Checkout() of a trashed book error = %v, want ErrNotFound", err)
+		}
+
+		if n, err := store.PurgeDeletedBooks(t.Context(), time.Now().Add(time.Hour)); err != nil || n != 0 {
+			t.Fatalf("PurgeDeletedBooks() = %d, %v, want the lent book kept", n, err)
+		}
+		if got, err := store.GetLoan(t.Context(), loan.ID); err != nil || got.ReturnedAt == nil {
+			t.Errorf("GetLoan() after purge = %+v, %v, want the loan kept", got, err)
+		}
+		if _, err := store.RestoreBook(t.Context(), book.ID); err != nil {
+			t.Errorf("RestoreBook() of the kept book error = %v", err)
+		}
+	})
+
+	t.Run("fines outlive a purge of the fined book", func(t *testing.T) {
+		store := newStore(t)
+		book, _, member := setup(t, store, 1)
+
+		loan, err := store.Checkout(t.Context(), models.Checkout{BookID: book.ID, MemberID: member.ID, At: checkedOut, DueAt: due})
+		if err != nil {
+			t.Fatalf("Checkout() error = %v", err)
+		}
+		returnedAt := due.AddDate(0, 0, 3)
+		if _, err := store.ReturnLoan(t.Context(), loan.ID, returnedAt, pickupBy); err != nil {
+			t.Fatalf("ReturnLoan() error = %v", err)
+		}
+		fine, err := store.AssessFine(t.Context(), models.Assessment{LoanID: loan.ID, MemberID: member.ID, Days: 2, Amount: 50, Final: true, At: returnedAt})
+		if err != nil {
+			t.Fatalf("AssessFine() error = %v", err)
+		}
+		if err := store.DeleteBook(t.Context(), book.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() error = %v", err)
+		}
+		if _, err := store.PurgeDeletedBooks(t.Context(), time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("PurgeDeletedBooks() error = %v", err)
+		}
+
+		if got, err := store.GetFine(t.Context(), fine.ID); err != nil || got.Balance() != 50 {
+			t.Errorf("GetFine() after purge = %+v, %v, want 50 still owed", got, err)
+		}
+		if list, err := store.ListFines(t.Context(), member.ID); err != nil || len(list) != 1 || list[0].ID != fine.ID {
+			t.Errorf("ListFines() after purge = %+v, %v, want the fine kept", list, err)
+		}
+	})
+
+	t.Run("purge drops the copies of books never lent and their holds", func(t *testing.T) {
+		store := newStore(t)
+		_, _, member := setup(t, store, 0)
+		book, err := store.CreateBook(t.Context(), &models.CreateBookRequest{
+			Title: "Children of Dune", Author: "Frank Herbert", ISBN: "9780306406157", Pages: 444, Published: checkedOut.AddDate(-50, 0, 0),
+		})
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if _, err := store.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: "CHILDREN-A"}, checkedOut, pickupBy); err != nil {
+			t.Fatalf("AddCopy() error = %v", err)
+		}
+		hold, err := store.Reserve(t.Context(), models.Reserve{BookID: book.ID, MemberID: member.ID, At: checkedOut, PickupBy: pickupBy})
+		if err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+		if err := store.DeleteBook(t.Context(), book.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() error = %v", err)
+		}
+		if n, err := store.PurgeDeletedBooks(t.Context(), time.Now().Add(time.Hour)); err != nil || n != 1 {
+			t.Fatalf("PurgeDeletedBooks() = %d, %v, want 1", n, err)
+		}
+		if _, err := store.GetHold(t.Context(), hold.ID); !errors.Is(err, ErrHoldNotFound) {
+			t.Errorf("GetHold() after purge error = %v, want ErrHoldNotFound", err)
+		}
+		if _, err := store.ListCopies(t.Context(), book.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ListCopies() after purge error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestMemoryLendingStore(t *testing.T) {
+	runLendingStoreConformance(t, func(t *testing.T) lendingBookStore {
+		return NewMemoryBookStore()
+	})
+}
+
+// TestLoanRepository runs the lending suite against Postgres. It needs
+// TEST_DATABASE_URL pointing at a disposable database.
+func TestLoanRepository(t *testing.T) {
+	db := openTestDatabase(t)
+	runLendingStoreConformance(t, func(t *testing.T) lendingBookStore {
+		return newTestRepository(t, db)
+	})
+}
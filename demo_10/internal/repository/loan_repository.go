@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"book-service/internal/models"
+)
+
+const (
+	memberColumns = "id, name, email, type, created_at"
+	copyColumns   = "id, book_id, barcode, created_at, " +
+		"EXISTS (SELECT 1 FROM loans WHERE loans.copy_id = copies.id AND loans.returned_at IS NULL)"
+	loanColumns = "id, copy_id, book_id, member_id, checked_out_at, due_at, returned_at"
+)
+
+func scanMember(row rowScanner) (*models.Member, error) {
+	var m models.Member
+	if err := row.Scan(&m.ID, &m.Name, &m.Email, &m.Type, &m.CreatedAt); err != nil {
+		return nil, translateError(err)
+	}
+	return &m, nil
+}
+
+func scanCopy(row rowScanner) (*models.Copy, error) {
+	var c models.Copy
+	if err := row.Scan(&c.ID, &c.BookID, &c.Barcode, &c.CreatedAt, &c.OnLoan); err != nil {
+		return nil, translateError(err)
+	}
+	return &c, nil
+}
+
+func scanLoan(row rowScanner) (*models.Loan, error) {
+	var l models.Loan
+	if err := row.Scan(&l.ID, &l.CopyID, &l.BookID, &l.MemberID, &l.CheckedOutAt, &l.DueAt, &l.ReturnedAt); err != nil {
+		return nil, translateError(err)
+	}
+	return &l, nil
+}
+
+func (r *BookRepository) CreateMember(ctx context.Context, req *models.MemberRequest) (*models.Member, error) {
+	ctx, end := r.startOp(ctx, "CreateMember")
+	defer end()
+
+	query := `
+		INSERT INTO members (name, email, type, created_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		RETURNING ` + memberColumns
+	ctx, span := startQuery(ctx, "members.insert", query)
+	defer span.End()
+
+	member, err := scanMember(r.db.QueryRowContext(ctx, query, req.Name, req.Email, memberType(req.Type)))
+	return member, specificError(recordError(ctx, span, err), ErrMemberNotFound, ErrMemberExists)
+}
+
+func (r *BookRepository) GetMember(ctx context.Context, id int) (*models.Member, error) {
+	ctx, end := r.startOp(ctx, "GetMember")
+	defer end()
+
+	query := `SELECT ` + memberColumns + ` FROM members WHERE id = $1`
+	ctx, span := startQuery(ctx, "members.select_by_id", query)
+	defer span.End()
+
+	member, err := scanMember(r.db.QueryRowContext(ctx, query, id))
+	return member, specificError(recordError(ctx, span, err), ErrMemberNotFound, ErrConflict)
+}
+
+// AddCopy sets the new copy aside for the book's first waiting holder in
+// the same transaction.
+func (r *BookRepository) AddCopy(ctx context.Context, bookID int, req *models.CopyRequest, at, pickupBy time.Time) (*models.Copy, error) {
+	ctx, end := r.startOp(ctx, "AddCopy")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	if err := lockQueue(ctx, tx, bookID, true); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO copies (book_id, barcode, created_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		RETURNING ` + copyColumns
+	qctx, span := startQuery(ctx, "copies.insert", query)
+	added, err := scanCopy(tx.QueryRowContext(qctx, query, bookID, req.Barcode))
+	err = specificError(recordError(qctx, span, err), ErrNotFound, ErrCopyExists)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fillHolds(ctx, tx, bookID, at, pickupBy); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return added, nil
+}
+
+func (r *BookRepository) ListCopies(ctx context.Context, bookID int) ([]models.Copy, error) {
+	ctx, end := r.startOp(ctx, "ListCopies")
+	defer end()
+
+	exists := `SELECT EXISTS (SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL)`
+	qctx, span := startQuery(ctx, "books.exists_live", exists)
+	var live bool
+	err := recordError(qctx, span, translateError(r.db.QueryRowContext(qctx, exists, bookID).Scan(&live)))
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	if !live {
+		return nil, ErrNotFound
+	}
+
+	query := `SELECT ` + copyColumns + ` FROM copies WHERE book_id = $1 ORDER BY id`
+	ctx, span = startQuery(ctx, "copies.list", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	copies := []models.Copy{}
+	for rows.Next() {
+		c, err := scanCopy(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		copies = append(copies, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return copies, nil
+}
+
+// Checkout locks the copy it lends, so concurrent checkouts of the same copy
+// queue up and the second finds it on loan. Picking a copy skips those other
+// checkouts hold, and the partial unique index on open loans backs this up.
+// The share lock on the book keeps its holds still until tx ends.
+func (r *BookRepository) Checkout(ctx context.Context, c models.Checkout) (*models.Loan, error) {
+	ctx, end := r.startOp(ctx, "Checkout")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	if err := lockMember(ctx, tx, c.MemberID); err != nil {
+		return nil, err
+	}
+	if err := shareLiveBook(ctx, tx, c.BookID); err != nil {
+		return nil, err
+	}
+
+	copyID := c.CopyID
+	held, err := readyHold(ctx, tx, c.BookID, c.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case held != 0 && (copyID == 0 || copyID == held):
+		copyID = held
+	case copyID != 0:
+		if err := lockCopy(ctx, tx, c.BookID, copyID); err != nil {
+			return nil, err
+		}
+		onLoan, err := copyOnLoan(ctx, tx, copyID)
+		if err != nil {
+			return nil, err
+		}
+		if onLoan {
+			return nil, ErrCopyOnLoan
+		}
+		onHold, err := copyOnHold(ctx, tx, copyID)
+		if err != nil {
+			return nil, err
+		}
+		if onHold {
+			return nil, ErrCopyOnHold
+		}
+	default:
+		if copyID, err = pickCopy(ctx, tx, c.BookID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		INSERT INTO loans (copy_id, book_id, member_id, checked_out_at, due_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + loanColumns
+	qctx, span := startQuery(ctx, "loans.insert", query)
+	loan, err := scanLoan(tx.QueryRowContext(qctx, query, copyID, c.BookID, c.MemberID, c.At, c.DueAt))
+	recordError(qctx, span, err)
+	span.End()
+	if err != nil {
+		return nil, specificError(err, ErrLoanNotFound, ErrCopyOnLoan)
+	}
+	if err := fulfilHolds(ctx, tx, c.BookID, c.MemberID, copyID, c.At); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return loan, nil
+}
+
+// lockMember locks a member's row for the rest of tx, so that one member's
+// checkouts are serialized.
+func lockMember(ctx context.Context, tx *sql.Tx, id int) error {
+	query := `SELECT 1 FROM members WHERE id = $1 FOR UPDATE`
+	ctx, span := startQuery(ctx, "members.select_for_update", query)
+	defer span.End()
+
+	var one int
+	err := translateError(tx.QueryRowContext(ctx, query, id).Scan(&one))
+	return specificError(recordError(ctx, span, err), ErrMemberNotFound, ErrConflict)
+}
+
+// shareLiveBook fails with ErrNotFound unless the book is live, and keeps it
+// from being trashed until tx ends.
+func shareLiveBook(ctx context.Context, tx *sql.Tx, id int) error {
+	query := `SELECT 1 FROM books WHERE id = $1 AND deleted_at IS NULL FOR SHARE`
+	ctx, span := startQuery(ctx, "books.select_live_for_share", query)
+	defer span.End()
+
+	var one int
+	return recordError(ctx, span, translateError(tx.QueryRowContext(ctx, query, id).Scan(&one)))
+}
+
+func lockCopy(ctx context.Context, tx *sql.Tx, bookID, id int) error {
+	query := `SELECT 1 FROM copies WHERE id = $1 AND book_id = $2 FOR UPDATE`
+	ctx, span := startQuery(ctx, "copies.select_for_update", query)
+	defer span.End()
+
+	var one int
+	err := translateError(tx.QueryRowContext(ctx, query, id, bookID).Scan(&one))
+	return specificError(recordError(ctx, span, err), ErrCopyNotFound, ErrConflict)
+}
+
+// copyOnLoan runs as its own statement after the copy is locked, so it sees
+// loans committed while the lock was waited for.
+func copyOnLoan(ctx context.Context, tx *sql.Tx, id int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM loans WHERE copy_id = $1 AND returned_at IS NULL)`
+	ctx, span := startQuery(ctx, "loans.exists_open", query)
+	defer span.End()
+
+	var onLoan bool
+	err := tx.QueryRowContext(ctx, query, id).Scan(&onLoan)
+	return onLoan, recordError(ctx, span, translateError(err))
+}
+
+// pickCopy locks the first copy of a book that is neither on loan nor on
+// hold. A copy can look free to the query's snapshot yet have been lent by
+// a checkout that committed just before the lock was taken; those are
+// rechecked and passed over.
+func pickCopy(ctx context.Context, tx *sql.Tx, bookID int) (int, error) {
+	query := `
+		SELECT id FROM copies
+		WHERE book_id = $1 AND id <> ALL ($2)
+			AND NOT EXISTS (SELECT 1 FROM loans WHERE loans.copy_id = copies.id AND loans.returned_at IS NULL)
+			AND NOT EXISTS (SELECT 1 FROM holds WHERE holds.copy_id = copies.id AND holds.status = 'ready')
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+
+	passed := []int64{}
+	for {
+		qctx, span := startQuery(ctx, "copies.pick_for_update", query)
+		var id int
+		err := translateError(tx.QueryRowContext(qctx, query, bookID, pq.Array(passed)).Scan(&id))
+		recordError(qctx, span, err)
+		span.End()
+		if errors.Is(err, ErrNotFound) {
+			return 0, ErrNoCopyAvailable
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		onLoan, err := copyOnLoan(ctx, tx, id)
+		if err != nil {
+			return 0, err
+		}
+		if !onLoan {
+			return id, nil
+		}
+		passed = append(passed, int64(id))
+	}
+}
+
+// ReturnLoan sets the returned copy aside for the book's first waiting
+// holder in the same transaction.
+func (r *BookRepository) ReturnLoan(ctx context.Context, id int, at, pickupBy time.Time) (*models.Loan, error) {
+	ctx, end := r.startOp(ctx, "ReturnLoan")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, txError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE loans SET returned_at = $2
+		WHERE id = $1 AND returned_at IS NULL
+		RETURNING ` + loanColumns
+	qctx, span := startQuery(ctx, "loans.return", query)
+	loan, err := scanLoan(tx.QueryRowContext(qctx, query, id, at))
+	recordError(qctx, span, err)
+	span.End()
+	if errors.Is(err, ErrNotFound) {
+		// Nothing was updated: either the loan does not exist or it is
+		// closed.
+		if _, err := r.getLoan(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrLoanReturned
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockQueue(ctx, tx, loan.BookID, false); err != nil {
+		return nil, err
+	}
+	if err := fillHolds(ctx, tx, loan.BookID, at, pickupBy); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, txError(ctx, err)
+	}
+	return loan, nil
+}
+
+func (r *BookRepository) GetLoan(ctx context.Context, id int) (*models.Loan, error) {
+	ctx, end := r.startOp(ctx, "GetLoan")
+	defer end()
+
+	return r.getLoan(ctx, id)
+}
+
+func (r *BookRepository) getLoan(ctx context.Context, id int) (*models.Loan, error) {
+	query := `SELECT ` + loanColumns + ` FROM loans WHERE id = $1`
+	ctx, span := startQuery(ctx, "loans.select_by_id", query)
+	defer span.End()
+
+	loan, err := scanLoan(r.db.QueryRowContext(ctx, query, id))
+	return loan, specificError(recordError(ctx, span, err), ErrLoanNotFound, ErrConflict)
+}
+
+func (r *BookRepository) ListLoans(ctx context.Context, params models.ListLoansParams) (*models.Page[models.Loan], error) {
+	ctx, end := r.startOp(ctx, "ListLoans")
+	defer end()
+
+	below, err := decodeLoanCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{params.MemberID, below}
+	query := `SELECT ` + loanColumns + ` FROM loans WHERE member_id = $1 AND id < $2`
+	switch params.Status {
+	case models.LoanReturned:
+		query += " AND returned_at IS NOT NULL"
+	case models.LoanActive:
+		args = append(args, params.Now)
+		query += fmt.Sprintf(" AND returned_at IS NULL AND due_at >= $%d", len(args))
+	case models.LoanOverdue:
+		args = append(args, params.Now)
+		query += fmt.Sprintf(" AND returned_at IS NULL AND due_at < $%d", len(args))
+	}
+	// Fetch one extra row to find out whether another page follows.
+	args = append(args, params.Limit+1)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	ctx, span := startQuery(ctx, "loans.list", query)
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	defer rows.Close()
+
+	loans := make([]models.Loan, 0, params.Limit+1)
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, recordError(ctx, span, err)
+		}
+		loans = append(loans, *loan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordError(ctx, span, translateError(err))
+	}
+	return loanPage(loans, params.Limit), nil
+}
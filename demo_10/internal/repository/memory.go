@@ -0,0 +1,748 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"book-service/internal/models"
+)
+
+// MemoryBookStore is a concurrency-safe BookStore kept entirely in process.
+// It mirrors the Postgres schema's behaviour: ISBNs are unique among live
+// books, column lengths are enforced and timestamps have microsecond
+// precision without a time zone.
+type MemoryBookStore struct {
+	mu    sync.RWMutex
+	books map[int]*models.Book
+	// isbns indexes live books only; trashed ones stay in books.
+	isbns map[string]int
+	// history holds each book's revisions, oldest first. It outlives purges.
+	history map[int][]revision
+	nextID  int
+	now     func() time.Time
+	// modified is when the last write, including a delete, happened.
+	modified time.Time
+
+	authors map[int]*models.Author
+	// authorIDs indexes authors by name; namesakes share an entry.
+	authorIDs    map[string][]int
+	nextAuthorID int
+
+	lending
+}
+
+func NewMemoryBookStore() *MemoryBookStore {
+	return &MemoryBookStore{
+		books:        map[int]*models.Book{},
+		isbns:        map[string]int{},
+		history:      map[int][]revision{},
+		nextID:       1,
+		now:          time.Now,
+		authors:      map[int]*models.Author{},
+		authorIDs:    map[string][]int{},
+		nextAuthorID: 1,
+		lending:      newLending(),
+	}
+}
+
+func (s *MemoryBookStore) CreateBook(ctx context.Context, book *models.CreateBookRequest) (*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credits := []credit{{Author: book.Author, AuthorIDs: book.AuthorIDs}}
+	undo, err := s.creditAuthors(credits)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkColumns(book.Title, credits[0].Author, book.ISBN); err != nil {
+		undo()
+		return nil, err
+	}
+	if _, ok := s.isbns[book.ISBN]; ok {
+		undo()
+		return nil, fmt.Errorf("%w: a book with this ISBN already exists", ErrConflict)
+	}
+
+	now := s.timestamp()
+	created := &models.Book{
+		ID:        s.nextID,
+		Title:     book.Title,
+		Author:    credits[0].Author,
+		AuthorIDs: credits[0].AuthorIDs,
+		ISBN:      book.ISBN,
+		Pages:     book.Pages,
+		Published: toTimestamp(book.Published),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+	s.nextID++
+	s.books[created.ID] = created
+	s.modified = now
+	s.isbns[created.ISBN] = created.ID
+	s.record(ctx, models.BookCreated, nil, created)
+	return copyBook(created), nil
+}
+
+func (s *MemoryBookStore) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	book, ok := s.books[id]
+	if !ok || book.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return copyBook(book), nil
+}
+
+func (s *MemoryBookStore) ListBooks(ctx context.Context, params models.ListBooksParams) (*models.Page[models.Book], error) {
+	spec, err := parseSort(params.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		after   interface{}
+		afterID int
+	)
+	if params.Cursor != "" {
+		if after, afterID, err = decodeCursor(params.Cursor, spec); err != nil {
+			return nil, err
+		}
+	}
+
+	// order reports how a sorts relative to b in the requested direction.
+	order := func(aValue interface{}, aID int, bValue interface{}, bID int) int {
+		c := spec.field.compare(aValue, bValue)
+		if c == 0 {
+			c = aID - bID
+		}
+		if spec.desc {
+			c = -c
+		}
+		return c
+	}
+
+	s.mu.RLock()
+	books := make([]models.Book, 0, len(s.books))
+	for _, book := range s.books {
+		if !matchesFilter(book, params.Filter) {
+			continue
+		}
+		if params.Cursor != "" && order(spec.field.get(book), book.ID, after, afterID) <= 0 {
+			continue
+		}
+		books = append(books, *book)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(books, func(a, b models.Book) int {
+		return order(spec.field.get(&a), a.ID, spec.field.get(&b), b.ID)
+	})
+
+	page := &models.Page[models.Book]{Data: books}
+	if len(books) > params.Limit {
+		page.Data = books[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(spec, &page.Data[params.Limit-1])
+	}
+
+	return page, nil
+}
+
+// SearchBooks matches query words as prefixes of title and author words. It
+// has no stemming, so lang is only validated; ranks weight title matches
+// above author matches like the Postgres search vector does.
+func (s *MemoryBookStore) SearchBooks(ctx context.Context, params models.SearchParams) (*models.Page[models.SearchResult], error) {
+	cfg, err := searchConfig(params.Lang)
+	if err != nil {
+		return nil, err
+	}
+	terms := searchTerms(params.Query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: query has no searchable words", ErrInvalidSearch)
+	}
+	tsquery := prefixTSQuery(terms)
+	offset, err := decodeSearchCursor(params.Cursor, tsquery, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var results []models.SearchResult
+	for _, book := range s.books {
+		if book.DeletedAt != nil {
+			continue
+		}
+		if rank, ok := memoryRank(book, terms); ok {
+			results = append(results, models.SearchResult{
+				Book: *book,
+				Rank: rank,
+				Highlight: models.SearchHighlight{
+					Title:  highlight(book.Title, terms),
+					Author: highlight(book.Author, terms),
+				},
+			})
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(results, func(a, b models.SearchResult) int {
+		if c := cmp.Compare(b.Rank, a.Rank); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+
+	page := &models.Page[models.SearchResult]{Data: []models.SearchResult{}}
+	if offset < len(results) {
+		page.Data = results[offset:]
+	}
+	if len(page.Data) > params.Limit {
+		page.Data = page.Data[:params.Limit]
+		page.HasMore = true
+		page.NextCursor = encodeSearchCursor(tsquery, cfg, offset+params.Limit)
+	}
+	return page, nil
+}
+
+func memoryRank(book *models.Book, terms []string) (float64, bool) {
+	titleWords, authorWords := searchTerms(book.Title), searchTerms(book.Author)
+	rank := 0.0
+	for _, term := range terms {
+		title, author := countPrefixed(titleWords, term), countPrefixed(authorWords, term)
+		if title+author == 0 {
+			return 0, false
+		}
+		rank += float64(title) + 0.4*float64(author)
+	}
+	return rank, true
+}
+
+func countPrefixed(words []string, prefix string) int {
+	n := 0
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// highlight wraps every word of text that starts with one of the terms in
+// highlight markers, leaving the rest of the text as is.
+func highlight(text string, terms []string) string {
+	var b strings.Builder
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWord(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWord(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		lower := strings.ToLower(word)
+		if slices.ContainsFunc(terms, func(t string) bool { return strings.HasPrefix(lower, t) }) {
+			b.WriteString(highlightStart + word + highlightStop)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func (s *MemoryBookStore) UpdateBook(ctx context.Context, id int, book *models.UpdateBookRequest, ifMatch []int) (*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.books[id]
+	if !ok || current.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	if !versionMatches(current.Version, ifMatch) {
+		return nil, ErrPreconditionFailed
+	}
+
+	return s.writeUpdate(ctx, current, book, models.BookUpdated)
+}
+
+func (s *MemoryBookStore) RevertBook(ctx context.Context, id, rev int, ifMatch []int) (*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.books[id]
+	if !ok || current.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	if !versionMatches(current.Version, ifMatch) {
+		return nil, ErrPreconditionFailed
+	}
+
+	i := slices.IndexFunc(s.history[id], func(r revision) bool { return r.Revision == rev })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: revision %d does not exist", ErrNotFound, rev)
+	}
+	return s.writeUpdate(ctx, current, s.history[id][i].State.updateRequest(), models.BookReverted)
+}
+
+func (s *MemoryBookStore) BookHistory(ctx context.Context, id int, params models.HistoryParams) (*models.Page[models.BookRevision], error) {
+	below, err := decodeHistoryCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.history[id]
+	if len(history) == 0 {
+		return nil, ErrNotFound
+	}
+	revisions := make([]models.BookRevision, 0, params.Limit+1)
+	for i := len(history) - 1; i >= 0 && len(revisions) <= params.Limit; i-- {
+		if history[i].Revision < below {
+			revisions = append(revisions, history[i].BookRevision)
+		}
+	}
+	return historyPage(revisions, params.Limit), nil
+}
+
+// writeUpdate applies req to current and records the change under action.
+// Callers hold s.mu.
+func (s *MemoryBookStore) writeUpdate(ctx context.Context, current *models.Book, req *models.UpdateBookRequest, action models.BookAction) (*models.Book, error) {
+	updated := *current
+	applyUpdate(&updated, req)
+	updated.Published = toTimestamp(updated.Published)
+
+	undo := func() {}
+	if c, ok := updateCredit(&updated, req); ok {
+		credits := []credit{c}
+		var err error
+		if undo, err = s.creditAuthors(credits); err != nil {
+			return nil, err
+		}
+		updated.Author, updated.AuthorIDs = credits[0].Author, credits[0].AuthorIDs
+	}
+
+	if err := checkColumns(updated.Title, updated.Author, updated.ISBN); err != nil {
+		undo()
+		return nil, err
+	}
+	if owner, ok := s.isbns[updated.ISBN]; ok && owner != current.ID {
+		undo()
+		return nil, fmt.Errorf("%w: a book with this ISBN already exists", ErrConflict)
+	}
+
+	updated.UpdatedAt = s.timestamp()
+	updated.Version++
+	delete(s.isbns, current.ISBN)
+	s.isbns[updated.ISBN] = current.ID
+	s.books[current.ID] = &updated
+	s.modified = updated.UpdatedAt
+	s.record(ctx, action, current, &updated)
+	return copyBook(&updated), nil
+}
+
+// record appends the change from before to after to the book's history.
+// Callers hold s.mu.
+func (s *MemoryBookStore) record(ctx context.Context, action models.BookAction, before, after *models.Book) {
+	s.history[after.ID] = append(s.history[after.ID], newRevision(ctx, action, before, after))
+}
+
+func (s *MemoryBookStore) DeleteBook(ctx context.Context, id int, ifMatch []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book, ok := s.books[id]
+	if !ok || book.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if !versionMatches(book.Version, ifMatch) {
+		return ErrPreconditionFailed
+	}
+	if s.bookOnLoan(id) {
+		return ErrBookOnLoan
+	}
+
+	now := s.timestamp()
+	trashed := *book
+	trashed.DeletedAt = &now
+	trashed.UpdatedAt = now
+	trashed.Version++
+	delete(s.isbns, book.ISBN)
+	s.books[id] = &trashed
+	s.modified = now
+	s.record(ctx, models.BookDeleted, book, &trashed)
+	return nil
+}
+
+func (s *MemoryBookStore) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book, ok := s.books[id]
+	if !ok || book.DeletedAt == nil {
+		return nil, ErrNotFound
+	}
+	if _, taken := s.isbns[book.ISBN]; taken {
+		return nil, fmt.Errorf("%w: a book with this ISBN already exists", ErrConflict)
+	}
+
+	restored := *book
+	restored.DeletedAt = nil
+	restored.UpdatedAt = s.timestamp()
+	restored.Version++
+	s.books[id] = &restored
+	s.isbns[restored.ISBN] = id
+	s.modified = restored.UpdatedAt
+	s.record(ctx, models.BookRestored, book, &restored)
+	return copyBook(&restored), nil
+}
+
+func (s *MemoryBookStore) PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for id, book := range s.books {
+		if book.DeletedAt != nil && book.DeletedAt.Before(cutoff) && !s.bookLent(id) {
+			delete(s.books, id)
+			s.purgeLending(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryBookStore) UpsertBooks(ctx context.Context, books []models.CreateBookRequest, dryRun bool) ([]models.UpsertResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credits := make([]credit, len(books))
+	for i := range books {
+		var existing *models.Book
+		if id, ok := s.isbns[books[i].ISBN]; ok {
+			existing = s.books[id]
+		}
+		credits[i] = importCredit(&books[i], existing)
+	}
+	undo, err := s.creditAuthors(credits)
+	if err != nil {
+		return nil, err
+	}
+	// A dry run must not leave the authors it credited behind.
+	if dryRun {
+		defer undo()
+	}
+	for i, b := range books {
+		if err := checkColumns(b.Title, credits[i].Author, b.ISBN); err != nil {
+			undo()
+			return nil, err
+		}
+	}
+
+	now := s.timestamp()
+	results := make([]models.UpsertResult, len(books))
+	for i, b := range books {
+		results[i].ISBN = b.ISBN
+		published := toTimestamp(b.Published)
+
+		id, exists := s.isbns[b.ISBN]
+		if !exists {
+			results[i].Outcome = models.UpsertCreated
+			if dryRun {
+				continue
+			}
+			book := &models.Book{
+				ID:        s.nextID,
+				Title:     b.Title,
+				Author:    credits[i].Author,
+				AuthorIDs: credits[i].AuthorIDs,
+				ISBN:      b.ISBN,
+				Pages:     b.Pages,
+				Published: published,
+				CreatedAt: now,
+				UpdatedAt: now,
+				Version:   1,
+			}
+			s.nextID++
+			s.books[book.ID] = book
+			s.isbns[book.ISBN] = book.ID
+			s.modified = now
+			s.record(ctx, models.BookImported, nil, book)
+			results[i].ID, results[i].Version = book.ID, book.Version
+			continue
+		}
+
+		current := s.books[id]
+		if current.Title == b.Title && current.Author == credits[i].Author && current.Pages == b.Pages && current.Published.Equal(published) {
+			results[i].Outcome = models.UpsertUnchanged
+			continue
+		}
+		results[i].Outcome = models.UpsertUpdated
+		if dryRun {
+			continue
+		}
+		updated := *current
+		updated.Title, updated.Author, updated.Pages, updated.Published = b.Title, credits[i].Author, b.Pages, published
+		updated.AuthorIDs = credits[i].AuthorIDs
+		updated.UpdatedAt = now
+		updated.Version++
+		s.books[id] = &updated
+		s.modified = now
+		s.record(ctx, models.BookImported, current, &updated)
+		results[i].ID, results[i].Version = updated.ID, updated.Version
+	}
+
+	return results, nil
+}
+
+func (s *MemoryBookStore) CreateAuthor(ctx context.Context, req *models.AuthorRequest) (*models.Author, error) {
+	if err := checkLength(req.Name, 255); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	author := *s.insertAuthor(req.Name)
+	return &author, nil
+}
+
+func (s *MemoryBookStore) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	author, ok := s.authors[id]
+	if !ok {
+		return nil, ErrAuthorNotFound
+	}
+	result := *author
+	return &result, nil
+}
+
+func (s *MemoryBookStore) ListAuthors(ctx context.Context, params models.ListAuthorsParams) (*models.Page[models.Author], error) {
+	after, err := decodeAuthorCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	authors := make([]models.Author, 0, len(s.authors))
+	for _, author := range s.authors {
+		if author.ID <= after {
+			continue
+		}
+		if params.Name != "" && !strings.Contains(strings.ToLower(author.Name), strings.ToLower(params.Name)) {
+			continue
+		}
+		authors = append(authors, *author)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(authors, func(a, b models.Author) int { return a.ID - b.ID })
+	return authorPage(authors, params.Limit), nil
+}
+
+func (s *MemoryBookStore) UpdateAuthor(ctx context.Context, id int, req *models.AuthorRequest) (*models.Author, error) {
+	if err := checkLength(req.Name, 255); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.authors[id]
+	if !ok {
+		return nil, ErrAuthorNotFound
+	}
+
+	updated := *current
+	updated.Name = req.Name
+	updated.UpdatedAt = s.timestamp()
+	s.unindexAuthor(current)
+	s.authorIDs[updated.Name] = append(s.authorIDs[updated.Name], id)
+	s.authors[id] = &updated
+
+	result := updated
+	return &result, nil
+}
+
+func (s *MemoryBookStore) DeleteAuthor(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	author, ok := s.authors[id]
+	if !ok {
+		return ErrAuthorNotFound
+	}
+	for _, book := range s.books {
+		if slices.Contains(book.AuthorIDs, id) {
+			return ErrAuthorHasBooks
+		}
+	}
+	s.unindexAuthor(author)
+	delete(s.authors, id)
+	return nil
+}
+
+// creditAuthors resolves credits, creating the authors named in bylines that
+// do not exist yet. Unlike Postgres the store has no transaction to roll
+// back, so a write that fails afterwards calls undo to remove those authors
+// again. Callers hold s.mu.
+func (s *MemoryBookStore) creditAuthors(credits []credit) (undo func(), err error) {
+	var created []int
+	undo = func() {
+		for _, id := range created {
+			s.unindexAuthor(s.authors[id])
+			delete(s.authors, id)
+		}
+		created = nil
+	}
+
+	err = resolveCredits(credits,
+		func(ids []int) (map[int]string, error) {
+			names := make(map[int]string, len(ids))
+			for _, id := range ids {
+				if author, ok := s.authors[id]; ok {
+					names[id] = author.Name
+				}
+			}
+			return names, nil
+		},
+		func(names []string) (map[string]int, error) {
+			ids := make(map[string]int, len(names))
+			for _, name := range names {
+				switch named := s.authorIDs[name]; len(named) {
+				case 0:
+					if err := checkLength(name, 255); err != nil {
+						return nil, err
+					}
+					ids[name] = s.insertAuthor(name).ID
+					created = append(created, ids[name])
+				case 1:
+					ids[name] = named[0]
+				default:
+					return nil, fmt.Errorf("%w: %s", ErrAuthorAmbiguous, name)
+				}
+			}
+			return ids, nil
+		},
+	)
+	if err != nil {
+		undo()
+		return nil, err
+	}
+	return undo, nil
+}
+
+// insertAuthor adds an author. Callers hold s.mu.
+func (s *MemoryBookStore) insertAuthor(name string) *models.Author {
+	now := s.timestamp()
+	author := &models.Author{ID: s.nextAuthorID, Name: name, CreatedAt: now, UpdatedAt: now}
+	s.nextAuthorID++
+	s.authors[author.ID] = author
+	s.authorIDs[name] = append(s.authorIDs[name], author.ID)
+	return author
+}
+
+// unindexAuthor drops author from the name index. Callers hold s.mu.
+func (s *MemoryBookStore) unindexAuthor(author *models.Author) {
+	ids := slices.DeleteFunc(s.authorIDs[author.Name], func(id int) bool { return id == author.ID })
+	if len(ids) == 0 {
+		delete(s.authorIDs, author.Name)
+		return
+	}
+	s.authorIDs[author.Name] = ids
+}
+
+func (s *MemoryBookStore) ExportBooks(ctx context.Context, fn func(book *models.Book) error) error {
+	s.mu.RLock()
+	books := make([]models.Book, 0, len(s.books))
+	for _, book := range s.books {
+		if book.DeletedAt == nil {
+			books = append(books, *book)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(books, func(a, b models.Book) int { return a.ID - b.ID })
+	for i := range books {
+		if err := fn(&books[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryBookStore) LastModified(ctx context.Context) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.modified, nil
+}
+
+func (s *MemoryBookStore) timestamp() time.Time {
+	return toTimestamp(s.now().UTC())
+}
+
+func matchesFilter(book *models.Book, f models.BookFilter) bool {
+	if book.DeletedAt != nil && !f.IncludeDeleted {
+		return false
+	}
+	if f.Author != "" && !strings.Contains(strings.ToLower(book.Author), strings.ToLower(f.Author)) {
+		return false
+	}
+	if f.ISBNPrefix != "" && !strings.HasPrefix(book.ISBN, f.ISBNPrefix) {
+		return false
+	}
+	if f.AuthorID != 0 && !slices.Contains(book.AuthorIDs, f.AuthorID) {
+		return false
+	}
+	if f.PublishedFrom != nil && book.Published.Before(toTimestamp(*f.PublishedFrom)) {
+		return false
+	}
+	if f.PublishedTo != nil && book.Published.After(toTimestamp(*f.PublishedTo)) {
+		return false
+	}
+	if f.MinPages != nil && book.Pages < *f.MinPages {
+		return false
+	}
+	if f.MaxPages != nil && book.Pages > *f.MaxPages {
+		return false
+	}
+	return true
+}
+
+// checkColumns enforces the VARCHAR limits of the books table.
+func checkColumns(title, author, isbn string) error {
+	for _, c := range []struct {
+		value string
+		max   int
+	}{{title, 255}, {author, 255}, {isbn, 20}} {
+		if utf8.RuneCountInString(c.value) > c.max {
+			return fmt.Errorf("%w: value too long for type character varying(%d)", ErrValidation, c.max)
+		}
+	}
+	return nil
+}
+
+// toTimestamp converts t the way a Postgres TIMESTAMP column stores it: the
+// wall clock is kept, the zone is dropped and precision is microseconds.
+func toTimestamp(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Truncate(time.Microsecond)
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"book-service/internal/models"
+)
+
+func (s *MemoryBookStore) OverdueLoans(ctx context.Context, now time.Time) ([]models.OverdueLoan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var loans []models.OverdueLoan
+	for _, loan := range s.loans {
+		switch {
+		case loan.ReturnedAt == nil && loan.DueAt.Before(now):
+		case loan.ReturnedAt != nil && loan.ReturnedAt.After(loan.DueAt) && !s.finalFine(loan.ID):
+		default:
+			continue
+		}
+		loans = append(loans, models.OverdueLoan{Loan: *loan, MemberType: s.members[loan.MemberID].Type})
+	}
+	slices.SortFunc(loans, func(a, b models.OverdueLoan) int { return a.ID - b.ID })
+	return loans, nil
+}
+
+func (s *MemoryBookStore) AssessFine(ctx context.Context, a models.Assessment) (*models.Fine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.loans[a.LoanID]; !ok {
+		return nil, ErrLoanNotFound
+	}
+	at := toTimestamp(a.At)
+	fine := &models.Fine{ID: s.nextIDs.fine, LoanID: a.LoanID, MemberID: a.MemberID}
+	if id, ok := s.finedLoans[a.LoanID]; ok {
+		fine = s.fines[id]
+		// Like the conditional upsert in Postgres, leave a final or newer
+		// fine alone.
+		if fine.Final || fine.AssessedAt.After(at) {
+			result := *fine
+			return &result, nil
+		}
+	} else {
+		s.nextIDs.fine++
+		s.fines[fine.ID] = fine
+		s.finedLoans[a.LoanID] = fine.ID
+	}
+	fine.Days, fine.Amount, fine.Final, fine.AssessedAt = a.Days, a.Amount, a.Final, at
+
+	result := *fine
+	return &result, nil
+}
+
+func (s *MemoryBookStore) GetFine(ctx context.Context, id int) (*models.Fine, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fine, ok := s.fines[id]
+	if !ok {
+		return nil, ErrFineNotFound
+	}
+	result := *fine
+	return &result, nil
+}
+
+func (s *MemoryBookStore) ListFines(ctx context.Context, memberID int) ([]models.Fine, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fines := []models.Fine{}
+	for _, fine := range s.fines {
+		if fine.MemberID == memberID && fine.Amount > 0 {
+			fines = append(fines, *fine)
+		}
+	}
+	slices.SortFunc(fines, func(a, b models.Fine) int { return b.ID - a.ID })
+	return fines, nil
+}
+
+func (s *MemoryBookStore) PayFine(ctx context.Context, id int, at time.Time) (*models.Fine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fine, ok := s.fines[id]
+	if !ok {
+		return nil, ErrFineNotFound
+	}
+	if fine.Balance() <= 0 {
+		return nil, ErrFinePaid
+	}
+	paidAt := toTimestamp(at)
+	fine.Paid, fine.PaidAt = fine.Amount, &paidAt
+
+	result := *fine
+	return &result, nil
+}
+
+func (s *MemoryBookStore) AddClosure(ctx context.Context, c *models.Closure) (*models.Closure, error) {
+	if err := checkLength(c.Reason, 255); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.closures[c.Day]; ok {
+		return nil, ErrClosureExists
+	}
+	s.closures[c.Day] = *c
+
+	result := *c
+	return &result, nil
+}
+
+func (s *MemoryBookStore) ListClosures(ctx context.Context, from, to string) ([]models.Closure, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Days are YYYY-MM-DD, so they sort and compare as strings.
+	closures := []models.Closure{}
+	for day, c := range s.closures {
+		if day >= from && day <= to {
+			closures = append(closures, c)
+		}
+	}
+	slices.SortFunc(closures, func(a, b models.Closure) int { return strings.Compare(a.Day, b.Day) })
+	return closures, nil
+}
+
+func (s *MemoryBookStore) DeleteClosure(ctx context.Context, day string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.closures[day]; !ok {
+		return ErrClosureNotFound
+	}
+	delete(s.closures, day)
+	return nil
+}
+
+// finalFine reports whether a loan has a final fine. Callers hold s.mu.
+func (s *MemoryBookStore) finalFine(loanID int) bool {
+	id, ok := s.finedLoans[loanID]
+	return ok && s.fines[id].Final
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"book-service/internal/models"
+)
+
+func (s *MemoryBookStore) Reserve(ctx context.Context, r models.Reserve) (*models.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[r.MemberID]; !ok {
+		return nil, ErrMemberNotFound
+	}
+	if !s.live(r.BookID) {
+		return nil, ErrNotFound
+	}
+	for _, h := range s.holds {
+		if h.BookID == r.BookID && h.MemberID == r.MemberID && holdOpen(h) {
+			return nil, ErrHoldExists
+		}
+	}
+
+	hold := &models.Hold{
+		ID:        s.nextIDs.hold,
+		BookID:    r.BookID,
+		MemberID:  r.MemberID,
+		Status:    models.HoldWaiting,
+		CreatedAt: toTimestamp(r.At),
+	}
+	s.nextIDs.hold++
+	s.holds[hold.ID] = hold
+	s.fillHolds(r.BookID, r.At, r.PickupBy)
+
+	return s.holdView(hold), nil
+}
+
+func (s *MemoryBookStore) GetHold(ctx context.Context, id int) (*models.Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hold, ok := s.holds[id]
+	if !ok {
+		return nil, ErrHoldNotFound
+	}
+	return s.holdView(hold), nil
+}
+
+func (s *MemoryBookStore) CancelHold(ctx context.Context, id int, at, pickupBy time.Time) (*models.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hold, ok := s.holds[id]
+	if !ok {
+		return nil, ErrHoldNotFound
+	}
+	if !holdOpen(hold) {
+		return nil, ErrHoldClosed
+	}
+	s.closeHold(hold, models.HoldCancelled, at)
+	s.fillHolds(hold.BookID, at, pickupBy)
+
+	return s.holdView(hold), nil
+}
+
+func (s *MemoryBookStore) ListHolds(ctx context.Context, bookID int) ([]models.Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.live(bookID) {
+		return nil, ErrNotFound
+	}
+	holds := []models.Hold{}
+	for _, h := range s.holds {
+		if h.BookID == bookID && holdOpen(h) {
+			holds = append(holds, *s.holdView(h))
+		}
+	}
+	slices.SortFunc(holds, func(a, b models.Hold) int { return a.ID - b.ID })
+	return holds, nil
+}
+
+func (s *MemoryBookStore) ExpireHolds(ctx context.Context, at, pickupBy time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := 0
+	waiting := map[int]bool{}
+	for _, h := range s.holds {
+		if h.Status == models.HoldReady && h.PickupBy.Before(at) {
+			s.closeHold(h, models.HoldExpired, at)
+			expired++
+		}
+		if h.Status == models.HoldWaiting {
+			waiting[h.BookID] = true
+		}
+	}
+	for bookID := range waiting {
+		s.fillHolds(bookID, at, pickupBy)
+	}
+	return expired, nil
+}
+
+// fillHolds sets aside the free copies of a book for its waiting holders,
+// lowest copy id to the longest waiting. Callers hold s.mu.
+func (s *MemoryBookStore) fillHolds(bookID int, at, pickupBy time.Time) {
+	var queue []*models.Hold
+	for _, h := range s.holds {
+		if h.BookID == bookID && h.Status == models.HoldWaiting {
+			queue = append(queue, h)
+		}
+	}
+	slices.SortFunc(queue, func(a, b *models.Hold) int { return a.ID - b.ID })
+
+	free := s.freeCopies(bookID)
+	for i := 0; i < len(queue) && i < len(free); i++ {
+		readyAt, deadline, copyID := toTimestamp(at), toTimestamp(pickupBy), free[i]
+		h := queue[i]
+		h.Status, h.CopyID, h.ReadyAt, h.PickupBy = models.HoldReady, &copyID, &readyAt, &deadline
+		s.setAside[copyID] = h.ID
+	}
+}
+
+// freeCopies returns the ids of a book's copies that are neither on loan nor
+// on hold, in order. Callers hold s.mu.
+func (s *MemoryBookStore) freeCopies(bookID int) []int {
+	var ids []int
+	for id, c := range s.copies {
+		_, onLoan := s.openLoans[id]
+		_, onHold := s.setAside[id]
+		if c.BookID == bookID && !onLoan && !onHold {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// readyHold returns the member's ready hold on a book, if any. Callers hold
+// s.mu.
+func (s *MemoryBookStore) readyHold(bookID, memberID int) *models.Hold {
+	for _, h := range s.holds {
+		if h.BookID == bookID && h.MemberID == memberID && h.Status == models.HoldReady {
+			return h
+		}
+	}
+	return nil
+}
+
+// fulfilHolds closes the member's hold on a book once they have borrowed a
+// copy of it: a waiting one, or the ready one for that copy. Callers hold
+// s.mu.
+func (s *MemoryBookStore) fulfilHolds(bookID, memberID, copyID int, at time.Time) {
+	for _, h := range s.holds {
+		if h.BookID != bookID || h.MemberID != memberID {
+			continue
+		}
+		if h.Status == models.HoldWaiting || h.Status == models.HoldReady && *h.CopyID == copyID {
+			s.closeHold(h, models.HoldFulfilled, at)
+		}
+	}
+}
+
+// closeHold closes an open hold, releasing the copy set aside for it.
+// Callers hold s.mu.
+func (s *MemoryBookStore) closeHold(h *models.Hold, status models.HoldStatus, at time.Time) {
+	if h.Status == models.HoldReady {
+		delete(s.setAside, *h.CopyID)
+	}
+	closedAt := toTimestamp(at)
+	h.Status, h.ClosedAt = status, &closedAt
+}
+
+// holdView copies a hold and works out its place in the queue. Callers hold
+// s.mu.
+func (s *MemoryBookStore) holdView(h *models.Hold) *models.Hold {
+	result := *h
+	if h.Status == models.HoldWaiting {
+		for _, other := range s.holds {
+			if other.BookID == h.BookID && other.Status == models.HoldWaiting && other.ID <= h.ID {
+				result.Position++
+			}
+		}
+	}
+	return &result
+}
+
+func holdOpen(h *models.Hold) bool {
+	return h.Status == models.HoldWaiting || h.Status == models.HoldReady
+}
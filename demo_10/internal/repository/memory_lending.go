@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"book-service/internal/models"
+)
+
+// lending is the part of MemoryBookStore behind LendingStore. It shares the
+// store's lock so checkouts see books as they are.
+type lending struct {
+	members      map[int]*models.Member
+	memberEmails map[string]int
+	copies       map[int]*models.Copy
+	barcodes     map[string]int
+	loans        map[int]*models.Loan
+	holds        map[int]*models.Hold
+	fines        map[int]*models.Fine
+	closures     map[string]models.Closure
+	// openLoans maps a copy on loan to its open loan, setAside a copy on
+	// hold to its ready hold, and finedLoans a loan to its fine.
+	openLoans  map[int]int
+	setAside   map[int]int
+	finedLoans map[int]int
+	nextIDs    struct{ member, copy, loan, hold, fine int }
+}
+
+func newLending() lending {
+	l := lending{
+		members:      map[int]*models.Member{},
+		memberEmails: map[string]int{},
+		copies:       map[int]*models.Copy{},
+		barcodes:     map[string]int{},
+		loans:        map[int]*models.Loan{},
+		holds:        map[int]*models.Hold{},
+		fines:        map[int]*models.Fine{},
+		closures:     map[string]models.Closure{},
+		openLoans:    map[int]int{},
+		setAside:     map[int]int{},
+		finedLoans:   map[int]int{},
+	}
+	l.nextIDs.member, l.nextIDs.copy, l.nextIDs.loan, l.nextIDs.hold, l.nextIDs.fine = 1, 1, 1, 1, 1
+	return l
+}
+
+func (s *MemoryBookStore) CreateMember(ctx context.Context, req *models.MemberRequest) (*models.Member, error) {
+	if err := checkLength(req.Name, 255); err != nil {
+		return nil, err
+	}
+	if err := checkLength(req.Email, 255); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.memberEmails[req.Email]; ok {
+		return nil, ErrMemberExists
+	}
+	member := &models.Member{ID: s.nextIDs.member, Name: req.Name, Email: req.Email, Type: memberType(req.Type), CreatedAt: s.timestamp()}
+	s.nextIDs.member++
+	s.members[member.ID] = member
+	s.memberEmails[member.Email] = member.ID
+
+	result := *member
+	return &result, nil
+}
+
+func (s *MemoryBookStore) GetMember(ctx context.Context, id int) (*models.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	member, ok := s.members[id]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	result := *member
+	return &result, nil
+}
+
+func (s *MemoryBookStore) AddCopy(ctx context.Context, bookID int, req *models.CopyRequest, at, pickupBy time.Time) (*models.Copy, error) {
+	if err := checkLength(req.Barcode, 64); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.live(bookID) {
+		return nil, ErrNotFound
+	}
+	if _, ok := s.barcodes[req.Barcode]; ok {
+		return nil, ErrCopyExists
+	}
+	c := &models.Copy{ID: s.nextIDs.copy, BookID: bookID, Barcode: req.Barcode, CreatedAt: s.timestamp()}
+	s.nextIDs.copy++
+	s.copies[c.ID] = c
+	s.barcodes[c.Barcode] = c.ID
+	s.fillHolds(bookID, at, pickupBy)
+
+	result := *c
+	return &result, nil
+}
+
+func (s *MemoryBookStore) ListCopies(ctx context.Context, bookID int) ([]models.Copy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.live(bookID) {
+		return nil, ErrNotFound
+	}
+	copies := []models.Copy{}
+	for _, c := range s.copies {
+		if c.BookID == bookID {
+			result := *c
+			_, result.OnLoan = s.openLoans[c.ID]
+			copies = append(copies, result)
+		}
+	}
+	slices.SortFunc(copies, func(a, b models.Copy) int { return a.ID - b.ID })
+	return copies, nil
+}
+
+func (s *MemoryBookStore) Checkout(ctx context.Context, c models.Checkout) (*models.Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[c.MemberID]; !ok {
+		return nil, ErrMemberNotFound
+	}
+	if !s.live(c.BookID) {
+		return nil, ErrNotFound
+	}
+
+	copyID := c.CopyID
+	held := s.readyHold(c.BookID, c.MemberID)
+	switch {
+	case held != nil && (copyID == 0 || copyID == *held.CopyID):
+		copyID = *held.CopyID
+	case copyID != 0:
+		cp, ok := s.copies[copyID]
+		if !ok || cp.BookID != c.BookID {
+			return nil, ErrCopyNotFound
+		}
+		if _, onLoan := s.openLoans[copyID]; onLoan {
+			return nil, ErrCopyOnLoan
+		}
+		if _, onHold := s.setAside[copyID]; onHold {
+			return nil, ErrCopyOnHold
+		}
+	default:
+		// Like Postgres, lend the free copy with the lowest id.
+		free := s.freeCopies(c.BookID)
+		if len(free) == 0 {
+			return nil, ErrNoCopyAvailable
+		}
+		copyID = free[0]
+	}
+
+	loan := &models.Loan{
+		ID:           s.nextIDs.loan,
+		CopyID:       copyID,
+		BookID:       c.BookID,
+		MemberID:     c.MemberID,
+		CheckedOutAt: toTimestamp(c.At),
+		DueAt:        toTimestamp(c.DueAt),
+	}
+	s.nextIDs.loan++
+	s.loans[loan.ID] = loan
+	s.openLoans[copyID] = loan.ID
+	s.fulfilHolds(c.BookID, c.MemberID, copyID, c.At)
+
+	result := *loan
+	return &result, nil
+}
+
+func (s *MemoryBookStore) ReturnLoan(ctx context.Context, id int, at, pickupBy time.Time) (*models.Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loan, ok := s.loans[id]
+	if !ok {
+		return nil, ErrLoanNotFound
+	}
+	if loan.ReturnedAt != nil {
+		return nil, ErrLoanReturned
+	}
+
+	returned := *loan
+	returnedAt := toTimestamp(at)
+	returned.ReturnedAt = &returnedAt
+	s.loans[id] = &returned
+	delete(s.openLoans, loan.CopyID)
+	s.fillHolds(loan.BookID, at, pickupBy)
+
+	result := returned
+	return &result, nil
+}
+
+func (s *MemoryBookStore) GetLoan(ctx context.Context, id int) (*models.Loan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	loan, ok := s.loans[id]
+	if !ok {
+		return nil, ErrLoanNotFound
+	}
+	result := *loan
+	return &result, nil
+}
+
+func (s *MemoryBookStore) ListLoans(ctx context.Context, params models.ListLoansParams) (*models.Page[models.Loan], error) {
+	below, err := decodeLoanCursor(params.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	loans := []models.Loan{}
+	for _, loan := range s.loans {
+		if loan.MemberID != params.MemberID || loan.ID >= below {
+			continue
+		}
+		if params.Status != "" && loan.StatusAt(params.Now) != params.Status {
+			continue
+		}
+		loans = append(loans, *loan)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(loans, func(a, b models.Loan) int { return b.ID - a.ID })
+	return loanPage(loans, params.Limit), nil
+}
+
+// live reports whether a book exists and is not in the trash. Callers hold
+// s.mu.
+func (s *MemoryBookStore) live(id int) bool {
+	book, ok := s.books[id]
+	return ok && book.DeletedAt == nil
+}
+
+// purgeLending drops the copies of a purged book and the holds on it, as
+// the purge does in Postgres. The book has never been lent, so there are no
+// loans to keep. Callers hold s.mu.
+func (s *MemoryBookStore) purgeLending(bookID int) {
+	for id, c := range s.copies {
+		if c.BookID != bookID {
+			continue
+		}
+		delete(s.copies, id)
+		delete(s.barcodes, c.Barcode)
+	}
+	for id, hold := range s.holds {
+		if hold.BookID == bookID {
+			delete(s.holds, id)
+			if hold.Status == models.HoldReady {
+				delete(s.setAside, *hold.CopyID)
+			}
+		}
+	}
+}
+
+// bookOnLoan reports whether copies of a book are out. Callers hold s.mu.
+func (s *MemoryBookStore) bookOnLoan(bookID int) bool {
+	for copyID := range s.openLoans {
+		if s.copies[copyID].BookID == bookID {
+			return true
+		}
+	}
+	return false
+}
+
+// bookLent reports whether a copy of a book has ever been lent. Callers hold
+// s.mu.
+func (s *MemoryBookStore) bookLent(bookID int) bool {
+	for _, loan := range s.loans {
+		if loan.BookID == bookID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLength enforces a VARCHAR limit.
+func checkLength(value string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("%w: value too long for type character varying(%d)", ErrValidation, max)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultQueryBuckets cover single-row lookups in well under a millisecond up
+// to bulk upserts and exports that take seconds.
+var DefaultQueryBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// QueryMetrics records how long each repository operation spends in the
+// database. A nil *QueryMetrics records nothing.
+type QueryMetrics struct {
+	duration *prometheus.HistogramVec
+}
+
+// NewQueryMetrics registers book_db_query_duration_seconds, labeled by
+// operation, with reg. A nil reg means the global registry.
+func NewQueryMetrics(reg prometheus.Registerer) (*QueryMetrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m := &QueryMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "book_db_query_duration_seconds",
+			Help:    "Duration of database queries in seconds by repository operation",
+			Buckets: DefaultQueryBuckets,
+		}, []string{"operation"}),
+	}
+	if err := reg.Register(m.duration); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// observe starts timing operation; call the returned func when it finishes.
+func (m *QueryMetrics) observe(operation string) func() {
+	if m == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestQueryMetricsObserve(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewQueryMetrics(reg)
+	if err != nil {
+		t.Fatalf("NewQueryMetrics() error = %v", err)
+	}
+
+	m.observe("GetBookByID")()
+	m.observe("GetBookByID")()
+	m.observe("CreateBook")()
+
+	if got := testutil.CollectAndCount(m.duration); got != 2 {
+		t.Errorf("series = %d, want 2", got)
+	}
+
+	var nilMetrics *QueryMetrics
+	nilMetrics.observe("GetBookByID")()
+
+	if _, err := NewQueryMetrics(reg); err == nil {
+		t.Error("NewQueryMetrics() registered twice on one registry, want error")
+	}
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"book-service/internal/models"
+)
+
+const DefaultSort = "-created_at"
+
+type sortKind int
+
+const (
+	sortKindTime sortKind = iota
+	sortKindString
+	sortKindInt
+)
+
+type sortField struct {
+	column string
+	kind   sortKind
+	get    func(b *models.Book) interface{}
+}
+
+// sortFields whitelists the columns clients may order by. Every ordering is
+// suffixed with id so the keyset is unique even when values tie. Text columns
+// use the "C" collation so ordering is byte-wise and matches the in-memory
+// store.
+var sortFields = map[string]sortField{
+	"created_at": {column: "created_at", kind: sortKindTime, get: func(b *models.Book) interface{} { return b.CreatedAt }},
+	"updated_at": {column: "updated_at", kind: sortKindTime, get: func(b *models.Book) interface{} { return b.UpdatedAt }},
+	"published":  {column: "published", kind: sortKindTime, get: func(b *models.Book) interface{} { return b.Published }},
+	"title":      {column: `title COLLATE "C"`, kind: sortKindString, get: func(b *models.Book) interface{} { return b.Title }},
+	"author":     {column: `author COLLATE "C"`, kind: sortKindString, get: func(b *models.Book) interface{} { return b.Author }},
+	"pages":      {column: "pages", kind: sortKindInt, get: func(b *models.Book) interface{} { return b.Pages }},
+	"id":         {column: "id", kind: sortKindInt, get: func(b *models.Book) interface{} { return b.ID }},
+}
+
+func (f sortField) format(v interface{}) string {
+	switch f.kind {
+	case sortKindTime:
+		return formatTime(v.(time.Time))
+	case sortKindInt:
+		return strconv.Itoa(v.(int))
+	default:
+		return v.(string)
+	}
+}
+
+// compare orders two values of the field's kind like Postgres would.
+func (f sortField) compare(a, b interface{}) int {
+	switch f.kind {
+	case sortKindTime:
+		return a.(time.Time).Compare(b.(time.Time))
+	case sortKindInt:
+		return cmp.Compare(a.(int), b.(int))
+	default:
+		return strings.Compare(a.(string), b.(string))
+	}
+}
+
+type sortSpec struct {
+	key   string
+	field sortField
+	desc  bool
+}
+
+func parseSort(s string) (sortSpec, error) {
+	if s == "" {
+		s = DefaultSort
+	}
+
+	spec := sortSpec{key: s}
+	name := s
+	switch {
+	case strings.HasPrefix(s, "-"):
+		spec.desc = true
+		name = s[1:]
+	case strings.HasPrefix(s, "+"):
+		name = s[1:]
+	}
+
+	field, ok := sortFields[name]
+	if !ok {
+		return sortSpec{}, ErrInvalidSort
+	}
+	spec.field = field
+
+	return spec, nil
+}
+
+// cursor is the opaque position handed back to clients. It remembers the sort
+// it was issued for, so it cannot be replayed against a different ordering.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeCursor(spec sortSpec, book *models.Book) string {
+	data, _ := json.Marshal(cursor{
+		Sort:  spec.key,
+		Value: spec.field.format(spec.field.get(book)),
+		ID:    book.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, spec sortSpec) (value interface{}, id int, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	if c.Sort != spec.key {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	switch spec.field.kind {
+	case sortKindTime:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		value = t
+	case sortKindInt:
+		n, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		value = n
+	default:
+		value = c.Value
+	}
+
+	return value, c.ID, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"book-service/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	book := &models.Book{
+		ID:        42,
+		Title:     "The Go Programming Language",
+		Author:    "Alan Donovan",
+		Pages:     380,
+		Published: time.Date(2015, 10, 26, 0, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2026, 3, 2, 10, 0, 0, 123456789, time.UTC),
+		UpdatedAt: time.Date(2026, 3, 3, 9, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		sort string
+		want interface{}
+	}{
+		{sort: "", want: book.CreatedAt},
+		{sort: "+updated_at", want: book.UpdatedAt},
+		{sort: "-published", want: book.Published},
+		{sort: "title", want: book.Title},
+		{sort: "-author", want: book.Author},
+		{sort: "pages", want: book.Pages},
+		{sort: "-id", want: book.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			spec, err := parseSort(tt.sort)
+			if err != nil {
+				t.Fatalf("parseSort(%q) error = %v", tt.sort, err)
+			}
+			value, id, err := decodeCursor(encodeCursor(spec, book), spec)
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if spec.field.compare(value, tt.want) != 0 || id != book.ID {
+				t.Errorf("decodeCursor() = %v, %d, want %v, %d", value, id, tt.want, book.ID)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	byPages, _ := parseSort("pages")
+	byTitle, _ := parseSort("title")
+	enc := base64.RawURLEncoding
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "not JSON", cursor: enc.EncodeToString([]byte("pages=380"))},
+		{name: "issued for another sort", cursor: encodeCursor(byTitle, &models.Book{ID: 1, Title: "Dune"})},
+		{name: "value of the wrong kind", cursor: enc.EncodeToString([]byte(`{"s":"pages","v":"many","id":1}`))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor, byPages); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort    string
+		wantKey string
+		desc    bool
+		wantErr error
+	}{
+		{sort: "", wantKey: DefaultSort, desc: true},
+		{sort: "title", wantKey: "title"},
+		{sort: "+pages", wantKey: "+pages"},
+		{sort: "-published", wantKey: "-published", desc: true},
+		{sort: "isbn", wantErr: ErrInvalidSort},
+		{sort: "-", wantErr: ErrInvalidSort},
+		{sort: "title; DROP TABLE books", wantErr: ErrInvalidSort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			spec, err := parseSort(tt.sort)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseSort() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (spec.key != tt.wantKey || spec.desc != tt.desc) {
+				t.Errorf("parseSort() = %q desc %v, want %q desc %v", spec.key, spec.desc, tt.wantKey, tt.desc)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const DefaultSearchLang = "english"
+
+// searchConfigs whitelists the text search configurations a client may pick
+// with ?lang=. The stored search_vector column is built with
+// DefaultSearchLang; other configurations are computed per query and cannot
+// use the GIN index.
+var searchConfigs = map[string]string{
+	"english": "english",
+	"simple":  "simple",
+	"french":  "french",
+	"german":  "german",
+	"spanish": "spanish",
+	"italian": "italian",
+	"russian": "russian",
+}
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// searchTerms splits a free-text query into lower-cased words. Anything but
+// letters and digits is a separator, which keeps the terms safe to embed in
+// a tsquery.
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// prefixTSQuery builds a tsquery matching every term as a prefix.
+func prefixTSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func searchConfig(lang string) (string, error) {
+	if lang == "" {
+		lang = DefaultSearchLang
+	}
+	cfg, ok := searchConfigs[strings.ToLower(lang)]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported lang %q", ErrInvalidSearch, lang)
+	}
+	return cfg, nil
+}
+
+// searchCursor pages through ranked results by offset. Ranks are floats
+// recomputed on every query, so they make a poor keyset; the cursor is tied
+// to the query it was issued for instead.
+type searchCursor struct {
+	Query  string `json:"q"`
+	Lang   string `json:"l"`
+	Offset int    `json:"o"`
+}
+
+func encodeSearchCursor(query, lang string, offset int) string {
+	data, _ := json.Marshal(searchCursor{Query: query, Lang: lang, Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(s, query, lang string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var c searchCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Query != query || c.Lang != lang || c.Offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return c.Offset, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"book-service/internal/models"
+)
+
+// BookStore is the storage contract BookService depends on. BookRepository
+// implements it on Postgres and MemoryBookStore in process; both must pass
+// the conformance suite in store_test.go.
+//
+// Every write bumps a book's Version. UpdateBook and DeleteBook take the
+// versions the caller expects (from If-Match); an empty list means the write
+// is unconditional, otherwise ErrPreconditionFailed is returned on mismatch.
+//
+// DeleteBook moves a book to the trash. Trashed books are invisible to every
+// read except ListBooks with Filter.IncludeDeleted, and free their ISBN for a
+// new book.
+//
+// Every write also records a revision in the book's history, atomically with
+// the write. Its number is the book's new Version.
+//
+// A book's Author byline and AuthorIDs are kept in step: writes that set only
+// the IDs derive the byline from the authors' names, and writes that set only
+// the byline credit the authors it names, creating missing ones. Unknown IDs
+// fail with ErrValidation.
+type BookStore interface {
+	AuthorStore
+
+	CreateBook(ctx context.Context, book *models.CreateBookRequest) (*models.Book, error)
+	GetBookByID(ctx context.Context, id int) (*models.Book, error)
+	ListBooks(ctx context.Context, params models.ListBooksParams) (*models.Page[models.Book], error)
+	// SearchBooks ranks books whose title or author match every word of the
+	// query, each treated as a prefix.
+	SearchBooks(ctx context.Context, params models.SearchParams) (*models.Page[models.SearchResult], error)
+	UpdateBook(ctx context.Context, id int, book *models.UpdateBookRequest, ifMatch []int) (*models.Book, error)
+	DeleteBook(ctx context.Context, id int, ifMatch []int) error
+	// RestoreBook takes a book out of the trash, failing with ErrConflict
+	// if a live book has its ISBN.
+	RestoreBook(ctx context.Context, id int) (*models.Book, error)
+	// PurgeDeletedBooks permanently removes books trashed before cutoff.
+	// Their history is kept.
+	PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error)
+
+	// BookHistory lists a book's revisions, newest first. It fails with
+	// ErrNotFound for a book that never existed.
+	BookHistory(ctx context.Context, id int, params models.HistoryParams) (*models.Page[models.BookRevision], error)
+	// RevertBook sets a live book's title, author, ISBN, pages and
+	// published date back to what they were at revision, recording the
+	// revert as a new revision. ifMatch works as for UpdateBook.
+	RevertBook(ctx context.Context, id, revision int, ifMatch []int) (*models.Book, error)
+
+	// UpsertBooks inserts books or updates the existing book with the same
+	// ISBN, returning one result per input in order. ISBNs must be unique
+	// within a batch. With dryRun nothing is persisted.
+	UpsertBooks(ctx context.Context, books []models.CreateBookRequest, dryRun bool) ([]models.UpsertResult, error)
+	// ExportBooks calls fn for every book in id order without loading the
+	// whole catalog at once. Iteration stops at the first error fn returns.
+	ExportBooks(ctx context.Context, fn func(book *models.Book) error) error
+
+	// LastModified reports when the catalog last changed, or the zero time
+	// if it is empty. Lists and searches use it as their Last-Modified.
+	LastModified(ctx context.Context) (time.Time, error)
+}
+
+// AuthorStore keeps the authors books are credited to. Names may repeat,
+// and a byline name shared by more than one author is rejected with
+// ErrAuthorAmbiguous.
+// Renaming an author leaves the bylines of their books as they are, and an
+// author still credited on a book, even a trashed one, cannot be deleted.
+type AuthorStore interface {
+	CreateAuthor(ctx context.Context, req *models.AuthorRequest) (*models.Author, error)
+	GetAuthor(ctx context.Context, id int) (*models.Author, error)
+	// ListAuthors pages through authors in id order.
+	ListAuthors(ctx context.Context, params models.ListAuthorsParams) (*models.Page[models.Author], error)
+	UpdateAuthor(ctx context.Context, id int, req *models.AuthorRequest) (*models.Author, error)
+	DeleteAuthor(ctx context.Context, id int) error
+}
+
+var (
+	_ BookStore = (*BookRepository)(nil)
+	_ BookStore = (*MemoryBookStore)(nil)
+	_ BookStore = (*CachedBookStore)(nil)
+)
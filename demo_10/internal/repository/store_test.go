@@ -0,0 +1,752 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"book-service/internal/models"
+	"book-service/pkg/database"
+	"book-service/pkg/logging"
+)
+
+// runBookStoreConformance is the contract every BookStore implementation must
+// satisfy. newStore must return an empty store.
+func runBookStoreConformance(t *testing.T, newStore func(t *testing.T) BookStore) {
+	published := time.Date(2015, 10, 26, 0, 0, 0, 0, time.UTC)
+	newBook := func(i int) *models.CreateBookRequest {
+		return &models.CreateBookRequest{
+			Title:     fmt.Sprintf("Book %02d", i),
+			Author:    fmt.Sprintf("Author %d", i%3),
+			ISBN:      fmt.Sprintf("97800000000%02d", i),
+			Pages:     100 + i,
+			Published: published.AddDate(i, 0, 0),
+		}
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		store := newStore(t)
+
+		created, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if created.ID == 0 || created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+			t.Errorf("CreateBook() = %+v, want id and matching timestamps", created)
+		}
+
+		got, err := store.GetBookByID(t.Context(), created.ID)
+		if err != nil {
+			t.Fatalf("GetBookByID() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, created) {
+			t.Errorf("GetBookByID() = %+v, want %+v", got, created)
+		}
+		if !got.Published.Equal(published.AddDate(1, 0, 0)) {
+			t.Errorf("Published = %v, want %v", got.Published, published.AddDate(1, 0, 0))
+		}
+	})
+
+	t.Run("duplicate isbn conflicts", func(t *testing.T) {
+		store := newStore(t)
+
+		first, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		second, err := store.CreateBook(t.Context(), newBook(2))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+
+		if _, err := store.CreateBook(t.Context(), newBook(1)); !errors.Is(err, ErrConflict) {
+			t.Errorf("CreateBook() duplicate error = %v, want ErrConflict", err)
+		}
+		if _, err := store.UpdateBook(t.Context(), second.ID, &models.UpdateBookRequest{ISBN: &first.ISBN}, nil); !errors.Is(err, ErrConflict) {
+			t.Errorf("UpdateBook() duplicate error = %v, want ErrConflict", err)
+		}
+	})
+
+	t.Run("column limits", func(t *testing.T) {
+		store := newStore(t)
+
+		req := newBook(1)
+		req.ISBN = "123456789012345678901"
+		if _, err := store.CreateBook(t.Context(), req); !errors.Is(err, ErrValidation) {
+			t.Errorf("CreateBook() long isbn error = %v, want ErrValidation", err)
+		}
+	})
+
+	t.Run("missing book", func(t *testing.T) {
+		store := newStore(t)
+
+		if _, err := store.GetBookByID(t.Context(), 42); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetBookByID() error = %v, want ErrNotFound", err)
+		}
+		title := "x"
+		if _, err := store.UpdateBook(t.Context(), 42, &models.UpdateBookRequest{Title: &title}, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateBook() error = %v, want ErrNotFound", err)
+		}
+		if err := store.DeleteBook(t.Context(), 42, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteBook() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("update applies only present fields", func(t *testing.T) {
+		store := newStore(t)
+
+		created, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		pages := 999
+		updated, err := store.UpdateBook(t.Context(), created.ID, &models.UpdateBookRequest{Pages: &pages}, nil)
+		if err != nil {
+			t.Fatalf("UpdateBook() error = %v", err)
+		}
+
+		want := *created
+		want.Pages = pages
+		want.UpdatedAt = updated.UpdatedAt
+		want.Version = created.Version + 1
+		if !reflect.DeepEqual(*updated, want) {
+			t.Errorf("UpdateBook() = %+v, want %+v", updated, want)
+		}
+		if updated.UpdatedAt.Before(created.UpdatedAt) {
+			t.Errorf("UpdatedAt went backwards: %v < %v", updated.UpdatedAt, created.UpdatedAt)
+		}
+	})
+
+	t.Run("versions and preconditions", func(t *testing.T) {
+		store := newStore(t)
+
+		created, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if created.Version != 1 {
+			t.Errorf("Version = %d, want 1", created.Version)
+		}
+
+		title := "Renamed"
+		updated, err := store.UpdateBook(t.Context(), created.ID, &models.UpdateBookRequest{Title: &title}, []int{created.Version})
+		if err != nil {
+			t.Fatalf("UpdateBook() with current version error = %v", err)
+		}
+		if updated.Version != 2 {
+			t.Errorf("Version after update = %d, want 2", updated.Version)
+		}
+
+		stale := "Stale"
+		if _, err := store.UpdateBook(t.Context(), created.ID, &models.UpdateBookRequest{Title: &stale}, []int{created.Version}); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("UpdateBook() with stale version error = %v, want ErrPreconditionFailed", err)
+		}
+		if err := store.DeleteBook(t.Context(), created.ID, []int{created.Version}); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("DeleteBook() with stale version error = %v, want ErrPreconditionFailed", err)
+		}
+
+		got, err := store.GetBookByID(t.Context(), created.ID)
+		if err != nil {
+			t.Fatalf("GetBookByID() error = %v", err)
+		}
+		if got.Title != title || got.Version != 2 {
+			t.Errorf("GetBookByID() = %+v, want title %q at version 2", got, title)
+		}
+		if err := store.DeleteBook(t.Context(), created.ID, []int{1, 2}); err != nil {
+			t.Errorf("DeleteBook() with matching version error = %v", err)
+		}
+	})
+
+	t.Run("concurrent updates serialize", func(t *testing.T) {
+		store := newStore(t)
+
+		created, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+
+		const writers = 8
+		errs := make(chan error, writers)
+		for i := 0; i < writers; i++ {
+			go func(i int) {
+				pages := 200 + i
+				_, err := store.UpdateBook(t.Context(), created.ID, &models.UpdateBookRequest{Pages: &pages}, []int{created.Version})
+				errs <- err
+			}(i)
+		}
+
+		succeeded := 0
+		for i := 0; i < writers; i++ {
+			err := <-errs
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, ErrPreconditionFailed):
+				t.Errorf("UpdateBook() error = %v, want nil or ErrPreconditionFailed", err)
+			}
+		}
+		if succeeded != 1 {
+			t.Errorf("%d conditional updates succeeded, want exactly 1", succeeded)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store := newStore(t)
+
+		created, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if err := store.DeleteBook(t.Context(), created.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() error = %v", err)
+		}
+		if _, err := store.GetBookByID(t.Context(), created.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetBookByID() after delete error = %v, want ErrNotFound", err)
+		}
+		if _, err := store.CreateBook(t.Context(), newBook(1)); err != nil {
+			t.Errorf("CreateBook() reusing deleted isbn error = %v", err)
+		}
+	})
+
+	t.Run("list pagination and ordering", func(t *testing.T) {
+		store := newStore(t)
+
+		for i := 1; i <= 7; i++ {
+			if _, err := store.CreateBook(t.Context(), newBook(i)); err != nil {
+				t.Fatalf("CreateBook() error = %v", err)
+			}
+		}
+
+		tests := []struct {
+			sort   string
+			filter models.BookFilter
+			want   []string
+		}{
+			{sort: "", want: []string{"Book 07", "Book 06", "Book 05", "Book 04", "Book 03", "Book 02", "Book 01"}},
+			{sort: "title", want: []string{"Book 01", "Book 02", "Book 03", "Book 04", "Book 05", "Book 06", "Book 07"}},
+			{sort: "-pages", want: []string{"Book 07", "Book 06", "Book 05", "Book 04", "Book 03", "Book 02", "Book 01"}},
+			{sort: "author", want: []string{"Book 03", "Book 06", "Book 01", "Book 04", "Book 07", "Book 02", "Book 05"}},
+			{sort: "published", filter: models.BookFilter{Author: "author 1"}, want: []string{"Book 01", "Book 04", "Book 07"}},
+			{sort: "id", filter: models.BookFilter{ISBNPrefix: "978000000000"}, want: []string{"Book 01", "Book 02", "Book 03", "Book 04", "Book 05", "Book 06", "Book 07"}},
+			{sort: "id", filter: models.BookFilter{MinPages: intPtr(103), MaxPages: intPtr(105)}, want: []string{"Book 03", "Book 04", "Book 05"}},
+			{
+				sort: "-published",
+				filter: models.BookFilter{
+					PublishedFrom: timePtr(published.AddDate(2, 0, 0)),
+					PublishedTo:   timePtr(published.AddDate(3, 0, 0)),
+				},
+				want: []string{"Book 03", "Book 02"},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run("sort="+tt.sort, func(t *testing.T) {
+				var got []string
+				params := models.ListBooksParams{Sort: tt.sort, Filter: tt.filter, Limit: 2}
+				for {
+					page, err := store.ListBooks(t.Context(), params)
+					if err != nil {
+						t.Fatalf("ListBooks() error = %v", err)
+					}
+					for _, b := range page.Data {
+						got = append(got, b.Title)
+					}
+					if page.HasMore != (page.NextCursor != "") {
+						t.Fatalf("HasMore = %v but NextCursor = %q", page.HasMore, page.NextCursor)
+					}
+					if !page.HasMore {
+						break
+					}
+					params.Cursor = page.NextCursor
+				}
+				if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+					t.Errorf("ListBooks() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("upsert and export", func(t *testing.T) {
+		store := newStore(t)
+
+		existing, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if _, err := store.CreateBook(t.Context(), newBook(2)); err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+
+		changed := *newBook(1)
+		changed.Title = "Book 01, second edition"
+		batch := []models.CreateBookRequest{changed, *newBook(2), *newBook(3)}
+
+		dry, err := store.UpsertBooks(t.Context(), batch, true)
+		if err != nil {
+			t.Fatalf("UpsertBooks() dry run error = %v", err)
+		}
+		want := []models.UpsertOutcome{models.UpsertUpdated, models.UpsertUnchanged, models.UpsertCreated}
+		for i, res := range dry {
+			if res.ISBN != batch[i].ISBN || res.Outcome != want[i] {
+				t.Errorf("dry run result %d = %+v, want %s for %s", i, res, want[i], batch[i].ISBN)
+			}
+		}
+		if got, _ := store.GetBookByID(t.Context(), existing.ID); got.Title != existing.Title {
+			t.Errorf("dry run changed title to %q", got.Title)
+		}
+
+		results, err := store.UpsertBooks(t.Context(), batch, false)
+		if err != nil {
+			t.Fatalf("UpsertBooks() error = %v", err)
+		}
+		for i, res := range results {
+			if res.Outcome != want[i] {
+				t.Errorf("result %d = %+v, want %s", i, res, want[i])
+			}
+		}
+		if res := results[0]; res.ID != existing.ID || res.Version != existing.Version+1 {
+			t.Errorf("updated result = %+v, want id %d version %d", res, existing.ID, existing.Version+1)
+		}
+		if res := results[1]; res.ID != 0 || res.Version != 0 {
+			t.Errorf("unchanged result = %+v, want no id or version", res)
+		}
+
+		var exported []models.Book
+		err = store.ExportBooks(t.Context(), func(b *models.Book) error {
+			exported = append(exported, *b)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ExportBooks() error = %v", err)
+		}
+		if len(exported) != 3 {
+			t.Fatalf("ExportBooks() returned %d books, want 3", len(exported))
+		}
+		for i := 1; i < len(exported); i++ {
+			if exported[i-1].ID >= exported[i].ID {
+				t.Errorf("ExportBooks() not in id order: %d before %d", exported[i-1].ID, exported[i].ID)
+			}
+		}
+		if exported[0].Title != changed.Title || exported[0].Version != 2 {
+			t.Errorf("upserted book = %+v, want new title at version 2", exported[0])
+		}
+		if exported[1].Version != 1 {
+			t.Errorf("unchanged book version = %d, want 1", exported[1].Version)
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		store := newStore(t)
+
+		for i, b := range []struct{ title, author string }{
+			{"The Go Programming Language", "Alan Donovan"},
+			{"Concurrency in Go", "Katherine Cox-Buday"},
+			{"Learning Python", "Mark Lutz"},
+			{"Gopher Tales", "Go Team"},
+		} {
+			req := newBook(i + 1)
+			req.Title, req.Author = b.title, b.author
+			if _, err := store.CreateBook(t.Context(), req); err != nil {
+				t.Fatalf("CreateBook() error = %v", err)
+			}
+		}
+
+		page, err := store.SearchBooks(t.Context(), models.SearchParams{Query: "gopher", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchBooks() error = %v", err)
+		}
+		if len(page.Data) != 1 || page.Data[0].Title != "Gopher Tales" {
+			t.Fatalf("SearchBooks(gopher) = %+v, want Gopher Tales", page.Data)
+		}
+		if page.Data[0].Highlight.Title != "<mark>Gopher</mark> Tales" {
+			t.Errorf("Highlight.Title = %q", page.Data[0].Highlight.Title)
+		}
+
+		// Prefix matching, every word must match.
+		page, err = store.SearchBooks(t.Context(), models.SearchParams{Query: "go prog", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchBooks() error = %v", err)
+		}
+		if len(page.Data) != 1 || page.Data[0].Title != "The Go Programming Language" {
+			t.Errorf("SearchBooks(go prog) = %+v, want The Go Programming Language", page.Data)
+		}
+
+		var titles []string
+		params := models.SearchParams{Query: "Go", Limit: 2}
+		for {
+			page, err := store.SearchBooks(t.Context(), params)
+			if err != nil {
+				t.Fatalf("SearchBooks() error = %v", err)
+			}
+			for _, res := range page.Data {
+				titles = append(titles, res.Title)
+			}
+			if !page.HasMore {
+				break
+			}
+			params.Cursor = page.NextCursor
+		}
+		if len(titles) != 3 {
+			t.Errorf("SearchBooks(Go) across pages = %v, want 3 results", titles)
+		}
+
+		if _, err := store.SearchBooks(t.Context(), models.SearchParams{Query: "go", Lang: "klingon", Limit: 10}); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("SearchBooks() unknown lang error = %v, want ErrInvalidSearch", err)
+		}
+		if _, err := store.SearchBooks(t.Context(), models.SearchParams{Query: "!!", Limit: 10}); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("SearchBooks() empty query error = %v, want ErrInvalidSearch", err)
+		}
+	})
+
+	t.Run("trash, restore and purge", func(t *testing.T) {
+		store := newStore(t)
+
+		trashed, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if err := store.DeleteBook(t.Context(), trashed.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() error = %v", err)
+		}
+
+		if _, err := store.GetBookByID(t.Context(), trashed.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetBookByID() trashed error = %v, want ErrNotFound", err)
+		}
+		title := "Book 01, revised"
+		if _, err := store.UpdateBook(t.Context(), trashed.ID, &models.UpdateBookRequest{Title: &title}, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateBook() trashed error = %v, want ErrNotFound", err)
+		}
+		if err := store.DeleteBook(t.Context(), trashed.ID, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteBook() trashed error = %v, want ErrNotFound", err)
+		}
+		if page, err := store.ListBooks(t.Context(), models.ListBooksParams{Limit: 10}); err != nil || len(page.Data) != 0 {
+			t.Errorf("ListBooks() = %+v, %v, want no live books", page, err)
+		}
+		page, err := store.ListBooks(t.Context(), models.ListBooksParams{Filter: models.BookFilter{IncludeDeleted: true}, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListBooks() include deleted error = %v", err)
+		}
+		if len(page.Data) != 1 || page.Data[0].DeletedAt == nil || page.Data[0].Version != 2 {
+			t.Fatalf("ListBooks() include deleted = %+v, want the trashed book at version 2", page.Data)
+		}
+
+		// The ISBN is free for a new book, which then blocks the restore.
+		replacement, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() with trashed ISBN error = %v", err)
+		}
+		if _, err := store.RestoreBook(t.Context(), trashed.ID); !errors.Is(err, ErrConflict) {
+			t.Errorf("RestoreBook() taken ISBN error = %v, want ErrConflict", err)
+		}
+		if err := store.DeleteBook(t.Context(), replacement.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() error = %v", err)
+		}
+
+		restored, err := store.RestoreBook(t.Context(), trashed.ID)
+		if err != nil {
+			t.Fatalf("RestoreBook() error = %v", err)
+		}
+		if restored.DeletedAt != nil || restored.Version != 3 {
+			t.Errorf("RestoreBook() = %+v, want live book at version 3", restored)
+		}
+		if got, err := store.GetBookByID(t.Context(), trashed.ID); err != nil || got.Version != 3 {
+			t.Errorf("GetBookByID() restored = %+v, %v, want version 3", got, err)
+		}
+		if _, err := store.RestoreBook(t.Context(), trashed.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("RestoreBook() live book error = %v, want ErrNotFound", err)
+		}
+
+		if n, err := store.PurgeDeletedBooks(t.Context(), time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Errorf("PurgeDeletedBooks() recent cutoff = %d, %v, want 0", n, err)
+		}
+		if n, err := store.PurgeDeletedBooks(t.Context(), time.Now().Add(time.Hour)); err != nil || n != 1 {
+			t.Errorf("PurgeDeletedBooks() = %d, %v, want 1", n, err)
+		}
+		page, err = store.ListBooks(t.Context(), models.ListBooksParams{Filter: models.BookFilter{IncludeDeleted: true}, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListBooks() include deleted error = %v", err)
+		}
+		if len(page.Data) != 1 || page.Data[0].ID != trashed.ID {
+			t.Errorf("ListBooks() after purge = %+v, want only the restored book", page.Data)
+		}
+	})
+
+	t.Run("history and revert", func(t *testing.T) {
+		store := newStore(t)
+		ctx := logging.WithActor(logging.WithRequestID(t.Context(), "req-1"), "sub:alice")
+
+		created, err := store.CreateBook(ctx, newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		title := "Book 01, revised"
+		if _, err := store.UpdateBook(ctx, created.ID, &models.UpdateBookRequest{Title: &title}, nil); err != nil {
+			t.Fatalf("UpdateBook() error = %v", err)
+		}
+		isbn := "9780000000099"
+		if _, err := store.UpdateBook(ctx, created.ID, &models.UpdateBookRequest{ISBN: &isbn}, nil); err != nil {
+			t.Fatalf("UpdateBook() error = %v", err)
+		}
+		if err := store.DeleteBook(ctx, created.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() error = %v", err)
+		}
+		if _, err := store.RestoreBook(ctx, created.ID); err != nil {
+			t.Fatalf("RestoreBook() error = %v", err)
+		}
+
+		var revisions []models.BookRevision
+		cursor := ""
+		for {
+			page, err := store.BookHistory(t.Context(), created.ID, models.HistoryParams{Cursor: cursor, Limit: 2})
+			if err != nil {
+				t.Fatalf("BookHistory() error = %v", err)
+			}
+			revisions = append(revisions, page.Data...)
+			if !page.HasMore {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		wantActions := []models.BookAction{models.BookRestored, models.BookDeleted, models.BookUpdated, models.BookUpdated, models.BookCreated}
+		if len(revisions) != len(wantActions) {
+			t.Fatalf("BookHistory() returned %d revisions, want %d", len(revisions), len(wantActions))
+		}
+		for i, rev := range revisions {
+			if rev.Revision != len(wantActions)-i || rev.Action != wantActions[i] {
+				t.Errorf("revision %d = %d %s, want %d %s", i, rev.Revision, rev.Action, len(wantActions)-i, wantActions[i])
+			}
+			if rev.Actor != "sub:alice" || rev.RequestID != "req-1" {
+				t.Errorf("revision %d actor, request id = %q, %q, want sub:alice, req-1", rev.Revision, rev.Actor, rev.RequestID)
+			}
+		}
+
+		isbnChange := revisions[2]
+		if len(isbnChange.Changes) != 1 || string(isbnChange.Changes["isbn"].Before) != `"`+created.ISBN+`"` || string(isbnChange.Changes["isbn"].After) != `"`+isbn+`"` {
+			t.Errorf("revision 3 changes = %+v, want only isbn %s -> %s", isbnChange.Changes, created.ISBN, isbn)
+		}
+		if first := revisions[4]; string(first.Changes["title"].Before) != "null" || len(first.Changes) != 6 {
+			t.Errorf("revision 1 changes = %+v, want every field set from null", first.Changes)
+		}
+		if _, ok := revisions[1].Changes["deleted_at"]; !ok {
+			t.Errorf("revision 4 changes = %+v, want deleted_at", revisions[1].Changes)
+		}
+
+		if _, err := store.RevertBook(ctx, created.ID, 1, []int{1}); !errors.Is(err, ErrPreconditionFailed) {
+			t.Errorf("RevertBook() stale If-Match error = %v, want ErrPreconditionFailed", err)
+		}
+		if _, err := store.RevertBook(ctx, created.ID, 99, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("RevertBook() unknown revision error = %v, want ErrNotFound", err)
+		}
+		reverted, err := store.RevertBook(ctx, created.ID, 1, []int{5})
+		if err != nil {
+			t.Fatalf("RevertBook() error = %v", err)
+		}
+		if reverted.Title != created.Title || reverted.ISBN != created.ISBN || reverted.Version != 6 {
+			t.Errorf("RevertBook() = %+v, want revision 1's title and ISBN at version 6", reverted)
+		}
+		page, err := store.BookHistory(t.Context(), created.ID, models.HistoryParams{Limit: 1})
+		if err != nil || len(page.Data) != 1 || page.Data[0].Action != models.BookReverted || len(page.Data[0].Changes) != 2 {
+			t.Errorf("BookHistory() after revert = %+v, %v, want a revert of title and isbn", page, err)
+		}
+
+		if _, err := store.BookHistory(t.Context(), created.ID+100, models.HistoryParams{Limit: 10}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("BookHistory() unknown book error = %v, want ErrNotFound", err)
+		}
+		if _, err := store.BookHistory(t.Context(), created.ID, models.HistoryParams{Cursor: "not-a-cursor", Limit: 10}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("BookHistory() bad cursor error = %v, want ErrInvalidCursor", err)
+		}
+	})
+
+	t.Run("authors and credits", func(t *testing.T) {
+		store := newStore(t)
+
+		req := newBook(1)
+		req.Author = "Brian Kernighan & Dennis Ritchie"
+		created, err := store.CreateBook(t.Context(), req)
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if len(created.AuthorIDs) != 2 {
+			t.Fatalf("CreateBook() AuthorIDs = %v, want the two authors of the byline", created.AuthorIDs)
+		}
+		kernighan, err := store.GetAuthor(t.Context(), created.AuthorIDs[0])
+		if err != nil || kernighan.Name != "Brian Kernighan" {
+			t.Fatalf("GetAuthor() = %+v, %v, want Brian Kernighan", kernighan, err)
+		}
+
+		pike, err := store.CreateAuthor(t.Context(), &models.AuthorRequest{Name: "Rob Pike"})
+		if err != nil {
+			t.Fatalf("CreateAuthor() error = %v", err)
+		}
+
+		// A namesake is a different author, and a byline naming them both
+		// cannot say which it means.
+		namesake, err := store.CreateAuthor(t.Context(), &models.AuthorRequest{Name: "Brian Kernighan"})
+		if err != nil || namesake.ID == kernighan.ID {
+			t.Fatalf("CreateAuthor() namesake = %+v, %v, want a second Brian Kernighan", namesake, err)
+		}
+		req = newBook(4)
+		req.Author = "Brian Kernighan & Rob Pike"
+		if _, err := store.CreateBook(t.Context(), req); !errors.Is(err, ErrAuthorAmbiguous) || !errors.Is(err, ErrConflict) {
+			t.Errorf("CreateBook() ambiguous byline error = %v, want ErrAuthorAmbiguous", err)
+		}
+		req.AuthorIDs = []int{namesake.ID}
+		if book, err := store.CreateBook(t.Context(), req); err != nil || !reflect.DeepEqual(book.AuthorIDs, []int{namesake.ID}) {
+			t.Errorf("CreateBook() by the namesake's ID = %+v, %v, want credited to author %d", book, err, namesake.ID)
+		} else if _, err := store.UpdateBook(t.Context(), book.ID, &models.UpdateBookRequest{AuthorIDs: &[]int{pike.ID}}, nil); err != nil {
+			t.Fatalf("UpdateBook() error = %v", err)
+		}
+		if err := store.DeleteAuthor(t.Context(), namesake.ID); err != nil {
+			t.Errorf("DeleteAuthor() namesake error = %v", err)
+		}
+
+		// Credited by IDs alone, a book's byline is made from the names.
+		req = newBook(2)
+		req.Author, req.AuthorIDs = "", []int{kernighan.ID, pike.ID, kernighan.ID}
+		second, err := store.CreateBook(t.Context(), req)
+		if err != nil {
+			t.Fatalf("CreateBook() by author IDs error = %v", err)
+		}
+		if second.Author != "Brian Kernighan, Rob Pike" || !reflect.DeepEqual(second.AuthorIDs, []int{kernighan.ID, pike.ID}) {
+			t.Errorf("CreateBook() by author IDs = %q %v, want derived byline and deduplicated IDs", second.Author, second.AuthorIDs)
+		}
+		req = newBook(3)
+		req.AuthorIDs = []int{pike.ID + 100}
+		if _, err := store.CreateBook(t.Context(), req); !errors.Is(err, ErrValidation) {
+			t.Errorf("CreateBook() unknown author error = %v, want ErrValidation", err)
+		}
+
+		byline := "Rob Pike"
+		updated, err := store.UpdateBook(t.Context(), created.ID, &models.UpdateBookRequest{Author: &byline}, nil)
+		if err != nil || !reflect.DeepEqual(updated.AuthorIDs, []int{pike.ID}) {
+			t.Errorf("UpdateBook() byline = %v, %v, want credited to Rob Pike", updated, err)
+		}
+
+		page, err := store.ListBooks(t.Context(), models.ListBooksParams{Filter: models.BookFilter{AuthorID: kernighan.ID}, Limit: 10})
+		if err != nil || len(page.Data) != 1 || page.Data[0].ID != second.ID {
+			t.Errorf("ListBooks() by author = %+v, %v, want only book %d", page, err, second.ID)
+		}
+
+		renamed, err := store.UpdateAuthor(t.Context(), pike.ID, &models.AuthorRequest{Name: "Robert Pike"})
+		if err != nil || renamed.Name != "Robert Pike" {
+			t.Errorf("UpdateAuthor() = %+v, %v, want renamed", renamed, err)
+		}
+		if got, err := store.GetBookByID(t.Context(), second.ID); err != nil || got.Author != second.Author {
+			t.Errorf("GetBookByID() after rename = %+v, %v, want byline unchanged", got, err)
+		}
+
+		if err := store.DeleteBook(t.Context(), second.ID, nil); err != nil {
+			t.Fatalf("DeleteBook() error = %v", err)
+		}
+		if err := store.DeleteAuthor(t.Context(), kernighan.ID); !errors.Is(err, ErrAuthorHasBooks) {
+			t.Errorf("DeleteAuthor() credited on a trashed book error = %v, want ErrAuthorHasBooks", err)
+		}
+		if err := store.DeleteAuthor(t.Context(), pike.ID+100); !errors.Is(err, ErrAuthorNotFound) || !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeleteAuthor() unknown error = %v, want ErrAuthorNotFound", err)
+		}
+
+		authors, err := store.ListAuthors(t.Context(), models.ListAuthorsParams{Limit: 2})
+		if err != nil || len(authors.Data) != 2 || !authors.HasMore {
+			t.Fatalf("ListAuthors() = %+v, %v, want a full first page", authors, err)
+		}
+		rest, err := store.ListAuthors(t.Context(), models.ListAuthorsParams{Cursor: authors.NextCursor, Limit: 2})
+		if err != nil || len(rest.Data) != 1 || rest.HasMore || rest.Data[0].ID != pike.ID {
+			t.Errorf("ListAuthors() second page = %+v, %v, want only Robert Pike", rest, err)
+		}
+		named, err := store.ListAuthors(t.Context(), models.ListAuthorsParams{Name: "RITCHIE", Limit: 10})
+		if err != nil || len(named.Data) != 1 || named.Data[0].Name != "Dennis Ritchie" {
+			t.Errorf("ListAuthors() by name = %+v, %v, want Dennis Ritchie", named, err)
+		}
+	})
+
+	t.Run("last modified", func(t *testing.T) {
+		store := newStore(t)
+
+		if got, err := store.LastModified(t.Context()); err != nil || !got.IsZero() {
+			t.Fatalf("LastModified() on empty store = %v, %v, want zero time", got, err)
+		}
+
+		created, err := store.CreateBook(t.Context(), newBook(1))
+		if err != nil {
+			t.Fatalf("CreateBook() error = %v", err)
+		}
+		if got, err := store.LastModified(t.Context()); err != nil || !got.Equal(created.UpdatedAt) {
+			t.Errorf("LastModified() after create = %v, %v, want %v", got, err, created.UpdatedAt)
+		}
+
+		title := "Book 01, revised"
+		updated, err := store.UpdateBook(t.Context(), created.ID, &models.UpdateBookRequest{Title: &title}, nil)
+		if err != nil {
+			t.Fatalf("UpdateBook() error = %v", err)
+		}
+		if got, err := store.LastModified(t.Context()); err != nil || !got.Equal(updated.UpdatedAt) {
+			t.Errorf("LastModified() after update = %v, %v, want %v", got, err, updated.UpdatedAt)
+		}
+	})
+
+	t.Run("list rejects bad input", func(t *testing.T) {
+		store := newStore(t)
+
+		if _, err := store.ListBooks(t.Context(), models.ListBooksParams{Sort: "isbn", Limit: 10}); !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("ListBooks() unknown sort error = %v, want ErrInvalidSort", err)
+		}
+		if _, err := store.ListBooks(t.Context(), models.ListBooksParams{Cursor: "not-a-cursor", Limit: 10}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ListBooks() bad cursor error = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+func TestMemoryBookStore(t *testing.T) {
+	runBookStoreConformance(t, func(t *testing.T) BookStore {
+		return NewMemoryBookStore()
+	})
+}
+
+// TestBookRepository runs the conformance suite against Postgres. It needs
+// TEST_DATABASE_URL pointing at a disposable database.
+func TestBookRepository(t *testing.T) {
+	db := openTestDatabase(t)
+	runBookStoreConformance(t, func(t *testing.T) BookStore {
+		return newTestRepository(t, db)
+	})
+}
+
+// openTestDatabase connects to TEST_DATABASE_URL and migrates it, skipping
+// the test when it is not set.
+func openTestDatabase(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if err := migrator.Up(t.Context()); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return db
+}
+
+// newTestRepository empties every table and returns a repository on db.
+func newTestRepository(t *testing.T, db *sql.DB) *BookRepository {
+	if _, err := db.Exec(`TRUNCATE books, book_history, book_authors, authors, members, copies, loans, holds, fines, closures RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatalf("truncate tables: %v", err)
+	}
+	return NewBookRepository(db, BookRepositoryConfig{Timeouts: DefaultQueryTimeouts})
+}
+
+func intPtr(v int) *int { return &v }
+
+func timePtr(v time.Time) *time.Time { return &v }
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"book-service/pkg/logging"
+)
+
+const tracerName = "book-service/internal/repository"
+
+// startOp opens the span for one store operation, starts timing it and
+// bounds it by the operation's timeout. The returned func ends all three.
+func (r *BookRepository) startOp(ctx context.Context, op string) (context.Context, func()) {
+	cancel := context.CancelFunc(func() {})
+	if timeout := r.timeouts.For(op); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "BookRepository."+op)
+	done := r.metrics.observe(op)
+	return ctx, func() {
+		done()
+		span.End()
+		cancel()
+	}
+}
+
+// startQuery opens a client span for one SQL statement. name is a stable,
+// low-cardinality label such as "books.select_by_id", led by the table the
+// statement is about; the statement text is recorded with its placeholders,
+// never with argument values. The returned context also carries a logger
+// tagged with the statement name.
+func startQuery(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	query = strings.TrimSpace(query)
+	operation, _, _ := strings.Cut(query, " ")
+	table, _, _ := strings.Cut(name, ".")
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("statement", name))
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBCollectionName(table),
+			semconv.DBOperationName(strings.ToUpper(operation)),
+			semconv.DBQuerySummary(name),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// recordError logs err, marks span as failed and returns it, reported as
+// the context's error when ctx has ended. Outcomes the caller expects, such
+// as a missing row, a version mismatch or a duplicate ISBN, are not failures
+// of the query and are left alone, and a client going away is only logged.
+func recordError(ctx context.Context, span trace.Span, err error) error {
+	err = contextError(ctx, err)
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrPreconditionFailed) ||
+		errors.Is(err, ErrConflict) || errors.Is(err, ErrValidation) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		logging.FromContext(ctx).InfoContext(ctx, "query canceled", "error", err)
+		return err
+	}
+	logging.FromContext(ctx).ErrorContext(ctx, "query failed", "error", err)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
+// txError translates and records a failure to begin or commit a transaction
+// on the operation's span.
+func txError(ctx context.Context, err error) error {
+	return recordError(ctx, trace.SpanFromContext(ctx), translateError(err))
+}
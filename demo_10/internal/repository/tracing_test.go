@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+func TestStartQueryAttributes(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	tests := []struct {
+		name       string
+		query      string
+		collection string
+		operation  string
+	}{
+		{name: "books.select_by_id", query: `SELECT id FROM books WHERE id = $1`, collection: "books", operation: "SELECT"},
+		{name: "authors.delete", query: `DELETE FROM authors WHERE id = $1`, collection: "authors", operation: "DELETE"},
+		{name: "book_authors.insert", query: `INSERT INTO book_authors (book_id, author_id) VALUES ($1, $2)`, collection: "book_authors", operation: "INSERT"},
+		{name: "loans.return", query: `
+			UPDATE loans SET returned_at = $2 WHERE id = $1`, collection: "loans", operation: "UPDATE"},
+		{name: "holds.fill", query: `WITH free AS (SELECT 1) UPDATE holds SET status = 'ready'`, collection: "holds", operation: "WITH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			_, span := startQuery(t.Context(), tt.name, tt.query)
+			span.End()
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			attrs := make(map[string]string)
+			for _, kv := range spans[0].Attributes {
+				attrs[string(kv.Key)] = kv.Value.Emit()
+			}
+			if got := attrs[string(semconv.DBCollectionNameKey)]; got != tt.collection {
+				t.Errorf("%s = %q, want %q", semconv.DBCollectionNameKey, got, tt.collection)
+			}
+			if got := attrs[string(semconv.DBOperationNameKey)]; got != tt.operation {
+				t.Errorf("%s = %q, want %q", semconv.DBOperationNameKey, got, tt.operation)
+			}
+			if got := attrs[string(semconv.DBQuerySummaryKey)]; got != tt.name {
+				t.Errorf("%s = %q, want %q", semconv.DBQuerySummaryKey, got, tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+)
+
+// AuthorService manages the authors books are credited to.
+type AuthorService struct {
+	repo repository.BookStore
+}
+
+func NewAuthorService(repo repository.BookStore) *AuthorService {
+	return &AuthorService{repo: repo}
+}
+
+func (s *AuthorService) CreateAuthor(ctx context.Context, author *models.AuthorRequest) (*models.Author, error) {
+	ctx, span := startAuthorSpan(ctx, "CreateAuthor")
+	defer span.End()
+
+	req := *author
+	if err := validateAuthor(&req); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateAuthor(ctx, &req)
+}
+
+func (s *AuthorService) GetAuthor(ctx context.Context, id int) (*models.Author, error) {
+	ctx, span := startAuthorSpan(ctx, "GetAuthor")
+	defer span.End()
+
+	return s.repo.GetAuthor(ctx, id)
+}
+
+func (s *AuthorService) ListAuthors(ctx context.Context, params models.ListAuthorsParams) (*models.Page[models.Author], error) {
+	ctx, span := startAuthorSpan(ctx, "ListAuthors")
+	defer span.End()
+
+	params.Limit = pageSize(params.Limit)
+	return s.repo.ListAuthors(ctx, params)
+}
+
+// UpdateAuthor renames an author. Books keep their bylines.
+func (s *AuthorService) UpdateAuthor(ctx context.Context, id int, author *models.AuthorRequest) (*models.Author, error) {
+	ctx, span := startAuthorSpan(ctx, "UpdateAuthor")
+	defer span.End()
+
+	req := *author
+	if err := validateAuthor(&req); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateAuthor(ctx, id, &req)
+}
+
+// DeleteAuthor fails with ErrAuthorHasBooks while any book, trashed ones
+// included, is credited to the author.
+func (s *AuthorService) DeleteAuthor(ctx context.Context, id int) error {
+	ctx, span := startAuthorSpan(ctx, "DeleteAuthor")
+	defer span.End()
+
+	return s.repo.DeleteAuthor(ctx, id)
+}
+
+// ListAuthorBooks lists the books credited to an author, failing with
+// ErrAuthorNotFound rather than returning an empty page for an unknown one.
+func (s *AuthorService) ListAuthorBooks(ctx context.Context, id int, params models.ListBooksParams) (*models.Page[models.Book], error) {
+	ctx, span := startAuthorSpan(ctx, "ListAuthorBooks")
+	defer span.End()
+
+	if _, err := s.repo.GetAuthor(ctx, id); err != nil {
+		return nil, err
+	}
+	params.Filter.AuthorID = id
+	params.Limit = pageSize(params.Limit)
+	return s.repo.ListBooks(ctx, params)
+}
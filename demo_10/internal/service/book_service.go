@@ -1,34 +1,124 @@
 package service
 
 import (
+	"context"
+	"time"
+
 	"book-service/internal/models"
 	"book-service/internal/repository"
 )
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
 type BookService struct {
-	repo *repository.BookRepository
+	repo repository.BookStore
+	now  func() time.Time
 }
 
-func NewBookService(repo *repository.BookRepository) *BookService {
-	return &BookService{repo: repo}
+func NewBookService(repo repository.BookStore) *BookService {
+	return &BookService{repo: repo, now: time.Now}
 }
 
-func (s *BookService) CreateBook(book *models.CreateBookRequest) (*models.Book, error) {
-	return s.repo.CreateBook(book)
+func (s *BookService) CreateBook(ctx context.Context, book *models.CreateBookRequest) (*models.Book, error) {
+	ctx, span := startSpan(ctx, "CreateBook")
+	defer span.End()
+
+	req := *book
+	if err := validateCreate(&req, s.now()); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateBook(ctx, &req)
 }
 
-func (s *BookService) GetBookByID(id int) (*models.Book, error) {
-	return s.repo.GetBookByID(id)
+func (s *BookService) GetBookByID(ctx context.Context, id int) (*models.Book, error) {
+	ctx, span := startSpan(ctx, "GetBookByID")
+	defer span.End()
+
+	return s.repo.GetBookByID(ctx, id)
 }
 
-func (s *BookService) GetAllBooks() ([]models.Book, error) {
-	return s.repo.GetAllBooks()
+func (s *BookService) ListBooks(ctx context.Context, params models.ListBooksParams) (*models.Page[models.Book], error) {
+	ctx, span := startSpan(ctx, "ListBooks")
+	defer span.End()
+
+	params.Limit = pageSize(params.Limit)
+	return s.repo.ListBooks(ctx, params)
 }
 
-func (s *BookService) UpdateBook(id int, book *models.UpdateBookRequest) (*models.Book, error) {
-	return s.repo.UpdateBook(id, book)
+func (s *BookService) SearchBooks(ctx context.Context, params models.SearchParams) (*models.Page[models.SearchResult], error) {
+	ctx, span := startSpan(ctx, "SearchBooks")
+	defer span.End()
+
+	params.Limit = pageSize(params.Limit)
+	return s.repo.SearchBooks(ctx, params)
 }
 
-func (s *BookService) DeleteBook(id int) error {
-	return s.repo.DeleteBook(id)
+// LastModified is when the catalog last changed, the validator for lists
+// and searches.
+func (s *BookService) LastModified(ctx context.Context) (time.Time, error) {
+	ctx, span := startSpan(ctx, "LastModified")
+	defer span.End()
+
+	return s.repo.LastModified(ctx)
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+// UpdateBook applies a partial update. ifMatch lists the versions the caller
+// last saw; when it is not empty and the book has moved on, ErrPreconditionFailed
+// is returned and nothing is written.
+func (s *BookService) UpdateBook(ctx context.Context, id int, book *models.UpdateBookRequest, ifMatch []int) (*models.Book, error) {
+	ctx, span := startSpan(ctx, "UpdateBook")
+	defer span.End()
+
+	req := *book
+	if err := validateUpdate(&req, s.now()); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateBook(ctx, id, &req, ifMatch)
+}
+
+// DeleteBook moves a book to the trash; RestoreBook brings it back until the
+// purge job removes it for good.
+func (s *BookService) DeleteBook(ctx context.Context, id int, ifMatch []int) error {
+	ctx, span := startSpan(ctx, "DeleteBook")
+	defer span.End()
+
+	return s.repo.DeleteBook(ctx, id, ifMatch)
+}
+
+func (s *BookService) RestoreBook(ctx context.Context, id int) (*models.Book, error) {
+	ctx, span := startSpan(ctx, "RestoreBook")
+	defer span.End()
+
+	return s.repo.RestoreBook(ctx, id)
+}
+
+// BookHistory lists a book's revisions, newest first.
+func (s *BookService) BookHistory(ctx context.Context, id int, params models.HistoryParams) (*models.Page[models.BookRevision], error) {
+	ctx, span := startSpan(ctx, "BookHistory")
+	defer span.End()
+
+	params.Limit = pageSize(params.Limit)
+	return s.repo.BookHistory(ctx, id, params)
+}
+
+// RevertBook rolls a book's fields back to an earlier revision. ifMatch
+// works as for UpdateBook.
+func (s *BookService) RevertBook(ctx context.Context, id, revision int, ifMatch []int) (*models.Book, error) {
+	ctx, span := startSpan(ctx, "RevertBook")
+	defer span.End()
+
+	return s.repo.RevertBook(ctx, id, revision, ifMatch)
 }
@@ -0,0 +1,21 @@
+package service
+
+import "testing"
+
+func TestPageSize(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 0, want: DefaultPageSize},
+		{limit: -3, want: DefaultPageSize},
+		{limit: 1, want: 1},
+		{limit: MaxPageSize, want: MaxPageSize},
+		{limit: MaxPageSize + 1, want: MaxPageSize},
+	}
+	for _, tt := range tests {
+		if got := pageSize(tt.limit); got != tt.want {
+			t.Errorf("pageSize(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
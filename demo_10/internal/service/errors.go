@@ -0,0 +1,41 @@
+package service
+
+import "book-service/internal/repository"
+
+// Errors returned by BookService. Storage errors are re-exported so callers
+// above the service never need to import the repository package.
+var (
+	ErrNotFound    = repository.ErrNotFound
+	ErrConflict    = repository.ErrConflict
+	ErrValidation  = repository.ErrValidation
+	ErrUnavailable = repository.ErrUnavailable
+
+	ErrPreconditionFailed = repository.ErrPreconditionFailed
+
+	ErrInvalidCursor = repository.ErrInvalidCursor
+	ErrInvalidSort   = repository.ErrInvalidSort
+	ErrInvalidSearch = repository.ErrInvalidSearch
+
+	ErrAuthorNotFound  = repository.ErrAuthorNotFound
+	ErrAuthorAmbiguous = repository.ErrAuthorAmbiguous
+	ErrAuthorHasBooks  = repository.ErrAuthorHasBooks
+
+	ErrBookOnLoan = repository.ErrBookOnLoan
+
+	ErrMemberNotFound  = repository.ErrMemberNotFound
+	ErrMemberExists    = repository.ErrMemberExists
+	ErrCopyNotFound    = repository.ErrCopyNotFound
+	ErrCopyExists      = repository.ErrCopyExists
+	ErrCopyOnLoan      = repository.ErrCopyOnLoan
+	ErrCopyOnHold      = repository.ErrCopyOnHold
+	ErrNoCopyAvailable = repository.ErrNoCopyAvailable
+	ErrLoanNotFound    = repository.ErrLoanNotFound
+	ErrLoanReturned    = repository.ErrLoanReturned
+	ErrHoldNotFound    = repository.ErrHoldNotFound
+	ErrHoldExists      = repository.ErrHoldExists
+	ErrHoldClosed      = repository.ErrHoldClosed
+	ErrFineNotFound    = repository.ErrFineNotFound
+	ErrFinePaid        = repository.ErrFinePaid
+	ErrClosureNotFound = repository.ErrClosureNotFound
+	ErrClosureExists   = repository.ErrClosureExists
+)
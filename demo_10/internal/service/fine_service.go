@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+	"book-service/pkg/logging"
+)
+
+// FineService charges members for overdue loans under a FinePolicy. Fines
+// accrue on open loans each time AccrueFines runs and are settled when the
+// loan is returned; the closure calendar it keeps decides which days count.
+type FineService struct {
+	repo   repository.FineStore
+	policy FinePolicy
+	now    func() time.Time
+}
+
+func NewFineService(repo repository.FineStore, policy FinePolicy) *FineService {
+	return &FineService{repo: repo, policy: policy, now: time.Now}
+}
+
+// AssessLoan assesses the fine on a returned loan for the last time. A loan
+// returned on time has no fine, and AssessLoan returns nil for it.
+func (s *FineService) AssessLoan(ctx context.Context, loan *models.Loan) (*models.Fine, error) {
+	ctx, span := startFineSpan(ctx, "AssessLoan")
+	defer span.End()
+
+	if loan.ReturnedAt == nil || !loan.ReturnedAt.After(loan.DueAt) {
+		return nil, nil
+	}
+	member, err := s.repo.GetMember(ctx, loan.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	fines, err := s.assess(ctx, []models.OverdueLoan{{Loan: *loan, MemberType: member.Type}}, s.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return fines[0], nil
+}
+
+// AccrueFines brings the fines on every overdue loan up to date, and
+// settles those on loans returned late whose final assessment is missing.
+// It returns how many fines it assessed.
+func (s *FineService) AccrueFines(ctx context.Context) (int, error) {
+	ctx, span := startFineSpan(ctx, "AccrueFines")
+	defer span.End()
+
+	now := s.now().UTC()
+	loans, err := s.repo.OverdueLoans(ctx, now)
+	if err != nil || len(loans) == 0 {
+		return 0, err
+	}
+	fines, err := s.assess(ctx, loans, now)
+	return len(fines), err
+}
+
+// assess works out and records the fines on loans at now, looking up the
+// closures they span in one go.
+func (s *FineService) assess(ctx context.Context, loans []models.OverdueLoan, now time.Time) ([]*models.Fine, error) {
+	from, to := s.policy.Span(&loans[0], now)
+	for i := range loans[1:] {
+		f, t := s.policy.Span(&loans[i+1], now)
+		from, to = min(from, f), max(to, t)
+	}
+	closures, err := s.repo.ListClosures(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	closed := make(map[string]bool, len(closures))
+	for _, c := range closures {
+		closed[c.Day] = true
+	}
+
+	fines := make([]*models.Fine, 0, len(loans))
+	for i := range loans {
+		loan := &loans[i]
+		days, amount := s.policy.Assess(loan, now, closed)
+		fine, err := s.repo.AssessFine(ctx, models.Assessment{
+			LoanID:   loan.ID,
+			MemberID: loan.MemberID,
+			Days:     days,
+			Amount:   amount,
+			Final:    loan.ReturnedAt != nil,
+			At:       now,
+		})
+		if err != nil {
+			return fines, err
+		}
+		fines = append(fines, fine)
+	}
+	return fines, nil
+}
+
+// RunAccrual calls AccrueFines every interval until ctx is done. Replicas
+// may run it concurrently; a fine never goes back to an older assessment.
+func (s *FineService) RunAccrual(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger := logging.FromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.AccrueFines(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Error("accruing fines failed", "error", err)
+		case n > 0:
+			logger.Info("accrued fines", "count", n)
+		}
+	}
+}
+
+// ListMemberFines lists a member's fines, newest first, with what they owe
+// in total.
+func (s *FineService) ListMemberFines(ctx context.Context, memberID int) (*models.MemberFines, error) {
+	ctx, span := startFineSpan(ctx, "ListMemberFines")
+	defer span.End()
+
+	if _, err := s.repo.GetMember(ctx, memberID); err != nil {
+		return nil, err
+	}
+	fines, err := s.repo.ListFines(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.MemberFines{Data: fines}
+	for i := range fines {
+		result.Balance += fines[i].Balance()
+	}
+	return result, nil
+}
+
+func (s *FineService) GetFine(ctx context.Context, id int) (*models.Fine, error) {
+	ctx, span := startFineSpan(ctx, "GetFine")
+	defer span.End()
+
+	return s.repo.GetFine(ctx, id)
+}
+
+// PayFine settles a fine's balance. A fine on an open loan can be paid too;
+// it goes on accruing until the loan is returned.
+func (s *FineService) PayFine(ctx context.Context, id int) (*models.Fine, error) {
+	ctx, span := startFineSpan(ctx, "PayFine")
+	defer span.End()
+
+	return s.repo.PayFine(ctx, id, s.now().UTC())
+}
+
+func (s *FineService) AddClosure(ctx context.Context, closure *models.Closure) (*models.Closure, error) {
+	ctx, span := startFineSpan(ctx, "AddClosure")
+	defer span.End()
+
+	c := *closure
+	if err := validateClosure(&c); err != nil {
+		return nil, err
+	}
+	return s.repo.AddClosure(ctx, &c)
+}
+
+// ListClosures lists the closure days from from to to, both included. They
+// default to today and a year from it.
+func (s *FineService) ListClosures(ctx context.Context, from, to string) ([]models.Closure, error) {
+	ctx, span := startFineSpan(ctx, "ListClosures")
+	defer span.End()
+
+	today := s.policy.day(s.now())
+	if from == "" {
+		from = today.Format(time.DateOnly)
+	}
+	if to == "" {
+		to = today.AddDate(1, 0, 0).Format(time.DateOnly)
+	}
+	v := &validator{}
+	v.day("from", &from)
+	v.day("to", &to)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return s.repo.ListClosures(ctx, from, to)
+}
+
+func (s *FineService) DeleteClosure(ctx context.Context, day string) error {
+	ctx, span := startFineSpan(ctx, "DeleteClosure")
+	defer span.End()
+
+	v := &validator{}
+	v.day("day", &day)
+	if err := v.err(); err != nil {
+		return err
+	}
+	return s.repo.DeleteClosure(ctx, day)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"time"
+
+	"book-service/internal/models"
+)
+
+// FinePolicy prices overdue loans, in cents. It is plain arithmetic on the
+// times it is given, so the same loan, calendar and clock always cost the
+// same.
+type FinePolicy struct {
+	// DailyRate is charged for each overdue day the library is open, once
+	// the first GraceDays of them have passed.
+	DailyRate int
+	GraceDays int
+	// Caps limits the fine on a single loan by member type. Types without
+	// a cap are not capped.
+	Caps map[models.MemberType]int
+	// Location is the library's time zone, in which days are counted.
+	// Nil means UTC.
+	Location *time.Location
+}
+
+// Assess works out the fine on a loan at now, or when it was returned.
+// Every calendar day after the due date up to that day counts as overdue
+// unless the library was closed; closed holds those days as YYYY-MM-DD.
+func (p FinePolicy) Assess(loan *models.OverdueLoan, now time.Time, closed map[string]bool) (days, amount int) {
+	end := now
+	if loan.ReturnedAt != nil {
+		end = *loan.ReturnedAt
+	}
+	if !end.After(loan.DueAt) {
+		return 0, 0
+	}
+
+	overdue := 0
+	last := p.day(end)
+	for d := p.day(loan.DueAt).AddDate(0, 0, 1); !d.After(last); d = d.AddDate(0, 0, 1) {
+		if !closed[d.Format(time.DateOnly)] {
+			overdue++
+		}
+	}
+
+	days = max(overdue-p.GraceDays, 0)
+	amount = days * p.DailyRate
+	if limit, ok := p.Caps[loan.MemberType]; ok {
+		amount = min(amount, limit)
+	}
+	return days, amount
+}
+
+// Span returns the first and last day, as YYYY-MM-DD, on which a loan can
+// be overdue at now, for looking up closures.
+func (p FinePolicy) Span(loan *models.OverdueLoan, now time.Time) (from, to string) {
+	end := now
+	if loan.ReturnedAt != nil {
+		end = *loan.ReturnedAt
+	}
+	return p.day(loan.DueAt).AddDate(0, 0, 1).Format(time.DateOnly), p.day(end).Format(time.DateOnly)
+}
+
+// day is the calendar day of t in the library's time zone, as midnight UTC
+// so that stepping through days is not thrown by daylight saving.
+func (p FinePolicy) day(t time.Time) time.Time {
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
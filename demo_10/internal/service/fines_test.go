@@ -0,0 +1,178 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+)
+
+func TestFinePolicy(t *testing.T) {
+	due := time.Date(2025, 12, 22, 18, 0, 0, 0, time.UTC) // a Monday
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	policy := FinePolicy{
+		DailyRate: 25,
+		GraceDays: 1,
+		Caps:      map[models.MemberType]int{models.MemberStudent: 100},
+	}
+	returned := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name       string
+		policy     FinePolicy
+		loan       models.OverdueLoan
+		now        time.Time
+		closed     map[string]bool
+		wantDays   int
+		wantAmount int
+	}{
+		{
+			name: "on time",
+			loan: models.OverdueLoan{Loan: models.Loan{DueAt: due}},
+			now:  due,
+		},
+		{
+			name: "later on the due date",
+			loan: models.OverdueLoan{Loan: models.Loan{DueAt: due}},
+			now:  due.Add(5 * time.Hour),
+		},
+		{
+			name: "within the grace period",
+			loan: models.OverdueLoan{Loan: models.Loan{DueAt: due}},
+			now:  due.AddDate(0, 0, 1),
+		},
+		{
+			name:       "past the grace period",
+			loan:       models.OverdueLoan{Loan: models.Loan{DueAt: due}},
+			now:        due.AddDate(0, 0, 5),
+			wantDays:   4,
+			wantAmount: 100,
+		},
+		{
+			name:       "closures do not count",
+			loan:       models.OverdueLoan{Loan: models.Loan{DueAt: due}},
+			now:        due.AddDate(0, 0, 5),
+			closed:     map[string]bool{"2025-12-25": true, "2025-12-26": true, "2025-12-22": true},
+			wantDays:   2,
+			wantAmount: 50,
+		},
+		{
+			name:       "capped by member type",
+			loan:       models.OverdueLoan{Loan: models.Loan{DueAt: due}, MemberType: models.MemberStudent},
+			now:        due.AddDate(0, 0, 30),
+			wantDays:   29,
+			wantAmount: 100,
+		},
+		{
+			name:       "uncapped member type",
+			loan:       models.OverdueLoan{Loan: models.Loan{DueAt: due}, MemberType: models.MemberSenior},
+			now:        due.AddDate(0, 0, 30),
+			wantDays:   29,
+			wantAmount: 725,
+		},
+		{
+			name:       "returned loans stop at the return",
+			loan:       models.OverdueLoan{Loan: models.Loan{DueAt: due, ReturnedAt: returned(due.AddDate(0, 0, 3))}},
+			now:        due.AddDate(0, 0, 30),
+			wantDays:   2,
+			wantAmount: 50,
+		},
+		{
+			// 18:00 UTC on the 22nd is 13:00 in New York, and 03:00 UTC on
+			// the 24th is still the 23rd there.
+			name:       "days are counted in the library's time zone",
+			policy:     FinePolicy{DailyRate: 25, Location: newYork},
+			loan:       models.OverdueLoan{Loan: models.Loan{DueAt: due}},
+			now:        time.Date(2025, 12, 24, 3, 0, 0, 0, time.UTC),
+			wantDays:   1,
+			wantAmount: 25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy
+			if tt.policy.DailyRate != 0 {
+				p = tt.policy
+			}
+			days, amount := p.Assess(&tt.loan, tt.now, tt.closed)
+			if days != tt.wantDays || amount != tt.wantAmount {
+				t.Errorf("Assess() = %d days, %d, want %d days, %d", days, amount, tt.wantDays, tt.wantAmount)
+			}
+		})
+	}
+}
+
+func TestFineAccrual(t *testing.T) {
+	const period = 14 * 24 * time.Hour
+	checkedOut := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	due := checkedOut.Add(period)
+
+	store := repository.NewMemoryBookStore()
+	fines := NewFineService(store, FinePolicy{DailyRate: 25, GraceDays: 1})
+	lending := NewLendingService(store, LendingConfig{LoanPeriod: period, Fines: fines})
+	clock := func(now time.Time) {
+		fines.now = func() time.Time { return now }
+		lending.now = fines.now
+	}
+	clock(checkedOut)
+
+	book := createDune(t, store)
+	member, err := lending.CreateMember(t.Context(), &models.MemberRequest{Name: "Paul Atreides", Email: "paul@arrakis.example"})
+	if err != nil {
+		t.Fatalf("CreateMember() error = %v", err)
+	}
+	if _, err := lending.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: "DUNE-1"}); err != nil {
+		t.Fatalf("AddCopy() error = %v", err)
+	}
+	loan, err := lending.Checkout(t.Context(), book.ID, &models.CheckoutRequest{MemberID: member.ID})
+	if err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	if _, err := fines.AddClosure(t.Context(), &models.Closure{Day: due.AddDate(0, 0, 2).Format(time.DateOnly), Reason: "Stocktake"}); err != nil {
+		t.Fatalf("AddClosure() error = %v", err)
+	}
+
+	balance := func(t *testing.T, want int) {
+		t.Helper()
+		got, err := fines.ListMemberFines(t.Context(), member.ID)
+		if err != nil {
+			t.Fatalf("ListMemberFines() error = %v", err)
+		}
+		if got.Balance != want {
+			t.Errorf("ListMemberFines() balance = %d, want %d", got.Balance, want)
+		}
+	}
+
+	clock(due.AddDate(0, 0, 4))
+	if n, err := fines.AccrueFines(t.Context()); err != nil || n != 1 {
+		t.Fatalf("AccrueFines() = %d, %v, want 1", n, err)
+	}
+	// Four days overdue, one of them closed and one of grace.
+	balance(t, 50)
+
+	clock(due.AddDate(0, 0, 6))
+	if _, err := lending.ReturnLoan(t.Context(), loan.ID); err != nil {
+		t.Fatalf("ReturnLoan() error = %v", err)
+	}
+	balance(t, 100)
+
+	clock(due.AddDate(0, 0, 10))
+	if n, err := fines.AccrueFines(t.Context()); err != nil || n != 0 {
+		t.Errorf("AccrueFines() after the return = %d, %v, want nothing left to assess", n, err)
+	}
+	balance(t, 100)
+
+	list, err := fines.ListMemberFines(t.Context(), member.ID)
+	if err != nil {
+		t.Fatalf("ListMemberFines() error = %v", err)
+	}
+	paid, err := fines.PayFine(t.Context(), list.Data[0].ID)
+	if err != nil || !paid.Final {
+		t.Fatalf("PayFine() = %+v, %v, want the final fine paid", paid, err)
+	}
+	balance(t, 0)
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"book-service/internal/bulk"
+	"book-service/internal/models"
+)
+
+const (
+	importBatchSize    = 500
+	maxImportRowErrors = 1000
+)
+
+type ImportOptions struct {
+	DryRun bool
+}
+
+type ImportRowError struct {
+	Line    int          `json:"line"`
+	ISBN    string       `json:"isbn,omitempty"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun          bool             `json:"dry_run"`
+	Total           int              `json:"total"`
+	Created         int              `json:"created"`
+	Updated         int              `json:"updated"`
+	Unchanged       int              `json:"unchanged"`
+	Failed          int              `json:"failed"`
+	Errors          []ImportRowError `json:"errors,omitempty"`
+	ErrorsTruncated bool             `json:"errors_truncated,omitempty"`
+}
+
+func (r *ImportReport) addError(e ImportRowError) {
+	r.Failed++
+	if len(r.Errors) >= maxImportRowErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, e)
+}
+
+// count adds n books with outcome o to the report.
+func (r *ImportReport) count(o models.UpsertOutcome, n int) {
+	switch o {
+	case models.UpsertCreated:
+		r.Created += n
+	case models.UpsertUpdated:
+		r.Updated += n
+	default:
+		r.Unchanged += n
+	}
+}
+
+// mergeOutcomes is what two writes of one ISBN, prev and then next, did to
+// it together.
+func mergeOutcomes(prev, next models.UpsertOutcome) models.UpsertOutcome {
+	switch {
+	case prev == models.UpsertCreated:
+		return models.UpsertCreated
+	case prev == models.UpsertUpdated || next == models.UpsertUpdated:
+		return models.UpsertUpdated
+	default:
+		return models.UpsertUnchanged
+	}
+}
+
+// ImportBooks validates every record from src and upserts the valid ones by
+// ISBN in batches. Invalid rows are reported and skipped, and rows repeating
+// an ISBN are folded into one book, the later row winning, so that a dry run
+// reports what the import would. Batches are committed as they fill up, so
+// when an error is returned the report still describes the rows that were
+// written before it.
+func (s *BookService) ImportBooks(ctx context.Context, src bulk.Reader, opts ImportOptions) (*ImportReport, error) {
+	ctx, span := startSpan(ctx, "ImportBooks")
+	defer span.End()
+
+	report := &ImportReport{DryRun: opts.DryRun}
+	batch := make([]models.CreateBookRequest, 0, importBatchSize)
+	// inBatch maps the ISBNs in batch to their rows.
+	inBatch := make(map[string]int, importBatchSize)
+	// outcomes records what earlier batches did to each ISBN. A dry run
+	// writes nothing for a later batch to see, so a repeat in a later batch
+	// is merged with it here rather than left to the store, the same way in
+	// either mode.
+	outcomes := make(map[string]models.UpsertOutcome)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		results, err := s.repo.UpsertBooks(ctx, batch, opts.DryRun)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			outcome := res.Outcome
+			if prev, ok := outcomes[res.ISBN]; ok {
+				report.count(prev, -1)
+				outcome = mergeOutcomes(prev, outcome)
+			}
+			report.count(outcome, 1)
+			outcomes[res.ISBN] = outcome
+		}
+		batch = batch[:0]
+		clear(inBatch)
+		return nil
+	}
+
+	now := s.now()
+	for {
+		rec, err := src.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Total++
+
+		if rec.Err != nil {
+			report.addError(ImportRowError{Line: rec.Line, ISBN: rec.Book.ISBN, Message: rec.Err.Error()})
+			continue
+		}
+
+		book := rec.Book
+		if err := validateCreate(&book, now); err != nil {
+			var verr *ValidationError
+			errors.As(err, &verr)
+			report.addError(ImportRowError{Line: rec.Line, ISBN: rec.Book.ISBN, Message: "validation failed", Fields: verr.Errors})
+			continue
+		}
+
+		if i, ok := inBatch[book.ISBN]; ok {
+			batch[i] = book
+			continue
+		}
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+		inBatch[book.ISBN] = len(batch)
+		batch = append(batch, book)
+	}
+
+	return report, flush()
+}
+
+// ExportBooks streams the catalog in id order to fn.
+func (s *BookService) ExportBooks(ctx context.Context, fn func(book *models.Book) error) error {
+	ctx, span := startSpan(ctx, "ExportBooks")
+	defer span.End()
+
+	return s.repo.ExportBooks(ctx, fn)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"book-service/internal/bulk"
+	"book-service/internal/models"
+	"book-service/internal/repository"
+)
+
+func TestImportBooks(t *testing.T) {
+	const input = `title,author,isbn,pages,published
+The Go Programming Language,Alan Donovan,978-0-13-419044-0,380,2015-10-26
+Missing Pages,Someone,9780306406157,,2001-01-01
+Bad Checksum,Someone,9780306406158,100,2001-01-01
+Not A Number,Someone,9780306406157,many,2001-01-01
+"Go, Revised",Alan Donovan,0134190440,400,2016-01-01
+`
+
+	tests := []struct {
+		name      string
+		dryRun    bool
+		wantBooks int
+	}{
+		{name: "dry run writes nothing", dryRun: true, wantBooks: 0},
+		{name: "import", wantBooks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := repository.NewMemoryBookStore()
+			svc := NewBookService(store)
+			svc.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+			src, err := bulk.NewCSVReader(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("NewCSVReader() error = %v", err)
+			}
+			report, err := svc.ImportBooks(t.Context(), src, ImportOptions{DryRun: tt.dryRun})
+			if err != nil {
+				t.Fatalf("ImportBooks() error = %v", err)
+			}
+
+			// The repeated ISBN is folded into one book.
+			if report.Total != 5 || report.Failed != 3 || report.Created != 1 || report.Updated != 0 || report.Unchanged != 0 {
+				t.Errorf("ImportBooks() report = %+v, want 1 created and 3 failed", report)
+			}
+			wantLines := []int{3, 4, 5}
+			for i, rowErr := range report.Errors {
+				if rowErr.Line != wantLines[i] {
+					t.Errorf("error %d on line %d, want line %d", i, rowErr.Line, wantLines[i])
+				}
+			}
+
+			page, err := store.ListBooks(t.Context(), models.ListBooksParams{Limit: 10})
+			if err != nil {
+				t.Fatalf("ListBooks() error = %v", err)
+			}
+			if len(page.Data) != tt.wantBooks {
+				t.Fatalf("store holds %d books, want %d", len(page.Data), tt.wantBooks)
+			}
+			if tt.wantBooks > 0 && page.Data[0].Title != "Go, Revised" {
+				t.Errorf("stored title = %q, want the later row to win", page.Data[0].Title)
+			}
+		})
+	}
+}
+
+// TestImportDryRunMatchesImport checks that a dry run predicts the report of
+// the import, with ISBNs repeated within and across batches.
+func TestImportDryRunMatchesImport(t *testing.T) {
+	isbn := func(i int) string {
+		body := fmt.Sprintf("978%09d", i)
+		return body + string(isbn13CheckDigit(body))
+	}
+	var input strings.Builder
+	input.WriteString("title,author,isbn,pages,published\n")
+	row := func(title string, i int) {
+		fmt.Fprintf(&input, "%q,Someone,%s,100,2001-01-01\n", title, isbn(i))
+	}
+	row("Existing", 0)
+	row("Existing, Unchanged", 1)
+	row("Existing, Renamed", 2)
+	for i := range importBatchSize {
+		row("New", 100+i)
+	}
+	row("Existing, Renamed Again", 0)
+	row("Existing, Unchanged", 1)
+	row("New, Renamed", 100)
+	row("New, Renamed Twice", 100)
+
+	reports := make(map[bool]*ImportReport)
+	for _, dryRun := range []bool{true, false} {
+		store := repository.NewMemoryBookStore()
+		for i, title := range []string{"Existing", "Existing, Unchanged", "Existing"} {
+			book := models.CreateBookRequest{Title: title, Author: "Someone", ISBN: isbn(i), Pages: 100, Published: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)}
+			if _, err := store.CreateBook(t.Context(), &book); err != nil {
+				t.Fatalf("CreateBook() error = %v", err)
+			}
+		}
+		svc := NewBookService(store)
+		svc.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+		src, err := bulk.NewCSVReader(strings.NewReader(input.String()))
+		if err != nil {
+			t.Fatalf("NewCSVReader() error = %v", err)
+		}
+		report, err := svc.ImportBooks(t.Context(), src, ImportOptions{DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("ImportBooks(dry run %t) error = %v", dryRun, err)
+		}
+		report.DryRun = false
+		reports[dryRun] = report
+	}
+
+	want := ImportReport{Total: importBatchSize + 7, Created: importBatchSize, Updated: 2, Unchanged: 1}
+	for dryRun, report := range reports {
+		if !reflect.DeepEqual(*report, want) {
+			t.Errorf("ImportBooks(dry run %t) = %+v, want %+v", dryRun, *report, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	errISBNLength   = errors.New("must have 10 or 13 digits")
+	errISBNChars    = errors.New("must contain only digits, with an optional trailing X for ISBN-10")
+	errISBNChecksum = errors.New("has an invalid check digit")
+	errISBNPrefix   = errors.New("ISBN-13 must start with 978 or 979")
+)
+
+// NormalizeISBN strips hyphens and spaces, verifies the check digit and
+// returns the ISBN-13 form. ISBN-10 input is converted to ISBN-13.
+func NormalizeISBN(raw string) (string, error) {
+	isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(raw))
+
+	switch len(isbn) {
+	case 10:
+		if !validISBN10(isbn) {
+			return "", isbn10Error(isbn)
+		}
+		body := "978" + isbn[:9]
+		return body + string(isbn13CheckDigit(body)), nil
+	case 13:
+		if !allDigits(isbn) {
+			return "", errISBNChars
+		}
+		if !strings.HasPrefix(isbn, "978") && !strings.HasPrefix(isbn, "979") {
+			return "", errISBNPrefix
+		}
+		if isbn13CheckDigit(isbn[:12]) != isbn[12] {
+			return "", errISBNChecksum
+		}
+		return isbn, nil
+	default:
+		return "", errISBNLength
+	}
+}
+
+func validISBN10(isbn string) bool {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		c := isbn[i]
+		var d int
+		switch {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += d * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+func isbn10Error(isbn string) error {
+	if !allDigits(isbn[:9]) || !(isbn[9] == 'X' || (isbn[9] >= '0' && isbn[9] <= '9')) {
+		return errISBNChars
+	}
+	return errISBNChecksum
+}
+
+// isbn13CheckDigit computes the check digit for the first 12 digits of an
+// ISBN-13 using alternating weights of 1 and 3.
+func isbn13CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(body[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
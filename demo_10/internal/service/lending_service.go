@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+	"book-service/pkg/logging"
+)
+
+const (
+	DefaultLoanPeriod   = 21 * 24 * time.Hour
+	DefaultPickupWindow = 3 * 24 * time.Hour
+)
+
+type LendingConfig struct {
+	// LoanPeriod is how long a copy is lent for. Zero means
+	// DefaultLoanPeriod.
+	LoanPeriod time.Duration
+	// PickupWindow is how long a holder has to collect a copy set aside for
+	// them. Zero means DefaultPickupWindow.
+	PickupWindow time.Duration
+	// Fines, if set, assesses the fine on each loan returned late.
+	Fines *FineService
+}
+
+// LendingService runs the circulation desk: members, the copies of each
+// book, checking copies out and back in, and the queue of holds on each
+// book. Due dates, pickup deadlines and loan statuses follow its clock.
+type LendingService struct {
+	repo   repository.LendingStore
+	now    func() time.Time
+	config LendingConfig
+}
+
+func NewLendingService(repo repository.LendingStore, config LendingConfig) *LendingService {
+	if config.LoanPeriod <= 0 {
+		config.LoanPeriod = DefaultLoanPeriod
+	}
+	if config.PickupWindow <= 0 {
+		config.PickupWindow = DefaultPickupWindow
+	}
+	return &LendingService{repo: repo, now: time.Now, config: config}
+}
+
+func (s *LendingService) CreateMember(ctx context.Context, member *models.MemberRequest) (*models.Member, error) {
+	ctx, span := startLendingSpan(ctx, "CreateMember")
+	defer span.End()
+
+	req := *member
+	if err := validateMember(&req); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateMember(ctx, &req)
+}
+
+func (s *LendingService) GetMember(ctx context.Context, id int) (*models.Member, error) {
+	ctx, span := startLendingSpan(ctx, "GetMember")
+	defer span.End()
+
+	return s.repo.GetMember(ctx, id)
+}
+
+func (s *LendingService) AddCopy(ctx context.Context, bookID int, copy *models.CopyRequest) (*models.Copy, error) {
+	ctx, span := startLendingSpan(ctx, "AddCopy")
+	defer span.End()
+
+	req := *copy
+	if err := validateCopy(&req); err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	return s.repo.AddCopy(ctx, bookID, &req, now, now.Add(s.config.PickupWindow))
+}
+
+func (s *LendingService) ListCopies(ctx context.Context, bookID int) ([]models.Copy, error) {
+	ctx, span := startLendingSpan(ctx, "ListCopies")
+	defer span.End()
+
+	return s.repo.ListCopies(ctx, bookID)
+}
+
+// Checkout lends a copy of a book to a member, due back after the loan
+// period.
+func (s *LendingService) Checkout(ctx context.Context, bookID int, req *models.CheckoutRequest) (*models.Loan, error) {
+	ctx, span := startLendingSpan(ctx, "Checkout")
+	defer span.End()
+
+	if err := validateCheckout(req); err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	loan, err := s.repo.Checkout(ctx, models.Checkout{
+		BookID:   bookID,
+		MemberID: req.MemberID,
+		CopyID:   req.CopyID,
+		At:       now,
+		DueAt:    now.Add(s.config.LoanPeriod),
+	})
+	return s.withStatus(loan), err
+}
+
+// ReturnLoan closes a loan. The copy is set aside for the book's first
+// waiting holder, if there is one, and a late loan's fine is settled. The
+// return stands if the fine cannot be assessed; the accrual job picks it up
+// on its next run.
+func (s *LendingService) ReturnLoan(ctx context.Context, id int) (*models.Loan, error) {
+	ctx, span := startLendingSpan(ctx, "ReturnLoan")
+	defer span.End()
+
+	now := s.now().UTC()
+	loan, err := s.repo.ReturnLoan(ctx, id, now, now.Add(s.config.PickupWindow))
+	if err != nil {
+		return nil, err
+	}
+	if s.config.Fines != nil {
+		if _, err := s.config.Fines.AssessLoan(ctx, loan); err != nil {
+			logging.FromContext(ctx).Warn("assessing the fine on a returned loan failed", "loan_id", loan.ID, "error", err)
+		}
+	}
+	return s.withStatus(loan), nil
+}
+
+func (s *LendingService) GetLoan(ctx context.Context, id int) (*models.Loan, error) {
+	ctx, span := startLendingSpan(ctx, "GetLoan")
+	defer span.End()
+
+	loan, err := s.repo.GetLoan(ctx, id)
+	return s.withStatus(loan), err
+}
+
+// ListMemberLoans lists a member's loans, newest first, failing with
+// ErrMemberNotFound rather than returning an empty page for an unknown one.
+func (s *LendingService) ListMemberLoans(ctx context.Context, params models.ListLoansParams) (*models.Page[models.Loan], error) {
+	ctx, span := startLendingSpan(ctx, "ListMemberLoans")
+	defer span.End()
+
+	if _, err := s.repo.GetMember(ctx, params.MemberID); err != nil {
+		return nil, err
+	}
+	params.Now = s.now().UTC()
+	params.Limit = pageSize(params.Limit)
+	page, err := s.repo.ListLoans(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Data {
+		page.Data[i].Status = page.Data[i].StatusAt(params.Now)
+	}
+	return page, nil
+}
+
+// Reserve puts a member in the queue for a book. If a copy is free it is set
+// aside for them at once.
+func (s *LendingService) Reserve(ctx context.Context, bookID int, req *models.ReserveRequest) (*models.Hold, error) {
+	ctx, span := startLendingSpan(ctx, "Reserve")
+	defer span.End()
+
+	if err := validateReserve(req); err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	return s.repo.Reserve(ctx, models.Reserve{
+		BookID:   bookID,
+		MemberID: req.MemberID,
+		At:       now,
+		PickupBy: now.Add(s.config.PickupWindow),
+	})
+}
+
+func (s *LendingService) GetHold(ctx context.Context, id int) (*models.Hold, error) {
+	ctx, span := startLendingSpan(ctx, "GetHold")
+	defer span.End()
+
+	return s.repo.GetHold(ctx, id)
+}
+
+// CancelHold takes a member out of the queue. A copy set aside for them
+// passes to the next holder.
+func (s *LendingService) CancelHold(ctx context.Context, id int) (*models.Hold, error) {
+	ctx, span := startLendingSpan(ctx, "CancelHold")
+	defer span.End()
+
+	now := s.now().UTC()
+	return s.repo.CancelHold(ctx, id, now, now.Add(s.config.PickupWindow))
+}
+
+// ListBookHolds lists the queue for a book, ready holds and waiting ones
+// with their positions.
+func (s *LendingService) ListBookHolds(ctx context.Context, bookID int) ([]models.Hold, error) {
+	ctx, span := startLendingSpan(ctx, "ListBookHolds")
+	defer span.End()
+
+	return s.repo.ListHolds(ctx, bookID)
+}
+
+// ExpireHolds expires holds that were not picked up in time and passes
+// their copies down the queue.
+func (s *LendingService) ExpireHolds(ctx context.Context) (int, error) {
+	ctx, span := startLendingSpan(ctx, "ExpireHolds")
+	defer span.End()
+
+	now := s.now().UTC()
+	return s.repo.ExpireHolds(ctx, now, now.Add(s.config.PickupWindow))
+}
+
+// RunHoldSweep calls ExpireHolds every interval until ctx is done. Replicas
+// may run it concurrently; each hold expires once.
+func (s *LendingService) RunHoldSweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger := logging.FromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.ExpireHolds(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Error("expiring holds failed", "error", err)
+		case n > 0:
+			logger.Info("expired holds", "count", n)
+		}
+	}
+}
+
+func (s *LendingService) withStatus(loan *models.Loan) *models.Loan {
+	if loan != nil {
+		loan.Status = loan.StatusAt(s.now().UTC())
+	}
+	return loan
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+)
+
+// createDune adds a book to store for a test to work with.
+func createDune(t *testing.T, store *repository.MemoryBookStore) *models.Book {
+	t.Helper()
+	book, err := store.CreateBook(t.Context(), &models.CreateBookRequest{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Pages: 412})
+	if err != nil {
+		t.Fatalf("CreateBook() error = %v", err)
+	}
+	return book
+}
+
+func TestLoanStatus(t *testing.T) {
+	const period = 14 * 24 * time.Hour
+	checkedOut := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	store := repository.NewMemoryBookStore()
+	svc := NewLendingService(store, LendingConfig{LoanPeriod: period})
+	svc.now = func() time.Time { return checkedOut }
+
+	book := createDune(t, store)
+	member, err := svc.CreateMember(t.Context(), &models.MemberRequest{Name: "Paul Atreides", Email: "Paul@Arrakis.example"})
+	if err != nil {
+		t.Fatalf("CreateMember() error = %v", err)
+	}
+	if member.Email != "paul@arrakis.example" {
+		t.Errorf("CreateMember() email = %q, want it lowercased", member.Email)
+	}
+	if _, err := svc.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: "DUNE-1"}); err != nil {
+		t.Fatalf("AddCopy() error = %v", err)
+	}
+	loan, err := svc.Checkout(t.Context(), book.ID, &models.CheckoutRequest{MemberID: member.ID})
+	if err != nil {
+		t.Fatalf("Checkout() error = %v", err)
+	}
+	if want := checkedOut.Add(period); !loan.DueAt.Equal(want) {
+		t.Errorf("Checkout() due = %v, want %v", loan.DueAt, want)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want models.LoanStatus
+	}{
+		{name: "checked out", now: checkedOut, want: models.LoanActive},
+		{name: "on the due date", now: checkedOut.Add(period), want: models.LoanActive},
+		{name: "past the due date", now: checkedOut.Add(period + time.Minute), want: models.LoanOverdue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.now = func() time.Time { return tt.now }
+			got, err := svc.GetLoan(t.Context(), loan.ID)
+			if err != nil {
+				t.Fatalf("GetLoan() error = %v", err)
+			}
+			if got.Status != tt.want {
+				t.Errorf("GetLoan() status = %s, want %s", got.Status, tt.want)
+			}
+			page, err := svc.ListMemberLoans(t.Context(), models.ListLoansParams{MemberID: member.ID, Status: tt.want})
+			if err != nil {
+				t.Fatalf("ListMemberLoans() error = %v", err)
+			}
+			if len(page.Data) != 1 || page.Data[0].Status != tt.want {
+				t.Errorf("ListMemberLoans(%s) = %+v, want the loan", tt.want, page.Data)
+			}
+		})
+	}
+
+	svc.now = func() time.Time { return checkedOut.Add(period + 24*time.Hour) }
+	returned, err := svc.ReturnLoan(t.Context(), loan.ID)
+	if err != nil {
+		t.Fatalf("ReturnLoan() error = %v", err)
+	}
+	if returned.Status != models.LoanReturned {
+		t.Errorf("ReturnLoan() status = %s, want %s", returned.Status, models.LoanReturned)
+	}
+}
+
+func TestHoldPickupWindow(t *testing.T) {
+	const window = 48 * time.Hour
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	store := repository.NewMemoryBookStore()
+	svc := NewLendingService(store, LendingConfig{PickupWindow: window})
+	svc.now = func() time.Time { return now }
+
+	book := createDune(t, store)
+	if _, err := svc.AddCopy(t.Context(), book.ID, &models.CopyRequest{Barcode: "DUNE-1"}); err != nil {
+		t.Fatalf("AddCopy() error = %v", err)
+	}
+	var holds []*models.Hold
+	for _, email := range []string{"paul@arrakis.example", "chani@arrakis.example"} {
+		member, err := svc.CreateMember(t.Context(), &models.MemberRequest{Name: "Member", Email: email})
+		if err != nil {
+			t.Fatalf("CreateMember() error = %v", err)
+		}
+		hold, err := svc.Reserve(t.Context(), book.ID, &models.ReserveRequest{MemberID: member.ID})
+		if err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+		holds = append(holds, hold)
+	}
+	if want := now.Add(window); holds[0].PickupBy == nil || !holds[0].PickupBy.Equal(want) {
+		t.Fatalf("Reserve() pickup by = %v, want %v", holds[0].PickupBy, want)
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		expired int
+		want    models.HoldStatus
+	}{
+		{name: "within the window", now: now.Add(window), expired: 0, want: models.HoldWaiting},
+		{name: "past the window", now: now.Add(window + time.Minute), expired: 1, want: models.HoldReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.now = func() time.Time { return tt.now }
+			n, err := svc.ExpireHolds(t.Context())
+			if err != nil || n != tt.expired {
+				t.Fatalf("ExpireHolds() = %d, %v, want %d", n, err, tt.expired)
+			}
+			got, err := svc.GetHold(t.Context(), holds[1].ID)
+			if err != nil || got.Status != tt.want {
+				t.Errorf("GetHold() = %+v, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"book-service/pkg/logging"
+)
+
+// PurgeDeletedBooks permanently removes books that have been in the trash
+// for longer than retention.
+func (s *BookService) PurgeDeletedBooks(ctx context.Context, retention time.Duration) (int, error) {
+	ctx, span := startSpan(ctx, "PurgeDeletedBooks")
+	defer span.End()
+
+	return s.repo.PurgeDeletedBooks(ctx, s.now().Add(-retention))
+}
+
+// RunPurge calls PurgeDeletedBooks every interval until ctx is done. Replicas
+// may run it concurrently; a book is only ever purged once.
+func (s *BookService) RunPurge(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger := logging.FromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := s.PurgeDeletedBooks(ctx, retention)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Error("purging deleted books failed", "error", err)
+		case n > 0:
+			logger.Info("purged deleted books", "count", n, "retention", retention)
+		}
+	}
+}
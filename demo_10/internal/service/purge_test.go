@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"book-service/internal/repository"
+)
+
+func TestPurgeDeletedBooks(t *testing.T) {
+	const retention = 30 * 24 * time.Hour
+
+	store := repository.NewMemoryBookStore()
+	svc := NewBookService(store)
+
+	book := createDune(t, store)
+	if err := svc.DeleteBook(t.Context(), book.ID, nil); err != nil {
+		t.Fatalf("DeleteBook() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{name: "within retention", now: time.Now().Add(retention - time.Hour), want: 0},
+		{name: "past retention", now: time.Now().Add(retention + time.Hour), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.now = func() time.Time { return tt.now }
+			n, err := svc.PurgeDeletedBooks(t.Context(), retention)
+			if err != nil {
+				t.Fatalf("PurgeDeletedBooks() error = %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("PurgeDeletedBooks() = %d, want %d", n, tt.want)
+			}
+		})
+	}
+}
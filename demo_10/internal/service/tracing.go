@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "book-service/internal/service"
+
+func startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "BookService."+op)
+}
+
+func startAuthorSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "AuthorService."+op)
+}
+
+func startLendingSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "LendingService."+op)
+}
+
+func startFineSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "FineService."+op)
+}
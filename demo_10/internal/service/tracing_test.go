@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+)
+
+func TestBookServiceSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	svc := NewBookService(repository.NewMemoryBookStore())
+	ctx, parent := provider.Tracer("test").Start(t.Context(), "request")
+
+	book, err := svc.CreateBook(ctx, &models.CreateBookRequest{
+		Title:     "The Go Programming Language",
+		Author:    "Alan Donovan",
+		ISBN:      "9780134190440",
+		Pages:     380,
+		Published: time.Date(2015, 10, 26, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("CreateBook() error = %v", err)
+	}
+	if _, err := svc.GetBookByID(ctx, book.ID); err != nil {
+		t.Fatalf("GetBookByID() error = %v", err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	want := []string{"BookService.CreateBook", "BookService.GetBookByID", "request"}
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+	for i, span := range spans {
+		if span.Name != want[i] {
+			t.Errorf("span %d name = %q, want %q", i, span.Name, want[i])
+		}
+		if span.SpanContext.TraceID() != parent.SpanContext().TraceID() {
+			t.Errorf("span %q is in a different trace", span.Name)
+		}
+		if i < len(spans)-1 && span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q parent = %s, want request span", span.Name, span.Parent.SpanID())
+		}
+	}
+}
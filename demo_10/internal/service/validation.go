@@ -0,0 +1,258 @@
+package service
+
+import (
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"book-service/internal/models"
+	"book-service/internal/repository"
+)
+
+const (
+	maxTitleLength   = 255
+	maxAuthorLength  = 255
+	maxNameLength    = 255
+	maxEmailLength   = 255
+	maxBarcodeLength = 64
+	maxReasonLength  = 255
+)
+
+// FieldError describes a single rule a request field failed.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every field error found in a request. It matches
+// ErrValidation with errors.Is.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+type validator struct {
+	now    time.Time
+	errors []FieldError
+}
+
+func (v *validator) add(field, code, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+func (v *validator) text(field string, value *string, max int) {
+	*value = strings.TrimSpace(*value)
+	switch {
+	case *value == "":
+		v.add(field, "required", "must not be empty")
+	case utf8.RuneCountInString(*value) > max:
+		v.add(field, "too_long", "must be at most %d characters", max)
+	}
+}
+
+// byline checks the author of a new book, which may be left empty when the
+// book is credited by author IDs.
+func (v *validator) byline(value *string, ids []int) {
+	if len(ids) == 0 {
+		v.text("author", value, maxAuthorLength)
+		return
+	}
+	v.authorIDs(ids)
+	*value = strings.TrimSpace(*value)
+	if utf8.RuneCountInString(*value) > maxAuthorLength {
+		v.add("author", "too_long", "must be at most %d characters", maxAuthorLength)
+	}
+}
+
+func (v *validator) authorIDs(ids []int) {
+	if len(ids) == 0 {
+		v.add("author_ids", "required", "must not be empty")
+		return
+	}
+	seen := make(map[int]bool, len(ids))
+	for i, id := range ids {
+		field := fmt.Sprintf("author_ids[%d]", i)
+		switch {
+		case id <= 0:
+			v.add(field, "out_of_range", "must be greater than zero")
+		case seen[id]:
+			v.add(field, "duplicate", "author %d is already credited", id)
+		}
+		seen[id] = true
+	}
+}
+
+// authorName checks a name given to an author. Bylines are split into names
+// on commas, semicolons, "&" and "and", so a name containing one could never
+// be credited from a byline.
+func (v *validator) authorName(value *string) {
+	v.text("name", value, maxAuthorLength)
+	if *value != "" && len(repository.SplitByline(*value)) != 1 {
+		v.add("name", "invalid_name", "must be a single name without ',', ';', '&' or 'and'")
+	}
+}
+
+func (v *validator) isbn(value *string) {
+	if strings.TrimSpace(*value) == "" {
+		v.add("isbn", "required", "must not be empty")
+		return
+	}
+	normalized, err := NormalizeISBN(*value)
+	if err != nil {
+		v.add("isbn", "invalid_isbn", "%v", err)
+		return
+	}
+	*value = normalized
+}
+
+func (v *validator) pages(value int) {
+	if value <= 0 {
+		v.add("pages", "out_of_range", "must be greater than zero")
+	}
+}
+
+func (v *validator) published(value time.Time) {
+	switch {
+	case value.IsZero():
+		v.add("published", "required", "must be set")
+	case value.After(v.now):
+		v.add("published", "in_future", "must not be in the future")
+	}
+}
+
+// validateCreate checks every field of req and normalizes it in place.
+func validateCreate(req *models.CreateBookRequest, now time.Time) error {
+	v := &validator{now: now}
+	v.text("title", &req.Title, maxTitleLength)
+	v.byline(&req.Author, req.AuthorIDs)
+	v.isbn(&req.ISBN)
+	v.pages(req.Pages)
+	v.published(req.Published)
+	return v.err()
+}
+
+// validateAuthor checks an author request and normalizes it in place.
+func validateAuthor(req *models.AuthorRequest) error {
+	v := &validator{}
+	v.authorName(&req.Name)
+	return v.err()
+}
+
+// validateMember checks a member request and normalizes it in place. Email
+// addresses are compared case-insensitively, so they are stored lowercased.
+func validateMember(req *models.MemberRequest) error {
+	v := &validator{}
+	v.text("name", &req.Name, maxNameLength)
+	v.text("email", &req.Email, maxEmailLength)
+	if req.Email != "" {
+		if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+			v.add("email", "invalid_email", "must be a plain email address")
+		}
+		req.Email = strings.ToLower(req.Email)
+	}
+	if req.Type != "" && !slices.Contains(models.MemberTypes, req.Type) {
+		v.add("type", "invalid_type", "must be one of %v", models.MemberTypes)
+	}
+	return v.err()
+}
+
+func validateClosure(c *models.Closure) error {
+	v := &validator{}
+	v.day("day", &c.Day)
+	c.Reason = strings.TrimSpace(c.Reason)
+	if utf8.RuneCountInString(c.Reason) > maxReasonLength {
+		v.add("reason", "too_long", "must be at most %d characters", maxReasonLength)
+	}
+	return v.err()
+}
+
+// day checks a calendar day given as YYYY-MM-DD.
+func (v *validator) day(field string, value *string) {
+	*value = strings.TrimSpace(*value)
+	if *value == "" {
+		v.add(field, "required", "must not be empty")
+		return
+	}
+	if _, err := time.Parse(time.DateOnly, *value); err != nil {
+		v.add(field, "invalid_date", "must be a date as YYYY-MM-DD")
+	}
+}
+
+func validateCopy(req *models.CopyRequest) error {
+	v := &validator{}
+	v.text("barcode", &req.Barcode, maxBarcodeLength)
+	return v.err()
+}
+
+func validateCheckout(req *models.CheckoutRequest) error {
+	v := &validator{}
+	if req.MemberID <= 0 {
+		v.add("member_id", "required", "must be a member id")
+	}
+	if req.CopyID < 0 {
+		v.add("copy_id", "out_of_range", "must be a copy id")
+	}
+	return v.err()
+}
+
+func validateReserve(req *models.ReserveRequest) error {
+	v := &validator{}
+	if req.MemberID <= 0 {
+		v.add("member_id", "required", "must be a member id")
+	}
+	return v.err()
+}
+
+// validateUpdate applies the create rules to the fields present in a partial
+// update. Normalized values replace the request's pointers rather than being
+// written through them, so the caller's strings are left untouched.
+func validateUpdate(req *models.UpdateBookRequest, now time.Time) error {
+	v := &validator{now: now}
+	if req.Title != nil {
+		title := *req.Title
+		v.text("title", &title, maxTitleLength)
+		req.Title = &title
+	}
+	if req.Author != nil {
+		author := *req.Author
+		v.text("author", &author, maxAuthorLength)
+		req.Author = &author
+	}
+	if req.AuthorIDs != nil {
+		v.authorIDs(*req.AuthorIDs)
+	}
+	if req.ISBN != nil {
+		isbn := *req.ISBN
+		v.isbn(&isbn)
+		req.ISBN = &isbn
+	}
+	if req.Pages != nil {
+		v.pages(*req.Pages)
+	}
+	if req.Published != nil {
+		v.published(*req.Published)
+	}
+	return v.err()
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"book-service/internal/models"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "ISBN-13 with hyphens", input: "978-0-306-40615-7", want: "9780306406157"},
+		{name: "ISBN-10 converted to ISBN-13", input: "0-306-40615-2", want: "9780306406157"},
+		{name: "ISBN-10 with X check digit", input: "0-8044-2957-x", want: "9780804429573"},
+		{name: "ISBN-13 bad checksum", input: "9780306406158", wantErr: errISBNChecksum},
+		{name: "ISBN-10 bad checksum", input: "0306406153", wantErr: errISBNChecksum},
+		{name: "ISBN-13 wrong prefix", input: "1234567890128", wantErr: errISBNPrefix},
+		{name: "letters", input: "97803064061AB", wantErr: errISBNChars},
+		{name: "wrong length", input: "12345", wantErr: errISBNLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeISBN(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeISBN(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeISBN(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	valid := func() models.CreateBookRequest {
+		return models.CreateBookRequest{
+			Title:     " The Go Programming Language ",
+			Author:    "Alan Donovan",
+			ISBN:      "0-13-419044-0",
+			Pages:     380,
+			Published: time.Date(2015, 10, 26, 0, 0, 0, 0, time.UTC),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(r *models.CreateBookRequest)
+		wantFields []string
+	}{
+		{name: "valid request", modify: func(r *models.CreateBookRequest) {}},
+		{name: "empty title", modify: func(r *models.CreateBookRequest) { r.Title = "   " }, wantFields: []string{"title"}},
+		{name: "negative pages", modify: func(r *models.CreateBookRequest) { r.Pages = -1 }, wantFields: []string{"pages"}},
+		{name: "future published date", modify: func(r *models.CreateBookRequest) { r.Published = now.Add(time.Hour) }, wantFields: []string{"published"}},
+		{
+			name:   "author ids instead of a byline",
+			modify: func(r *models.CreateBookRequest) { r.Author, r.AuthorIDs = "", []int{1, 2} },
+		},
+		{
+			name:       "bad author ids",
+			modify:     func(r *models.CreateBookRequest) { r.AuthorIDs = []int{1, 0, 1} },
+			wantFields: []string{"author_ids[1]", "author_ids[2]"},
+		},
+		{
+			name: "several invalid fields",
+			modify: func(r *models.CreateBookRequest) {
+				r.Author = ""
+				r.ISBN = "123456789012345678901"
+			},
+			wantFields: []string{"author", "isbn"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+
+			err := validateCreate(&req, now)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("validateCreate() error = %v, want nil", err)
+				}
+				if req.Title != "The Go Programming Language" || req.ISBN != "9780134190440" {
+					t.Errorf("validateCreate() did not normalize request: %+v", req)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !errors.Is(err, ErrValidation) {
+				t.Fatalf("validateCreate() error = %v, want *ValidationError", err)
+			}
+			if len(verr.Errors) != len(tt.wantFields) {
+				t.Fatalf("validateCreate() returned %d field errors, want %d: %v", len(verr.Errors), len(tt.wantFields), verr.Errors)
+			}
+			for i, field := range tt.wantFields {
+				if verr.Errors[i].Field != field {
+					t.Errorf("field error %d = %q, want %q", i, verr.Errors[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUpdateOnlyChecksPresentFields(t *testing.T) {
+	pages := 0
+	isbn := "978-0-306-40615-7"
+	req := models.UpdateBookRequest{Pages: &pages, ISBN: &isbn}
+
+	err := validateUpdate(&req, time.Now())
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Field != "pages" {
+		t.Fatalf("validateUpdate() error = %v, want a single pages error", err)
+	}
+	if *req.ISBN != "9780306406157" || isbn != "978-0-306-40615-7" {
+		t.Errorf("validateUpdate() ISBN = %q (caller's %q), want normalized copy", *req.ISBN, isbn)
+	}
+}
+
+func TestValidateAuthor(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		wantCode string
+	}{
+		{name: "trimmed", input: "  Ursula K. Le Guin ", want: "Ursula K. Le Guin"},
+		{name: "empty", input: "  ", wantCode: "required"},
+		{name: "byline", input: "Kernighan & Ritchie", wantCode: "invalid_name"},
+		{name: "and inside a word", input: "Sandra Andersen", want: "Sandra Andersen"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.AuthorRequest{Name: tt.input}
+			err := validateAuthor(&req)
+			if tt.wantCode == "" {
+				if err != nil || req.Name != tt.want {
+					t.Errorf("validateAuthor(%q) = %q, %v, want %q", tt.input, req.Name, err, tt.want)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Code != tt.wantCode {
+				t.Errorf("validateAuthor(%q) error = %v, want a single %s error", tt.input, err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidateMember(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode string
+	}{
+		{name: "plain address", email: "paul@arrakis.example"},
+		{name: "missing", email: " ", wantCode: "required"},
+		{name: "no at sign", email: "paul.arrakis.example", wantCode: "invalid_email"},
+		{name: "display name", email: "Paul <paul@arrakis.example>", wantCode: "invalid_email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.MemberRequest{Name: "Paul Atreides", Email: tt.email}
+			err := validateMember(&req)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("validateMember(%q) error = %v", tt.email, err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Code != tt.wantCode {
+				t.Errorf("validateMember(%q) error = %v, want a single %s error", tt.email, err, tt.wantCode)
+			}
+		})
+	}
+}
@@ -2,42 +2,38 @@ package database
 
 import (
 	"database/sql"
-	"fmt"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
-func NewConnection(user, password, host, port, dbname string) (*sql.DB, error) {
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+// PoolConfig sizes the connection pool. Zero values keep database/sql's
+// defaults.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
+func NewConnection(dsn string, pool PoolConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
 	err = db.Ping()
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
-
-func InitializeSchema(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS books (
-		id SERIAL PRIMARY KEY,
-		title VARCHAR(255) NOT NULL,
-		author VARCHAR(255) NOT NULL,
-		isbn VARCHAR(20) UNIQUE NOT NULL,
-		pages INTEGER NOT NULL,
-		published TIMESTAMP NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	_, err := db.Exec(query)
-	return err
-}
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// RegisterPoolMetrics exports db's connection pool statistics (open, in-use
+// and idle connections, wait counts and durations, closed connections) as the
+// go_sql_* metrics, labeled with db_name. They are read from sql.DBStats at
+// scrape time, so nothing is sampled in the background.
+func RegisterPoolMetrics(reg prometheus.Registerer, db *sql.DB, name string) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the pg_advisory_lock key held while migrating, so that
+// replicas starting at the same time apply each migration exactly once.
+const migrationLockID = 72410301
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(fsys, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Latest returns the highest migration version embedded in the binary.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the highest migration version applied to the database.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := ensureMigrationsTable(ctx, m.db); err != nil {
+		return 0, err
+	}
+	return currentVersion(ctx, m.db)
+}
+
+// CheckCompatible fails with ErrSchemaTooNew when the database has
+// migrations applied that this binary does not know about.
+func (m *Migrator) CheckCompatible(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+		target := 0
+		for _, mig := range m.migrations {
+			if mig.Version < version {
+				target = mig.Version
+			}
+		}
+		return m.migrate(ctx, conn, version, target)
+	})
+}
+
+// To migrates up or down until version is the latest applied migration.
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current > m.Latest() {
+			return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaTooNew, current, m.Latest())
+		}
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(ctx, m.db); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &at
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	// Versions applied by a newer binary are reported with an unknown name.
+	for version, at := range applied {
+		at := at
+		statuses = append(statuses, MigrationStatus{Version: version, Name: "(unknown)", Applied: true, AppliedAt: &at})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, from, to int) error {
+	if to >= from {
+		for _, mig := range m.migrations {
+			if mig.Version > from && mig.Version <= to {
+				if err := applyMigration(ctx, conn, mig, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version <= from && mig.Version > to {
+			if err := applyMigration(ctx, conn, mig, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory
+// lock. Session-level advisory locks belong to a connection, so the lock,
+// the migrations and the unlock must all use the same one.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, record, args := mig.Down, `DELETE FROM schema_migrations WHERE version = $1`, []interface{}{mig.Version}
+	if up {
+		script, record, args = mig.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, []interface{}{mig.Version, mig.Name}
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("record migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func ensureMigrationsTable(ctx context.Context, db execQueryer) error {
+	_, err := db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func currentVersion(ctx context.Context, db execQueryer) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// CheckCurrent returns nil when every embedded migration has been applied,
+// and an error describing the gap otherwise. It backs the readiness probe,
+// so unlike Version it only reads: a database without schema_migrations
+// fails the check rather than getting the table created.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	version, err := currentVersion(ctx, m.db)
+	if err != nil {
+		return err
+	}
+	switch latest := m.Latest(); {
+	case version < latest:
+		return fmt.Errorf("database is at version %d, migrations up to %d are pending", version, latest)
+	case version > latest:
+		return fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaTooNew, version, latest)
+	}
+	return nil
+}
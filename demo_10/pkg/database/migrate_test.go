@@ -0,0 +1,69 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d, want contiguous versions starting at 1", i, m.Version)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int
+		wantErr  bool
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"migrations/0002_add_index.up.sql":      {Data: []byte("CREATE INDEX")},
+				"migrations/0002_add_index.down.sql":    {Data: []byte("DROP INDEX")},
+				"migrations/0001_create_books.up.sql":   {Data: []byte("CREATE TABLE")},
+				"migrations/0001_create_books.down.sql": {Data: []byte("DROP TABLE")},
+			},
+			versions: []int{1, 2},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"migrations/0001_create_books.up.sql": {Data: []byte("CREATE TABLE")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unexpected file name",
+			files: fstest.MapFS{
+				"migrations/create_books.sql": {Data: []byte("CREATE TABLE")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(migrations) != len(tt.versions) {
+				t.Fatalf("loadMigrations() returned %d migrations, want %d", len(migrations), len(tt.versions))
+			}
+			for i, v := range tt.versions {
+				if migrations[i].Version != v {
+					t.Errorf("migration %d version = %d, want %d", i, migrations[i].Version, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS books (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(255) NOT NULL,
+	author VARCHAR(255) NOT NULL,
+	isbn VARCHAR(20) UNIQUE NOT NULL,
+	pages INTEGER NOT NULL,
+	published TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_books_created_at_id ON books (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_books_published_id ON books (published, id);
@@ -0,0 +1,7 @@
+ALTER TABLE books ADD COLUMN search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(author, '')), 'B')
+	) STORED;
+
+CREATE INDEX idx_books_search_vector ON books USING GIN (search_vector);
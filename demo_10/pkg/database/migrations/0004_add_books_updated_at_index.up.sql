@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_books_updated_at_id ON books (updated_at DESC, id DESC);
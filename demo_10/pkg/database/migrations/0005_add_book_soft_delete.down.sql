@@ -0,0 +1,8 @@
+-- Trashed books may share an ISBN with a live one, which the full unique
+-- constraint cannot hold, so they are purged first.
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_books_deleted_at;
+DROP INDEX IF EXISTS books_isbn_live_key;
+ALTER TABLE books ADD CONSTRAINT books_isbn_key UNIQUE (isbn);
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,8 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Only live books need distinct ISBNs, so a book can be created again while
+-- its trashed copy waits to be purged.
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_isbn_key;
+CREATE UNIQUE INDEX IF NOT EXISTS books_isbn_live_key ON books (isbn) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,14 @@
+-- One row per write to a book, keyed by the version the write produced.
+-- There is no foreign key to books so history outlives purged books.
+CREATE TABLE IF NOT EXISTS book_history (
+	book_id INTEGER NOT NULL,
+	revision INTEGER NOT NULL,
+	action VARCHAR(20) NOT NULL,
+	actor TEXT NOT NULL DEFAULT '',
+	request_id VARCHAR(128) NOT NULL DEFAULT '',
+	changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	changes JSONB NOT NULL,
+	-- The tracked fields after the write, which a revert puts back.
+	snapshot JSONB NOT NULL,
+	PRIMARY KEY (book_id, revision)
+);
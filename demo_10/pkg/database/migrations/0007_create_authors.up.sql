@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS authors (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Names are not unique: different people can share one. Bylines are matched
+-- to authors by name, and fail when it is ambiguous.
+CREATE INDEX IF NOT EXISTS idx_authors_name ON authors (name);
+
+-- Credits in byline order. Deleting a book drops its credits; an author
+-- cannot be deleted while credited.
+CREATE TABLE IF NOT EXISTS book_authors (
+	book_id INTEGER NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	author_id INTEGER NOT NULL REFERENCES authors (id),
+	position SMALLINT NOT NULL,
+	PRIMARY KEY (book_id, author_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_authors_author_id ON book_authors (author_id);
+
+-- Credit existing books by splitting their bylines the way
+-- repository.SplitByline does.
+INSERT INTO authors (name)
+SELECT DISTINCT name
+FROM books, regexp_split_to_table(trim(books.author), '\s*(?:,|;|&|\s+and\s+)\s*') AS name
+WHERE name <> '';
+
+INSERT INTO book_authors (book_id, author_id, position)
+SELECT book_id, author_id, row_number() OVER (PARTITION BY book_id ORDER BY first) - 1
+FROM (
+	SELECT books.id AS book_id, authors.id AS author_id, min(credit.ordinality) AS first
+	FROM books
+	CROSS JOIN regexp_split_to_table(trim(books.author), '\s*(?:,|;|&|\s+and\s+)\s*') WITH ORDINALITY AS credit (name, ordinality)
+	JOIN authors ON authors.name = credit.name
+	GROUP BY books.id, authors.id
+) credits
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS loans;
+DROP TABLE IF EXISTS copies;
+DROP TABLE IF EXISTS members;
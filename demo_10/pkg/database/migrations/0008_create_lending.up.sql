@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS members (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT members_email_key UNIQUE (email)
+);
+
+-- Loans are records to keep: a copy that has been lent, and so its book,
+-- cannot be deleted. The purge job deletes only books never lent, and their
+-- copies first.
+CREATE TABLE IF NOT EXISTS copies (
+	id SERIAL PRIMARY KEY,
+	book_id INTEGER NOT NULL REFERENCES books (id) ON DELETE RESTRICT,
+	barcode VARCHAR(64) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT copies_barcode_key UNIQUE (barcode)
+);
+
+CREATE INDEX IF NOT EXISTS idx_copies_book_id ON copies (book_id, id);
+
+CREATE TABLE IF NOT EXISTS loans (
+	id SERIAL PRIMARY KEY,
+	copy_id INTEGER NOT NULL REFERENCES copies (id) ON DELETE RESTRICT,
+	book_id INTEGER NOT NULL,
+	member_id INTEGER NOT NULL REFERENCES members (id),
+	checked_out_at TIMESTAMP NOT NULL,
+	due_at TIMESTAMP NOT NULL,
+	returned_at TIMESTAMP,
+	CHECK (due_at > checked_out_at)
+);
+
+-- A copy has at most one open loan.
+CREATE UNIQUE INDEX IF NOT EXISTS loans_open_copy_key ON loans (copy_id) WHERE returned_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_loans_member_id ON loans (member_id, id);
+CREATE INDEX IF NOT EXISTS idx_loans_book_id ON loans (book_id);
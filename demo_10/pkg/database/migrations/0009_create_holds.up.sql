@@ -0,0 +1,22 @@
+-- A hold waits in its book's queue until a copy is set aside for it, then is
+-- ready until pickup_by. Holds go with their book when it is purged.
+CREATE TABLE IF NOT EXISTS holds (
+	id SERIAL PRIMARY KEY,
+	book_id INTEGER NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	member_id INTEGER NOT NULL REFERENCES members (id),
+	status VARCHAR(16) NOT NULL
+		CHECK (status IN ('waiting', 'ready', 'fulfilled', 'cancelled', 'expired')),
+	copy_id INTEGER REFERENCES copies (id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	ready_at TIMESTAMP,
+	pickup_by TIMESTAMP,
+	closed_at TIMESTAMP,
+	CHECK (status <> 'ready' OR (copy_id IS NOT NULL AND ready_at IS NOT NULL AND pickup_by IS NOT NULL))
+);
+
+-- A member is in a book's queue at most once, and a copy is set aside for
+-- at most one hold.
+CREATE UNIQUE INDEX IF NOT EXISTS holds_open_member_key ON holds (book_id, member_id) WHERE status IN ('waiting', 'ready');
+CREATE UNIQUE INDEX IF NOT EXISTS holds_ready_copy_key ON holds (copy_id) WHERE status = 'ready';
+CREATE INDEX IF NOT EXISTS idx_holds_queue ON holds (book_id, id) WHERE status = 'waiting';
+CREATE INDEX IF NOT EXISTS idx_holds_pickup_by ON holds (pickup_by) WHERE status = 'ready';
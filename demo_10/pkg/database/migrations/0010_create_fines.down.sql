@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fines;
+DROP TABLE IF EXISTS closures;
+ALTER TABLE members DROP COLUMN IF EXISTS type;
@@ -0,0 +1,27 @@
+-- The member type sets the cap on a member's fines.
+ALTER TABLE members ADD COLUMN IF NOT EXISTS type VARCHAR(16) NOT NULL DEFAULT 'standard'
+	CHECK (type IN ('standard', 'student', 'senior'));
+
+-- Days the library is closed, on which fines do not accrue.
+CREATE TABLE IF NOT EXISTS closures (
+	day DATE PRIMARY KEY,
+	reason VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+-- One fine per loan, in cents, kept up to date while the loan is open and
+-- final once it is returned. A fine is owed whatever becomes of the book,
+-- so its loan cannot be deleted from under it.
+CREATE TABLE IF NOT EXISTS fines (
+	id SERIAL PRIMARY KEY,
+	loan_id INTEGER NOT NULL REFERENCES loans (id) ON DELETE RESTRICT,
+	member_id INTEGER NOT NULL REFERENCES members (id),
+	days INTEGER NOT NULL CHECK (days >= 0),
+	amount INTEGER NOT NULL CHECK (amount >= 0),
+	paid INTEGER NOT NULL DEFAULT 0 CHECK (paid >= 0),
+	final BOOLEAN NOT NULL DEFAULT FALSE,
+	assessed_at TIMESTAMP NOT NULL,
+	paid_at TIMESTAMP,
+	CONSTRAINT fines_loan_id_key UNIQUE (loan_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fines_member_id ON fines (member_id, id);
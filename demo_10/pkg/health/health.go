@@ -0,0 +1,114 @@
+// Package health serves liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"book-service/pkg/logging"
+)
+
+// DefaultCheckTimeout bounds each readiness check so a hung dependency
+// fails the probe instead of stalling it.
+const DefaultCheckTimeout = 2 * time.Second
+
+// CheckFunc reports whether a dependency is usable.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker answers /livez from the process alone and /readyz from the
+// registered dependency checks. Once shutdown starts, readiness fails so
+// load balancers stop sending traffic while in-flight requests drain.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       []check
+	shuttingDown atomic.Bool
+	timeout      time.Duration
+}
+
+func NewChecker() *Checker {
+	return &Checker{timeout: DefaultCheckTimeout}
+}
+
+// AddReadinessCheck registers fn under name. Checks run concurrently on
+// every /readyz request.
+func (c *Checker) AddReadinessCheck(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// SetShuttingDown makes readiness fail from now on.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+type response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Livez reports that the process is up and serving. It deliberately ignores
+// dependencies: restarting the service would not bring the database back.
+func (c *Checker) Livez(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, response{Status: "ok"})
+}
+
+// Readyz runs every readiness check and reports 503 if any fails or the
+// service is shutting down. Probes are unauthenticated, so a failed check
+// shows only as "unavailable"; its error, which may name hosts and drivers,
+// goes to the log.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	if c.shuttingDown.Load() {
+		writeResponse(w, http.StatusServiceUnavailable, response{Status: "shutting_down"})
+		return
+	}
+
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	results := make([]string, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = "ok"
+			if err := chk.fn(ctx); err != nil {
+				logging.FromContext(ctx).Warn("readiness check failed", "check", chk.name, "error", err)
+				results[i] = "unavailable"
+			}
+		}()
+	}
+	wg.Wait()
+
+	res := response{Status: "ok", Checks: make(map[string]string, len(checks))}
+	status := http.StatusOK
+	for i, chk := range checks {
+		res.Checks[chk.name] = results[i]
+		if results[i] != "ok" {
+			res.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeResponse(w, status, res)
+}
+
+func writeResponse(w http.ResponseWriter, status int, res response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
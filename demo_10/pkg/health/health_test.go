@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name         string
+		checks       map[string]CheckFunc
+		shuttingDown bool
+		wantStatus   int
+		wantBody     response
+	}{
+		{
+			name:       "all checks pass",
+			checks:     map[string]CheckFunc{"database": ok, "migrations": ok},
+			wantStatus: http.StatusOK,
+			wantBody:   response{Status: "ok", Checks: map[string]string{"database": "ok", "migrations": "ok"}},
+		},
+		{
+			name:       "dependency down",
+			checks:     map[string]CheckFunc{"database": down, "migrations": ok},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   response{Status: "unavailable", Checks: map[string]string{"database": "unavailable", "migrations": "ok"}},
+		},
+		{
+			name:         "shutting down",
+			checks:       map[string]CheckFunc{"database": ok},
+			shuttingDown: true,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantBody:     response{Status: "shutting_down"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker()
+			for name, fn := range tt.checks {
+				c.AddReadinessCheck(name, fn)
+			}
+			if tt.shuttingDown {
+				c.SetShuttingDown()
+			}
+
+			rec := httptest.NewRecorder()
+			c.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var got response
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if got.Status != tt.wantBody.Status || len(got.Checks) != len(tt.wantBody.Checks) {
+				t.Fatalf("body = %+v, want %+v", got, tt.wantBody)
+			}
+			for name, want := range tt.wantBody.Checks {
+				if got.Checks[name] != want {
+					t.Errorf("check %s = %q, want %q", name, got.Checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLivezIgnoresDependencies(t *testing.T) {
+	c := NewChecker()
+	c.AddReadinessCheck("database", func(context.Context) error { return errors.New("down") })
+	c.SetShuttingDown()
+
+	rec := httptest.NewRecorder()
+	c.Livez(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{in: "", want: slog.LevelInfo},
+		{in: "debug", want: slog.LevelDebug},
+		{in: "WARN", want: slog.LevelWarn},
+		{in: "error", want: slog.LevelError},
+		{in: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLevel(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromContextDefaultsToDefaultLogger(t *testing.T) {
+	if got := FromContext(t.Context()); got != slog.Default() {
+		t.Error("FromContext() without a logger did not return slog.Default()")
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	if got := FromContext(NewContext(t.Context(), logger)); got != logger {
+		t.Error("FromContext() did not return the logger stored by NewContext")
+	}
+}
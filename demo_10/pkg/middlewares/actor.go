@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"book-service/pkg/logging"
+)
+
+// NewActor records who a request acts for, as chosen by key, in the request
+// context. The store writes it into the history of every book the request
+// changes.
+func NewActor(key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if actor := key(r); actor != "" {
+				r = r.WithContext(logging.WithActor(r.Context(), actor))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
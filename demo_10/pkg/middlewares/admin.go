@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+)
+
+const AdminTokenHeader = "X-Admin-Token"
+
+type adminKey struct{}
+
+// NewAdminAuth marks requests that carry an admin token in X-Admin-Token
+// as admin requests; handlers decide what needs it with IsAdmin. tokens
+// maps each admin's name to their token, and the name is kept for
+// KeyByAdmin. Nothing is rejected here. Empty tokens never match, so
+// without any admin access is disabled entirely.
+func NewAdminAuth(tokens map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := r.Header.Get(AdminTokenHeader)
+			for name, token := range tokens {
+				if token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+					r = r.WithContext(context.WithValue(r.Context(), adminKey{}, name))
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsAdmin reports whether the request was authenticated by NewAdminAuth.
+func IsAdmin(ctx context.Context) bool {
+	_, admin := ctx.Value(adminKey{}).(string)
+	return admin
+}
+
+// KeyByAdmin keys admin requests by the name of the admin whose token they
+// carry. It identifies the actor of a request rather than a rate limit
+// bucket.
+func KeyByAdmin(r *http.Request) string {
+	name, _ := r.Context().Value(adminKey{}).(string)
+	return name
+}
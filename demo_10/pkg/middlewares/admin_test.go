@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"book-service/pkg/logging"
+)
+
+func TestAdminAuth(t *testing.T) {
+	tokens := map[string]string{"admin": "s3cret", "admin:alice": "al1ce"}
+
+	tests := []struct {
+		name   string
+		tokens map[string]string
+		header string
+		want   string
+	}{
+		{name: "shared token", tokens: tokens, header: "s3cret", want: "admin"},
+		{name: "named token", tokens: tokens, header: "al1ce", want: "admin:alice"},
+		{name: "wrong token", tokens: tokens, header: "guess"},
+		{name: "missing header", tokens: tokens},
+		{name: "empty token", tokens: map[string]string{"admin": ""}, header: ""},
+		{name: "disabled", header: "s3cret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var admin bool
+			var key string
+			h := NewAdminAuth(tt.tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				admin, key = IsAdmin(r.Context()), KeyByAdmin(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/books", nil)
+			if tt.header != "" {
+				req.Header.Set(AdminTokenHeader, tt.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if admin != (tt.want != "") || key != tt.want {
+				t.Errorf("IsAdmin() = %v, KeyByAdmin() = %q, want %q", admin, key, tt.want)
+			}
+		})
+	}
+}
+
+func TestActor(t *testing.T) {
+	secret := []byte("jwt-s3cret")
+	// {"alg":"none"}.{"sub":"librarian-7"}.
+	const unsigned = "Bearer eyJhbGciOiJub25lIn0.eyJzdWIiOiJsaWJyYXJpYW4tNyJ9.sig"
+	signed := "Bearer " + signJWT(t, secret, `{"sub":"librarian-7"}`)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   string
+	}{
+		{name: "admin", header: map[string]string{AdminTokenHeader: "s3cret", "Authorization": signed}, want: "admin"},
+		{name: "named admin", header: map[string]string{AdminTokenHeader: "al1ce"}, want: "admin:alice"},
+		{name: "verified subject", header: map[string]string{"Authorization": signed}, want: "sub:librarian-7"},
+		{name: "unsigned token is not trusted", header: map[string]string{"Authorization": unsigned}, want: "ip:192.0.2.1"},
+		{name: "anonymous", want: "ip:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			actor := NewActor(FirstKey(KeyByAdmin, KeyByVerifiedSubject, KeyByIP))
+			h := NewAdminAuth(map[string]string{"admin": "s3cret", "admin:alice": "al1ce"})(NewJWTAuth(secret)(actor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = logging.Actor(r.Context())
+			}))))
+
+			req := httptest.NewRequest(http.MethodPut, "/api/books/1", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("actor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
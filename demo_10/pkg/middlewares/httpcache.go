@@ -0,0 +1,148 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+type HTTPCacheConfig struct {
+	// CacheControl is sent with successful responses, e.g.
+	// "public, max-age=60". Empty sends none. Error responses always get
+	// no-store so a transient failure is never cached.
+	CacheControl string
+	// Vary names the request headers that select between representations,
+	// such as Accept on routes that negotiate a format.
+	Vary []string
+}
+
+// NewHTTPCache adds caching headers to a route's GET responses and answers
+// conditional GETs. Handlers only set ETag and Last-Modified; when the
+// request's If-None-Match or If-Modified-Since shows the client's copy is
+// current, the response becomes 304 Not Modified and the body is dropped.
+// Headers the handler set itself are left alone.
+func NewHTTPCache(cfg HTTPCacheConfig) func(next http.Handler) http.Handler {
+	vary := strings.Join(cfg.Vary, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&cacheWriter{ResponseWriter: w, r: r, cacheControl: cfg.CacheControl, vary: vary}, r)
+		})
+	}
+}
+
+// NotModified evaluates r's If-None-Match and If-Modified-Since against the
+// validators in the response header h, as RFC 9110 prescribes for GET: when
+// If-None-Match is present it decides alone, using weak comparison.
+func NotModified(r *http.Request, h http.Header) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := strings.TrimSpace(r.Header.Get("If-None-Match")); inm != "" {
+		if inm == "*" {
+			return true
+		}
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			if opaqueTag(tag) == opaqueTag(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// SetLastModified sends t as Last-Modified. HTTP dates have one-second
+// resolution, so t is left out until its second has passed: a later write in
+// the same second would share the date and a client revalidating with it
+// would never see that write.
+func SetLastModified(w http.ResponseWriter, t time.Time) {
+	if t.IsZero() || time.Now().Before(t.Truncate(time.Second).Add(time.Second)) {
+		return
+	}
+	w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// opaqueTag strips the weakness indicator for weak comparison.
+func opaqueTag(tag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+}
+
+// cacheWriter adds the caching headers once the status is known and turns a
+// 200 into a 304 when the request's validators match.
+type cacheWriter struct {
+	http.ResponseWriter
+	r            *http.Request
+	cacheControl string
+	vary         string
+	wroteHeader  bool
+	notModified  bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.wroteHeader || status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	if status == http.StatusOK && NotModified(w.r, h) {
+		status = http.StatusNotModified
+		w.notModified = true
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+	}
+	if w.vary != "" {
+		h.Add("Vary", w.vary)
+	}
+	if h.Get("Cache-Control") == "" {
+		if status >= http.StatusBadRequest {
+			h.Set("Cache-Control", "no-store")
+		} else if w.cacheControl != "" {
+			h.Set("Cache-Control", w.cacheControl)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPCacheMiddleware(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	cache := NewHTTPCache(HTTPCacheConfig{CacheControl: "public, max-age=60", Vary: []string{"Accept"}})
+	handler := cache(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"3"`)
+		SetLastModified(w, modified)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1}`))
+	}))
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		header           map[string]string
+		wantStatus       int
+		wantBody         bool
+		wantCacheControl string
+	}{
+		{name: "plain get", wantStatus: http.StatusOK, wantBody: true, wantCacheControl: "public, max-age=60"},
+		{name: "matching etag", header: map[string]string{"If-None-Match": `"2", W/"3"`}, wantStatus: http.StatusNotModified, wantCacheControl: "public, max-age=60"},
+		{name: "stale etag", header: map[string]string{"If-None-Match": `"2"`}, wantStatus: http.StatusOK, wantBody: true, wantCacheControl: "public, max-age=60"},
+		{name: "wildcard etag", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified, wantCacheControl: "public, max-age=60"},
+		{name: "not modified since", header: map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, wantStatus: http.StatusNotModified, wantCacheControl: "public, max-age=60"},
+		{name: "modified since", header: map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}, wantStatus: http.StatusOK, wantBody: true, wantCacheControl: "public, max-age=60"},
+		{
+			name:             "etag wins over date",
+			header:           map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": modified.Format(http.TimeFormat)},
+			wantStatus:       http.StatusOK,
+			wantBody:         true,
+			wantCacheControl: "public, max-age=60",
+		},
+		{name: "malformed date", header: map[string]string{"If-Modified-Since": "yesterday"}, wantStatus: http.StatusOK, wantBody: true, wantCacheControl: "public, max-age=60"},
+		{name: "error", path: "/missing", wantStatus: http.StatusNotFound, wantBody: true, wantCacheControl: "no-store"},
+		{name: "unsafe method", method: http.MethodPut, header: map[string]string{"If-None-Match": `"3"`}, wantStatus: http.StatusOK, wantBody: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, path := tt.method, tt.path
+			if method == "" {
+				method = http.MethodGet
+			}
+			if path == "" {
+				path = "/api/books/1"
+			}
+			req := httptest.NewRequest(method, path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Body.Len() > 0; got != tt.wantBody {
+				t.Errorf("body = %q, want body %v", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+			if method == http.MethodGet && rec.Header().Get("Vary") != "Accept" {
+				t.Errorf("Vary = %q, want Accept", rec.Header().Get("Vary"))
+			}
+			if rec.Code == http.StatusNotModified && rec.Header().Get("Content-Type") != "" {
+				t.Errorf("304 carries Content-Type %q", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestSetLastModified(t *testing.T) {
+	rec := httptest.NewRecorder()
+	SetLastModified(rec, time.Now())
+	if got := rec.Header().Get("Last-Modified"); got != "" {
+		t.Errorf("Last-Modified for the current second = %q, want none", got)
+	}
+
+	past := time.Date(2025, 3, 1, 12, 0, 0, 500_000_000, time.FixedZone("CET", 3600))
+	SetLastModified(rec, past)
+	if got, want := rec.Header().Get("Last-Modified"), "Sat, 01 Mar 2025 11:00:00 GMT"; got != want {
+		t.Errorf("Last-Modified = %q, want %q", got, want)
+	}
+}
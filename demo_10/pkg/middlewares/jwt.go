@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type subjectKey struct{}
+
+type jwtClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+}
+
+// NewJWTAuth verifies HS256 bearer tokens against secret and marks the
+// subject of a valid, current one for KeyByVerifiedSubject. Nothing is
+// rejected here. An empty secret verifies no token.
+func NewJWTAuth(secret []byte) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(secret) > 0 {
+				if sub, ok := verifyJWT(r, secret, time.Now()); ok {
+					r = r.WithContext(context.WithValue(r.Context(), subjectKey{}, sub))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// verifyJWT returns the subject of r's bearer token if it is signed with
+// secret and valid at now.
+func verifyJWT(r *http.Request, secret []byte, now time.Time) (string, bool) {
+	parts, ok := bearerParts(r)
+	if !ok {
+		return "", false
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	var h struct {
+		Algorithm string `json:"alg"`
+	}
+	if json.Unmarshal(header, &h) != nil || h.Algorithm != "HS256" {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", false
+	}
+
+	claims, ok := parseClaims(parts[1])
+	if !ok || claims.Subject == "" {
+		return "", false
+	}
+	if claims.ExpiresAt != nil && !now.Before(time.Unix(*claims.ExpiresAt, 0)) {
+		return "", false
+	}
+	if claims.NotBefore != nil && now.Before(time.Unix(*claims.NotBefore, 0)) {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// bearerParts splits r's bearer token into its header, payload and
+// signature.
+func bearerParts(r *http.Request) ([]string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, false
+	}
+	parts := strings.Split(token, ".")
+	return parts, len(parts) == 3
+}
+
+func parseClaims(payload string) (jwtClaims, bool) {
+	var claims jwtClaims
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &claims) != nil {
+		return jwtClaims{}, false
+	}
+	return claims, true
+}
+
+// Subject returns the subject of the bearer token NewJWTAuth verified for
+// the request, if any.
+func Subject(ctx context.Context) (string, bool) {
+	sub, ok := ctx.Value(subjectKey{}).(string)
+	return sub, ok
+}
+
+// KeyByVerifiedSubject keys on the subject of a bearer token that
+// NewJWTAuth verified.
+func KeyByVerifiedSubject(r *http.Request) string {
+	if sub, ok := Subject(r.Context()); ok {
+		return "sub:" + sub
+	}
+	return ""
+}
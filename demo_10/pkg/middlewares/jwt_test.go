@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signJWT signs claims with secret as an HS256 token.
+func signJWT(t *testing.T, secret []byte, claims string) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTAuth(t *testing.T) {
+	secret := []byte("jwt-s3cret")
+	now := time.Now().Unix()
+	enc := base64.RawURLEncoding
+
+	tests := []struct {
+		name   string
+		secret []byte
+		token  string
+		want   string
+	}{
+		{name: "valid", secret: secret, token: signJWT(t, secret, `{"sub":"librarian-7"}`), want: "sub:librarian-7"},
+		{name: "current", secret: secret, token: signJWT(t, secret, fmt.Sprintf(`{"sub":"librarian-7","nbf":%d,"exp":%d}`, now-60, now+60)), want: "sub:librarian-7"},
+		{name: "expired", secret: secret, token: signJWT(t, secret, fmt.Sprintf(`{"sub":"librarian-7","exp":%d}`, now-60))},
+		{name: "not yet valid", secret: secret, token: signJWT(t, secret, fmt.Sprintf(`{"sub":"librarian-7","nbf":%d}`, now+60))},
+		{name: "wrong secret", secret: secret, token: signJWT(t, []byte("guess"), `{"sub":"librarian-7"}`)},
+		{name: "no subject", secret: secret, token: signJWT(t, secret, `{"exp":9999999999}`)},
+		{
+			name:   "alg none",
+			secret: secret,
+			token:  enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(`{"sub":"librarian-7"}`)) + ".",
+		},
+		{name: "malformed", secret: secret, token: "not-a-token"},
+		{name: "no secret configured", token: signJWT(t, nil, `{"sub":"librarian-7"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := NewJWTAuth(tt.secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = KeyByVerifiedSubject(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("KeyByVerifiedSubject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"book-service/pkg/logging"
+)
+
+// NewAccessLog gives every request a logger tagged with its request and
+// trace IDs, stores it in the request context for handlers and the layers
+// below them, and logs one line per request once the response is written.
+// Install it after RequestID and Tracing so both IDs are known.
+func NewAccessLog(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqLogger := logger
+			if id := logging.RequestID(r.Context()); id != "" {
+				reqLogger = reqLogger.With("request_id", id)
+			}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				reqLogger = reqLogger.With("trace_id", sc.TraceID().String())
+			}
+
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(logging.NewContext(r.Context(), reqLogger)))
+
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			reqLogger.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", routeTemplate(r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.status),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
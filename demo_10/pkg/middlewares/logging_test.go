@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"book-service/pkg/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "accepts caller id", incoming: "req-123", wantSame: true},
+		{name: "generates when missing", incoming: ""},
+		{name: "rejects control characters", incoming: "req\n{\"forged\":true}"},
+		{name: "rejects oversized id", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inContext string
+			h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inContext = logging.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" || got != inContext {
+				t.Fatalf("response id = %q, context id = %q, want the same non-empty id", got, inContext)
+			}
+			if (got == tt.incoming) != tt.wantSame {
+				t.Errorf("id = %q, incoming %q, want reused = %v", got, tt.incoming, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+
+	r := mux.NewRouter()
+	r.Use(RequestID)
+	r.Use(NewAccessLog(logger))
+	r.HandleFunc("/api/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Error("query failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/books/7", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), buf.String())
+	}
+
+	var handlerLine, accessLine map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &handlerLine); err != nil {
+		t.Fatalf("handler log line is not JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &accessLine); err != nil {
+		t.Fatalf("access log line is not JSON: %v", err)
+	}
+
+	if handlerLine["request_id"] != "req-123" {
+		t.Errorf("handler log request_id = %v, want req-123", handlerLine["request_id"])
+	}
+	want := map[string]interface{}{
+		"level":      "ERROR",
+		"msg":        "request",
+		"request_id": "req-123",
+		"method":     "GET",
+		"route":      "/api/books/{id}",
+		"path":       "/api/books/7",
+		"status":     float64(500),
+	}
+	for k, v := range want {
+		if accessLine[k] != v {
+			t.Errorf("access log %s = %v, want %v", k, accessLine[k], v)
+		}
+	}
+	if _, ok := accessLine["duration_ms"].(float64); !ok {
+		t.Errorf("access log duration_ms = %v, want a number", accessLine["duration_ms"])
+	}
+}
@@ -0,0 +1,144 @@
+package middlewares
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultDurationBuckets are tuned for an API whose P99 alert fires at two
+// seconds: fine-grained below it, coarse above.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 1.5, 2, 3, 5, 10}
+
+type MetricsConfig struct {
+	// Prefix is prepended to every metric name, e.g. "book" gives
+	// book_requests_total.
+	Prefix string
+	// Buckets for the duration histogram. Defaults to DefaultDurationBuckets.
+	Buckets []float64
+	// Registerer receives the collectors. Defaults to the global registry;
+	// tests pass their own so they do not collide.
+	Registerer prometheus.Registerer
+}
+
+// Metrics records rate, errors and duration per route template, method and
+// status code.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func NewMetrics(cfg MetricsConfig) (*Metrics, error) {
+	if cfg.Buckets == nil {
+		cfg.Buckets = DefaultDurationBuckets
+	}
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+	name := func(s string) string {
+		if cfg.Prefix == "" {
+			return s
+		}
+		return cfg.Prefix + "_" + s
+	}
+
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: name("requests_total"),
+			Help: "Total number of requests by route, method and status code",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    name("request_duration_seconds"),
+			Help:    "Duration of requests in seconds by route, method and status code",
+			Buckets: cfg.Buckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name("requests_in_flight"),
+			Help: "Number of requests currently being served by route and method",
+		}, []string{"route", "method"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.requests, m.duration, m.inFlight} {
+		if err := cfg.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Middleware must be installed with mux.Router.Use so the matched route is
+// known; labels use the route template (/api/books/{id}) rather than the raw
+// path to keep cardinality bounded.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		inFlight := m.inFlight.WithLabelValues(route, r.Method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.status)
+		m.requests.WithLabelValues(route, r.Method, status).Inc()
+		m.duration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeTemplate returns the template of the route mux matched, e.g.
+// /api/books/{id}, so labels and span names stay low-cardinality.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// statusRecorder captures the status code written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
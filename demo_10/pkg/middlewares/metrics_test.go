@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(MetricsConfig{Prefix: "book", Registerer: reg})
+	if err != nil {
+		t.Fatalf("NewMetrics() error = %v", err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/api/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "404" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods("GET", "DELETE")
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/api/books/1"},
+		{http.MethodGet, "/api/books/2"},
+		{http.MethodGet, "/api/books/404"},
+		{http.MethodDelete, "/api/books/1"},
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	want := `
+# HELP book_requests_total Total number of requests by route, method and status code
+# TYPE book_requests_total counter
+book_requests_total{method="DELETE",route="/api/books/{id}",status="200"} 1
+book_requests_total{method="GET",route="/api/books/{id}",status="200"} 2
+book_requests_total{method="GET",route="/api/books/{id}",status="404"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "book_requests_total"); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.ToFloat64(m.inFlight.WithLabelValues("/api/books/{id}", http.MethodGet)); got != 0 {
+		t.Errorf("in-flight gauge = %v after requests finished, want 0", got)
+	}
+}
+
+func TestNewMetricsIsolatedRegistries(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		if _, err := NewMetrics(MetricsConfig{Prefix: "book", Registerer: prometheus.NewRegistry()}); err != nil {
+			t.Fatalf("NewMetrics() #%d error = %v", i, err)
+		}
+	}
+
+	reg := prometheus.NewRegistry()
+	NewMetrics(MetricsConfig{Registerer: reg})
+	if _, err := NewMetrics(MetricsConfig{Registerer: reg}); err == nil {
+		t.Errorf("NewMetrics() on the same registry twice succeeded, want a registration error")
+	}
+}
@@ -1,17 +1,202 @@
 package middlewares
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"go.uber.org/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"book-service/pkg/logging"
 )
 
-func NewRateLimitMiddleware(ratePerSec int) func(next http.Handler) http.Handler {
-	rl := ratelimit.New(ratePerSec)
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			rl.Take()
-			next.ServeHTTP(w, r)
-		})
+var rateLimitedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_rate_limited_requests_total",
+	Help: "Total number of requests rejected by the rate limiter",
+}, []string{"route"})
+
+var rateLimitStoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_rate_limit_store_errors_total",
+	Help: "Total number of rate limit checks that failed because the store was unreachable",
+}, []string{"route"})
+
+// KeyFunc picks the bucket a request is charged to. An empty key means the
+// function cannot identify the client.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP keys on the connection's remote address. Behind a proxy every
+// client shares the proxy's address, so prefer a verified credential there.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// KeyByHeader keys on the value of a request header such as X-API-Key. The
+// client chooses the value, so it must only be used for a header that
+// middleware in front has verified.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return "hdr:" + v
+		}
+		return ""
+	}
+}
+
+// FirstKey tries each KeyFunc in turn and uses the first non-empty key.
+func FirstKey(funcs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, f := range funcs {
+			if key := f(r); key != "" {
+				return key
+			}
+		}
+		return ""
+	}
+}
+
+type RateLimitConfig struct {
+	// Route labels the rejection counter and namespaces the buckets, so
+	// routes sharing a store keep separate budgets.
+	Route string
+	// Rate is the number of tokens added to each bucket per second.
+	Rate float64
+	// Burst is the bucket capacity, the most requests a client can make at
+	// once after being idle.
+	Burst int
+	// Key identifies the client. Defaults to KeyByIP.
+	Key KeyFunc
+	// Store holds the buckets. Defaults to a new MemoryRateLimitStore; use a
+	// RedisRateLimitStore to share budgets between replicas.
+	Store RateLimitStore
+	// FailOpen lets requests through when the store cannot be reached.
+	// Otherwise they are rejected with 503.
+	FailOpen bool
+}
+
+// Limit is the budget a store enforces for a key.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// emissionInterval is the time it takes to earn one token.
+func (l Limit) emissionInterval() time.Duration {
+	return time.Duration(float64(time.Second) / l.Rate)
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next request would be allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// RateLimitStore takes one token from key's bucket if the limit allows it.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit Limit) (RateLimitResult, error)
+}
+
+// RateLimiter charges each client's requests against its own bucket, so one
+// noisy client cannot spend another's budget.
+type RateLimiter struct {
+	cfg   RateLimitConfig
+	limit Limit
+}
+
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	if cfg.Key == nil {
+		cfg.Key = KeyByIP
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{cfg: cfg, limit: Limit{Rate: cfg.Rate, Burst: cfg.Burst}}
+}
+
+// Allow takes a token from key's bucket if one is available.
+func (l *RateLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	return l.cfg.Store.Take(ctx, l.cfg.Route+"|"+key, l.limit)
+}
+
+// Middleware rejects requests over the limit with 429 instead of queueing
+// them, and reports the client's budget in RateLimit-* headers.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	policy := fmt.Sprintf("%d;w=%d", l.cfg.Burst, int(math.Ceil(float64(l.cfg.Burst)/l.cfg.Rate)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.cfg.Key(r)
+		if key == "" {
+			key = KeyByIP(r)
+		}
+
+		res, err := l.Allow(r.Context(), key)
+		if err != nil {
+			rateLimitStoreErrors.WithLabelValues(l.cfg.Route).Inc()
+			logging.FromContext(r.Context()).Warn("rate limit store unavailable",
+				"limiter", l.cfg.Route, "fail_open", l.cfg.FailOpen, "error", err)
+			if l.cfg.FailOpen {
+				next.ServeHTTP(w, r)
+				return
+			}
+			writeRateLimitProblem(w, r, http.StatusServiceUnavailable, "rate_limiter_unavailable")
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			rateLimitedRequests.WithLabelValues(l.cfg.Route).Inc()
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			writeRateLimitProblem(w, r, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewRateLimitMiddleware builds a keyed limiter for one route.
+func NewRateLimitMiddleware(cfg RateLimitConfig) func(next http.Handler) http.Handler {
+	return NewRateLimiter(cfg).Middleware
+}
+
+func writeRateLimitProblem(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Status   int    `json:"status"`
+		Code     string `json:"code"`
+		Instance string `json:"instance"`
+	}{"/problems/" + strings.ReplaceAll(code, "_", "-"), http.StatusText(status), status, code, r.URL.Path})
+}
+
+func ceilSeconds(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 && d > 0 {
+		return 1
 	}
+	return s
 }
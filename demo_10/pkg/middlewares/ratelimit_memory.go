@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// minBucketTTL keeps short-lived buckets around long enough that sweeping
+// does not dominate the cost of a check.
+const minBucketTTL = time.Minute
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+	ttl      time.Duration
+}
+
+// MemoryRateLimitStore keeps token buckets in process. Budgets are per
+// replica; use RedisRateLimitStore when several replicas share a limit.
+type MemoryRateLimitStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit Limit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	burst := float64(limit.Burst)
+	b, ok := s.buckets[key]
+	if !ok {
+		// A bucket left alone for as long as it takes to refill is
+		// indistinguishable from a new one, so that is how long it is kept.
+		ttl := time.Duration(burst) * limit.emissionInterval()
+		b = &bucket{tokens: burst, lastSeen: now, ttl: max(ttl, minBucketTTL)}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.lastSeen).Seconds()*limit.Rate)
+	b.lastSeen = now
+
+	res := RateLimitResult{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = tokensDuration(1-b.tokens, limit)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = tokensDuration(burst-b.tokens, limit)
+
+	return res, nil
+}
+
+// sweep drops idle buckets at most once a minute, so memory tracks the
+// number of recently active clients rather than every client ever seen.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < minBucketTTL {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.lastSeen) >= b.ttl {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func tokensDuration(tokens float64, limit Limit) time.Duration {
+	return time.Duration(tokens / limit.Rate * float64(time.Second))
+}
@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript implements the generic cell rate algorithm. The only state per
+// key is the theoretical arrival time (TAT) of the next request, in
+// microseconds on the Redis clock, so every replica sees the same budget
+// regardless of its own clock. The key expires once the bucket is full.
+//
+// Returns {allowed, remaining, retry_after_us, reset_us}.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - interval * burst
+if now < allow_at then
+	return {0, 0, allow_at - now, tat - now}
+end
+
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`)
+
+const defaultRedisRateLimitTimeout = 100 * time.Millisecond
+
+// RedisRateLimitStore shares rate limits between replicas. Each check is a
+// single atomic script call.
+type RedisRateLimitStore struct {
+	client redis.UniversalClient
+	prefix string
+	// Timeout bounds each check so a slow Redis degrades into the
+	// limiter's fail-open or fail-closed behaviour instead of stalling
+	// requests.
+	Timeout time.Duration
+}
+
+func NewRedisRateLimitStore(client redis.UniversalClient) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client:  client,
+		prefix:  "ratelimit:",
+		Timeout: defaultRedisRateLimitTimeout,
+	}
+}
+
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, limit Limit) (RateLimitResult, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	interval := limit.emissionInterval().Microseconds()
+	values, err := gcraScript.Run(ctx, s.client, []string{s.prefix + key}, interval, limit.Burst).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		Reset:      time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// runRateLimitStoreTests checks the behaviour every RateLimitStore must
+// share. advance moves the store's clock forward.
+func runRateLimitStoreTests(t *testing.T, newStore func(t *testing.T) (RateLimitStore, func(time.Duration))) {
+	ctx := context.Background()
+	limit := Limit{Rate: 2, Burst: 3}
+
+	t.Run("burst then reject", func(t *testing.T) {
+		store, advance := newStore(t)
+
+		for i := 0; i < 3; i++ {
+			res, err := store.Take(ctx, "a", limit)
+			if err != nil {
+				t.Fatalf("Take() error = %v", err)
+			}
+			if !res.Allowed || res.Remaining != 2-i {
+				t.Fatalf("request %d = %+v, want allowed with %d remaining", i, res, 2-i)
+			}
+		}
+
+		res, err := store.Take(ctx, "a", limit)
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if res.Allowed || res.RetryAfter != 500*time.Millisecond || res.Reset != 1500*time.Millisecond {
+			t.Fatalf("over burst = %+v, want rejected with 500ms retry and 1.5s reset", res)
+		}
+
+		if res, _ := store.Take(ctx, "b", limit); !res.Allowed {
+			t.Errorf("other key rejected, buckets must be per key")
+		}
+
+		advance(500 * time.Millisecond)
+		if res, _ := store.Take(ctx, "a", limit); !res.Allowed || res.Remaining != 0 {
+			t.Errorf("request after refill = %+v, want allowed with 0 remaining", res)
+		}
+	})
+
+	t.Run("refills to burst only", func(t *testing.T) {
+		store, advance := newStore(t)
+
+		store.Take(ctx, "a", limit)
+		advance(time.Hour)
+		res, _ := store.Take(ctx, "a", limit)
+		if !res.Allowed || res.Remaining != 2 {
+			t.Errorf("after long idle = %+v, want a full bucket", res)
+		}
+	})
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	runRateLimitStoreTests(t, func(t *testing.T) (RateLimitStore, func(time.Duration)) {
+		store := NewMemoryRateLimitStore()
+		now := time.Unix(1700000000, 0)
+		store.now = func() time.Time { return now }
+		return store, func(d time.Duration) { now = now.Add(d) }
+	})
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	runRateLimitStoreTests(t, func(t *testing.T) (RateLimitStore, func(time.Duration)) {
+		mr := miniredis.RunT(t)
+		now := time.Unix(1700000000, 0)
+		mr.SetTime(now)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+
+		return NewRedisRateLimitStore(client), func(d time.Duration) {
+			now = now.Add(d)
+			mr.SetTime(now)
+			mr.FastForward(d)
+		}
+	})
+}
+
+func TestMemoryRateLimitStoreEvictsIdleBuckets(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 1}
+
+	store.Take(context.Background(), "a", limit)
+	now = now.Add(30 * time.Second)
+	store.Take(context.Background(), "b", limit)
+	now = now.Add(45 * time.Second)
+	store.Take(context.Background(), "c", limit)
+
+	if _, ok := store.buckets["a"]; ok {
+		t.Errorf("idle bucket a was not evicted")
+	}
+	if _, ok := store.buckets["b"]; !ok {
+		t.Errorf("active bucket b was evicted")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return time.Unix(1700000000, 0) }
+	l := NewRateLimiter(RateLimitConfig{
+		Route: "test",
+		Rate:  1,
+		Burst: 1,
+		Key:   FirstKey(KeyByHeader("X-API-Key"), KeyByIP),
+		Store: store,
+	})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		wantStatus int
+	}{
+		{name: "first request", apiKey: "k1", wantStatus: http.StatusOK},
+		{name: "same key over limit", apiKey: "k1", wantStatus: http.StatusTooManyRequests},
+		{name: "different key", apiKey: "k2", wantStatus: http.StatusOK},
+		{name: "no key falls back to ip", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/books/1", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Header().Get("RateLimit-Limit") != "1" {
+				t.Errorf("RateLimit-Limit = %q, want 1", rec.Header().Get("RateLimit-Limit"))
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1" {
+				t.Errorf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareStoreDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	mr.Close()
+
+	tests := []struct {
+		name       string
+		failOpen   bool
+		wantStatus int
+	}{
+		{name: "fail open", failOpen: true, wantStatus: http.StatusOK},
+		{name: "fail closed", failOpen: false, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(RateLimitConfig{
+				Route:    "test",
+				Rate:     1,
+				Burst:    1,
+				Store:    NewRedisRateLimitStore(client),
+				FailOpen: tt.failOpen,
+			})
+			rec := httptest.NewRecorder()
+			l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+				ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
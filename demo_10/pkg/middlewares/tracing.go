@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "book-service/pkg/middlewares"
+
+// TraceIDHeader carries the trace ID back to the client so a failed request
+// can be looked up without access to the caller's own spans.
+const TraceIDHeader = "X-Trace-Id"
+
+type TracingConfig struct {
+	// TracerProvider creates the server spans. Defaults to the global
+	// provider.
+	TracerProvider trace.TracerProvider
+	// Propagator reads the caller's trace context from the request headers.
+	// Defaults to the global propagator.
+	Propagator propagation.TextMapPropagator
+}
+
+// Tracing starts a server span for every request, continuing the caller's
+// trace when the request carries a W3C traceparent header.
+type Tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func NewTracing(cfg TracingConfig) *Tracing {
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.Propagator == nil {
+		cfg.Propagator = otel.GetTextMapPropagator()
+	}
+	return &Tracing{
+		tracer:     cfg.TracerProvider.Tracer(tracerName),
+		propagator: cfg.Propagator,
+	}
+}
+
+// Middleware must be installed with mux.Router.Use so spans are named after
+// the route template rather than the raw path.
+func (t *Tracing) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := t.tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			w.Header().Set(TraceIDHeader, sc.TraceID().String())
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parent  = "00f067aa0ba902b7"
+	)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracing := NewTracing(TracingConfig{TracerProvider: provider, Propagator: propagation.TraceContext{}})
+
+	var handlerSpan trace.SpanContext
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	r.HandleFunc("/api/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		if mux.Vars(r)["id"] == "500" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		parent     string
+		wantStatus codes.Code
+	}{
+		{name: "continues caller trace", path: "/api/books/1", parent: "00-" + traceID + "-" + parent + "-01", wantStatus: codes.Unset},
+		{name: "starts new trace", path: "/api/books/2", wantStatus: codes.Unset},
+		{name: "server error", path: "/api/books/500", wantStatus: codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.parent != "" {
+				req.Header.Set("traceparent", tt.parent)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != "GET /api/books/{id}" {
+				t.Errorf("span name = %q, want %q", span.Name, "GET /api/books/{id}")
+			}
+			if span.SpanKind != trace.SpanKindServer {
+				t.Errorf("span kind = %v, want server", span.SpanKind)
+			}
+			if span.Status.Code != tt.wantStatus {
+				t.Errorf("span status = %v, want %v", span.Status.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get(TraceIDHeader); got != span.SpanContext.TraceID().String() {
+				t.Errorf("%s = %q, want %q", TraceIDHeader, got, span.SpanContext.TraceID())
+			}
+			if handlerSpan.SpanID() != span.SpanContext.SpanID() {
+				t.Error("handler context does not carry the server span")
+			}
+			if tt.parent != "" {
+				if got := span.SpanContext.TraceID().String(); got != traceID {
+					t.Errorf("trace ID = %s, want caller's %s", got, traceID)
+				}
+				if got := span.Parent.SpanID().String(); got != parent {
+					t.Errorf("parent span ID = %s, want %s", got, parent)
+				}
+			} else if span.Parent.IsValid() {
+				t.Errorf("span has parent %v, want a new trace", span.Parent)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Package tracing configures the global OpenTelemetry tracer provider and
+// W3C trace context propagation.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+type Config struct {
+	ServiceName string
+	// Exporter is ExporterOTLP, ExporterStdout or ExporterNone. Empty means
+	// none: spans are still created so trace IDs propagate, but nothing is
+	// exported.
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://otel-collector:4318.
+	// Empty falls back to OTEL_EXPORTER_OTLP_ENDPOINT, then localhost:4318.
+	Endpoint string
+	// SampleRatio is the fraction of new traces that are recorded; 0 records
+	// none. Requests that arrive with a sampled parent are always recorded.
+	// Nil means 1.
+	SampleRatio *float64
+}
+
+// Setup installs a tracer provider and the W3C traceparent/baggage
+// propagator globally. The returned func flushes buffered spans and must be
+// called before the process exits.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("build resource: %w", err)
+	}
+
+	ratio := 1.0
+	if cfg.SampleRatio != nil {
+		ratio = *cfg.SampleRatio
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected %s, %s or %s", cfg.Exporter, ExporterOTLP, ExporterStdout, ExporterNone)
+	}
+}
+
+// TraceID returns the ID of the trace ctx belongs to, or "" outside a
+// sampled or propagated trace.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}